@@ -0,0 +1,427 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store API settings
+const (
+	appStoreAudience  = "appstoreconnect-v1"
+	googlePlayScope   = "https://www.googleapis.com/auth/androidpublisher"
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	storeTokenTTL     = 15 * time.Minute // App Store Connect rejects tokens living over 20 minutes
+	appStorePageSize  = 200
+	googlePlayPageMax = 100
+)
+
+// AppStoreConfig reads customer reviews from the App Store Connect API
+type AppStoreConfig struct {
+	AppID      string `json:"app_id"`
+	IssuerID   string `json:"issuer_id"`
+	KeyID      string `json:"key_id"`
+	PrivateKey string `json:"private_key"` // .p8 contents, or "${JOB_SECRET_ASC_KEY}" holding them or a path to the file
+}
+
+// appStoreConnector reads reviews newest first and stops at the cursor, the
+// createdDate of the newest review of the previous sync
+type appStoreConnector struct {
+	config  ConnectorConfig
+	baseURL string
+	client  *http.Client
+}
+
+func newAppStoreConnector(config ConnectorConfig, client *http.Client) (*appStoreConnector, error) {
+	settings := config.AppStore
+	if settings.AppID == "" || settings.IssuerID == "" || settings.KeyID == "" || settings.PrivateKey == "" {
+		return nil, fmt.Errorf("appstore needs app_id, issuer_id, key_id and private_key")
+	}
+	return &appStoreConnector{
+		config:  config,
+		baseURL: connectorBaseURL(config, "https://api.appstoreconnect.apple.com"),
+		client:  client,
+	}, nil
+}
+
+// appStoreReviewPage is one page of customerReviews
+type appStoreReviewPage struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Rating           int    `json:"rating"`
+			Title            string `json:"title"`
+			Body             string `json:"body"`
+			ReviewerNickname string `json:"reviewerNickname"`
+			CreatedDate      string `json:"createdDate"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+// Sync reads reviews created after the cursor
+func (a *appStoreConnector) Sync(cursor string) ([]Review, string, error) {
+	settings := a.config.AppStore
+	key, err := parsePrivateKey(settings.PrivateKey)
+	if err != nil {
+		return nil, cursor, fmt.Errorf("appstore private_key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve.Params().BitSize != 256 {
+		return nil, cursor, fmt.Errorf("appstore private_key must be a P-256 (ES256) key")
+	}
+	now := time.Now()
+	token, err := signJWT(map[string]interface{}{"alg": "ES256", "kid": expandEnv(settings.KeyID), "typ": "JWT"}, map[string]interface{}{
+		"iss": expandEnv(settings.IssuerID),
+		"iat": now.Unix(),
+		"exp": now.Add(storeTokenTTL).Unix(),
+		"aud": appStoreAudience,
+	}, ecKey)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	stop, err := storeStopTime(cursor, a.config.sinceUnix())
+	if err != nil {
+		return nil, cursor, err
+	}
+	next := fmt.Sprintf("%s/v1/apps/%s/customerReviews?sort=-createdDate&limit=%d", a.baseURL, url.PathEscape(expandEnv(settings.AppID)), appStorePageSize)
+	newest := stop
+	var reviews []Review
+	for page := 0; page < connectorMaxPages && next != ""; page++ {
+		req, err := http.NewRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return nil, cursor, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		var body appStoreReviewPage
+		if _, err := doConnectorRequest(a.client, req, &body); err != nil {
+			return nil, cursor, err
+		}
+		next = ""
+		if body.Links.Next != "" {
+			link, err := followLink(req.URL, body.Links.Next)
+			if err != nil {
+				return nil, cursor, err
+			}
+			next = link.String()
+		}
+		for _, item := range body.Data {
+			created, err := time.Parse(time.RFC3339, item.Attributes.CreatedDate)
+			if err != nil {
+				continue
+			}
+			if !created.After(stop) {
+				next = "" // sorted newest first, so the rest were synced before
+				break
+			}
+			if created.After(newest) {
+				newest = created
+			}
+			reviews = append(reviews, Review{
+				ID:         "appstore-" + item.ID,
+				Date:       normalizeDate(item.Attributes.CreatedDate),
+				UserID:     item.Attributes.ReviewerNickname,
+				ReviewText: joinText(item.Attributes.Title, item.Attributes.Body),
+				Rating:     item.Attributes.Rating,
+				Source:     a.config.source(),
+			})
+		}
+	}
+	if len(reviews) == 0 {
+		return reviews, cursor, nil
+	}
+	return reviews, newest.UTC().Format(time.RFC3339), nil
+}
+
+// GooglePlayConfig reads reviews from the Google Play Developer API, which
+// returns reviews created or edited in the last week
+type GooglePlayConfig struct {
+	PackageName    string `json:"package_name"`
+	ServiceAccount string `json:"service_account,omitempty"` // key JSON, or "${JOB_SECRET_PLAY_ACCOUNT}" holding it or a path to it
+	AccessToken    string `json:"access_token,omitempty"`    // OAuth token used instead of a service account
+}
+
+// googlePlayConnector keeps reviews modified after the cursor, the latest
+// lastModified time seen in Unix seconds
+type googlePlayConnector struct {
+	config  ConnectorConfig
+	baseURL string
+	client  *http.Client
+}
+
+func newGooglePlayConnector(config ConnectorConfig, client *http.Client) (*googlePlayConnector, error) {
+	settings := config.GooglePlay
+	if settings.PackageName == "" {
+		return nil, fmt.Errorf("googleplay needs a package_name")
+	}
+	if settings.ServiceAccount == "" && settings.AccessToken == "" {
+		return nil, fmt.Errorf("googleplay needs a service_account or access_token")
+	}
+	return &googlePlayConnector{
+		config:  config,
+		baseURL: connectorBaseURL(config, "https://androidpublisher.googleapis.com"),
+		client:  client,
+	}, nil
+}
+
+// googlePlayReviewPage is one page of reviews.list
+type googlePlayReviewPage struct {
+	Reviews []struct {
+		ReviewID   string `json:"reviewId"`
+		AuthorName string `json:"authorName"`
+		Comments   []struct {
+			UserComment *struct {
+				Text         string `json:"text"`
+				StarRating   int    `json:"starRating"`
+				LastModified struct {
+					Seconds json.Number `json:"seconds"`
+				} `json:"lastModified"`
+			} `json:"userComment"`
+		} `json:"comments"`
+	} `json:"reviews"`
+	TokenPagination *struct {
+		NextPageToken string `json:"nextPageToken"`
+	} `json:"tokenPagination"`
+}
+
+// Sync reads every page and keeps reviews modified after the cursor
+func (g *googlePlayConnector) Sync(cursor string) ([]Review, string, error) {
+	token, err := g.accessToken()
+	if err != nil {
+		return nil, cursor, err
+	}
+	after := g.config.sinceUnix()
+	if cursor != "" {
+		if after, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return nil, cursor, fmt.Errorf("invalid googleplay cursor %q", cursor)
+		}
+	}
+	latest := after
+
+	var reviews []Review
+	pageToken := ""
+	for page := 0; page < connectorMaxPages; page++ {
+		query := url.Values{"maxResults": {strconv.Itoa(googlePlayPageMax)}}
+		if pageToken != "" {
+			query.Set("token", pageToken)
+		}
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/androidpublisher/v3/applications/%s/reviews?%s",
+			g.baseURL, url.PathEscape(expandEnv(g.config.GooglePlay.PackageName)), query.Encode()), nil)
+		if err != nil {
+			return nil, cursor, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		var body googlePlayReviewPage
+		if _, err := doConnectorRequest(g.client, req, &body); err != nil {
+			return nil, cursor, err
+		}
+		for _, item := range body.Reviews {
+			// The first comment is the user's; later ones are developer replies
+			if len(item.Comments) == 0 || item.Comments[0].UserComment == nil {
+				continue
+			}
+			comment := item.Comments[0].UserComment
+			modified, err := comment.LastModified.Seconds.Int64()
+			if err != nil || modified <= after {
+				continue
+			}
+			if modified > latest {
+				latest = modified
+			}
+			reviews = append(reviews, Review{
+				ID:         "googleplay-" + item.ReviewID,
+				Date:       normalizeDate(strconv.FormatInt(modified, 10)),
+				UserID:     item.AuthorName,
+				ReviewText: strings.TrimSpace(comment.Text),
+				Rating:     comment.StarRating,
+				Source:     g.config.source(),
+			})
+		}
+		if body.TokenPagination == nil || body.TokenPagination.NextPageToken == "" {
+			break
+		}
+		pageToken = body.TokenPagination.NextPageToken
+	}
+	return reviews, strconv.FormatInt(latest, 10), nil
+}
+
+// googleServiceAccount is the part of a service account key file we use
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// accessToken returns the configured token or exchanges a signed service
+// account assertion for one
+func (g *googlePlayConnector) accessToken() (string, error) {
+	settings := g.config.GooglePlay
+	if settings.AccessToken != "" {
+		return expandEnv(settings.AccessToken), nil
+	}
+
+	data, err := readKeyMaterial(settings.ServiceAccount)
+	if err != nil {
+		return "", fmt.Errorf("googleplay service_account: %w", err)
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return "", fmt.Errorf("googleplay service_account is not a key file: %w", err)
+	}
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("googleplay service_account private_key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("googleplay service_account private_key must be an RSA key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURL
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(map[string]interface{}{"alg": "RS256", "typ": "JWT"}, map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": googlePlayScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(storeTokenTTL).Unix(),
+	}, rsaKey)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequest(http.MethodPost, account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if _, err := doConnectorRequest(g.client, req, &body); err != nil {
+		return "", fmt.Errorf("googleplay token exchange failed: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("googleplay token exchange returned no access_token")
+	}
+	return body.AccessToken, nil
+}
+
+// storeStopTime is the createdDate cursor, or the configured start for a first sync
+func storeStopTime(cursor string, since int64) (time.Time, error) {
+	if cursor == "" {
+		return time.Unix(since, 0), nil
+	}
+	stop, err := time.Parse(time.RFC3339, cursor)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid appstore cursor %q", cursor)
+	}
+	return stop, nil
+}
+
+// readKeyMaterial resolves ${ENV} references and reads file paths, so keys
+// can be given inline, through the environment or as a file. Only a path
+// from the environment is read: a path posted to the API could name any
+// key file on the server
+func readKeyMaterial(value string) ([]byte, error) {
+	fromEnv := isEnvReference(value)
+	value = expandEnv(value)
+	if value == "" {
+		return nil, fmt.Errorf("no key given")
+	}
+	if strings.HasPrefix(value, "-----BEGIN") || strings.HasPrefix(value, "{") {
+		return []byte(value), nil
+	}
+	if !fromEnv {
+		return nil, fmt.Errorf("a key file path must be given through an environment variable")
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return data, nil
+}
+
+// parsePrivateKey reads a PKCS #8 PEM key, as issued by Apple and Google
+func parsePrivateKey(value string) (crypto.Signer, error) {
+	data, err := readKeyMaterial(value)
+	if err != nil {
+		return nil, err
+	}
+	// Keys pasted into JSON or environment variables often carry escaped newlines
+	data = []byte(strings.ReplaceAll(string(data), `\n`, "\n"))
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("not a PEM key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("not a PKCS #8 key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// signJWT creates a compact JWS signed with ES256 or RS256, depending on the key
+func signJWT(header, claims map[string]interface{}, key crypto.Signer) (string, error) {
+	encode := func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+	h, err := encode(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := encode(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := h + "." + c
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS wants the fixed-width r || s, not ASN.1
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case *rsa.PrivateKey:
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported JWT key type %T", key)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
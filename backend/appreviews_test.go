@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pemKey encodes a private key as PKCS #8, as Apple and Google issue them
+func pemKey(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// verifyJWT checks a token's signature and returns its claims
+func verifyJWT(t *testing.T, token string, key crypto.PublicKey) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			t.Fatalf("ES256 signature is %d bytes, want 64", len(signature))
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			t.Fatal("invalid ES256 signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature); err != nil {
+			t.Fatalf("invalid RS256 signature: %v", err)
+		}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	return claims
+}
+
+func TestAppStoreConnectorSync(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var limited int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/123/customerReviews" {
+			http.NotFound(w, r)
+			return
+		}
+		claims := verifyJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+		if claims["iss"] != "issuer" || claims["aud"] != appStoreAudience {
+			t.Errorf("claims = %v", claims)
+		}
+		if r.URL.Query().Get("sort") != "-createdDate" {
+			t.Errorf("sort = %q", r.URL.Query().Get("sort"))
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			fmt.Fprintf(w, `{"data":[
+				{"id":"r4","attributes":{"rating":2,"title":"Crashes","body":"Since the update","reviewerNickname":"n4","createdDate":"2024-02-05T08:00:00-07:00"}},
+				{"id":"r3","attributes":{"rating":5,"title":"Great","body":"","reviewerNickname":"n3","createdDate":"2024-02-04T08:00:00-07:00"}}
+			],"links":{"next":"%s/v1/apps/123/customerReviews?sort=-createdDate&cursor=p2"}}`, "http://"+r.Host)
+		case "p2":
+			if atomic.AddInt32(&limited, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"data":[
+				{"id":"r2","attributes":{"rating":4,"title":"Good","body":"Mostly","reviewerNickname":"n2","createdDate":"2024-01-20T08:00:00-07:00"}},
+				{"id":"r1","attributes":{"rating":3,"title":"Old","body":"Before since","reviewerNickname":"n1","createdDate":"2023-12-20T08:00:00-07:00"}}
+			],"links":{"next":"/v1/apps/123/customerReviews?sort=-createdDate&cursor=p3"}}`)
+		default:
+			t.Errorf("read past the since date: %s", r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	config := ConnectorConfig{Name: "ios", Type: connectorAppStore, BaseURL: srv.URL, Since: "2024-01-01",
+		AppStore: &AppStoreConfig{AppID: "123", IssuerID: "issuer", KeyID: "KEY", PrivateKey: pemKey(t, key)}}
+	connector, err := newConnector(config, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+
+	reviews, cursor, err := connector.Sync("")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if cursor != "2024-02-05T15:00:00Z" {
+		t.Fatalf("cursor = %q, want the newest createdDate", cursor)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "appstore-r4,appstore-r3,appstore-r2" {
+		t.Fatalf("reviews = %s, want r1 (before since) left out", got)
+	}
+	want := Review{ID: "appstore-r4", Date: "2024-02-05", UserID: "n4", ReviewText: "Crashes\n\nSince the update", Rating: 2, Source: "app_store"}
+	if reviews[0] != want {
+		t.Fatalf("review = %+v, want %+v", reviews[0], want)
+	}
+
+	// Resuming stops at the first review already synced and keeps the cursor
+	reviews, next, err := connector.Sync(cursor)
+	if err != nil {
+		t.Fatalf("resumed Sync: %v", err)
+	}
+	if len(reviews) != 0 || next != cursor {
+		t.Fatalf("resumed sync = %d reviews, cursor %q", len(reviews), next)
+	}
+}
+
+func TestAppStoreConnectorRefusesCrossOriginLinks(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"id":"r1","attributes":{"rating":5,"body":"ok","createdDate":"2024-02-05T08:00:00Z"}}],
+			"links":{"next":"https://attacker.example.com/collect"}}`)
+	}))
+	defer srv.Close()
+
+	connector, err := newConnector(ConnectorConfig{Name: "ios", Type: connectorAppStore, BaseURL: srv.URL,
+		AppStore: &AppStoreConfig{AppID: "123", IssuerID: "issuer", KeyID: "KEY", PrivateKey: pemKey(t, key)}}, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+	if _, _, err := connector.Sync(""); err == nil || !strings.Contains(err.Error(), "leaves") {
+		t.Fatalf("got %v, want the cross-origin link refused", err)
+	}
+}
+
+func TestGooglePlayConnectorSync(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var limited int32
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		claims := verifyJWT(t, r.Form.Get("assertion"), &key.PublicKey)
+		if claims["iss"] != "play@example.iam.gserviceaccount.com" || claims["aud"] != srv.URL+"/token" || claims["scope"] != googlePlayScope {
+			t.Errorf("claims = %v", claims)
+		}
+		fmt.Fprint(w, `{"access_token":"play-token"}`)
+	})
+	mux.HandleFunc("/androidpublisher/v3/applications/com.example.app/reviews", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer play-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("token") {
+		case "":
+			fmt.Fprint(w, `{"reviews":[
+				{"reviewId":"g2","authorName":"Ann","comments":[
+					{"userComment":{"text":" Battery drain ","starRating":1,"lastModified":{"seconds":"1707000000"}}},
+					{"developerComment":{"text":"Sorry!"}}]},
+				{"reviewId":"g1","authorName":"Bo","comments":[{"userComment":{"text":"Old","starRating":5,"lastModified":{"seconds":"1706000000"}}}]}
+			],"tokenPagination":{"nextPageToken":"p2"}}`)
+		case "p2":
+			if atomic.AddInt32(&limited, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"reviews":[
+				{"reviewId":"g3","authorName":"Cy","comments":[{"userComment":{"text":"Nice widgets","starRating":4,"lastModified":{"seconds":"1707100000"}}}]},
+				{"reviewId":"g4","authorName":"Di","comments":[{"developerComment":{"text":"reply only"}}]}
+			]}`)
+		default:
+			t.Errorf("unexpected token %q", r.URL.Query().Get("token"))
+		}
+	})
+
+	account, _ := json.Marshal(map[string]string{
+		"client_email": "play@example.iam.gserviceaccount.com",
+		"private_key":  pemKey(t, key),
+		"token_uri":    srv.URL + "/token",
+	})
+	t.Setenv("JOB_SECRET_PLAY_ACCOUNT", string(account))
+	connector, err := newConnector(ConnectorConfig{Name: "android", Type: connectorGooglePlay, BaseURL: srv.URL,
+		GooglePlay: &GooglePlayConfig{PackageName: "com.example.app", ServiceAccount: "${JOB_SECRET_PLAY_ACCOUNT}"}}, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+
+	// Resuming from a cursor keeps only reviews modified after it
+	reviews, cursor, err := connector.Sync("1706500000")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if cursor != "1707100000" {
+		t.Fatalf("cursor = %q, want the latest lastModified", cursor)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "googleplay-g2,googleplay-g3" {
+		t.Fatalf("reviews = %s", got)
+	}
+	want := Review{ID: "googleplay-g2", Date: "2024-02-03", UserID: "Ann", ReviewText: "Battery drain", Rating: 1, Source: "google_play"}
+	if reviews[0] != want {
+		t.Fatalf("review = %+v, want %+v", reviews[0], want)
+	}
+}
+
+func TestReadKeyMaterialOnlyReadsPathsFromTheEnvironment(t *testing.T) {
+	path := t.TempDir() + "/key.p8"
+	t.Setenv("JOB_SECRET_KEY_PATH", path)
+	if _, err := readKeyMaterial(path); err == nil {
+		t.Fatal("a literal key path was read")
+	}
+	if _, err := readKeyMaterial("${JOB_SECRET_KEY_PATH}"); err == nil || !strings.Contains(err.Error(), "failed to read key file") {
+		t.Fatalf("got %v, want the path from the environment read", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AspectAnalyzer is implemented by analyzers that can extract per-review aspect sentiment
+type AspectAnalyzer interface {
+	ExtractAspects(reviews []Review, themes []string) ([]AspectSentiment, error)
+}
+
+// AspectSentiment is the sentiment a single review expresses about one aspect
+type AspectSentiment struct {
+	ReviewID  string `json:"review_id"`
+	Phase     string `json:"phase"` // "pre_launch" or "post_launch"
+	Aspect    string `json:"aspect"`
+	Sentiment string `json:"sentiment"` // positive, negative, neutral
+	Evidence  string `json:"evidence"`  // span of the review text expressing the sentiment
+}
+
+// SentimentCounts tallies sentiment labels
+type SentimentCounts struct {
+	Positive int `json:"positive"`
+	Negative int `json:"negative"`
+	Neutral  int `json:"neutral"`
+}
+
+// add counts one label, treating anything unrecognised as neutral
+func (c *SentimentCounts) add(sentiment string) {
+	switch sentiment {
+	case "positive":
+		c.Positive++
+	case "negative":
+		c.Negative++
+	default:
+		c.Neutral++
+	}
+}
+
+// merge adds another tally into this one
+func (c *SentimentCounts) merge(other SentimentCounts) {
+	c.Positive += other.Positive
+	c.Negative += other.Negative
+	c.Neutral += other.Neutral
+}
+
+// scale multiplies each count by factor, used when extrapolating from a sample
+func (c *SentimentCounts) scale(factor float64) {
+	c.Positive = int(float64(c.Positive)*factor + 0.5)
+	c.Negative = int(float64(c.Negative)*factor + 0.5)
+	c.Neutral = int(float64(c.Neutral)*factor + 0.5)
+}
+
+// ExtractAspects asks the LLM for (review, aspect, sentiment, evidence) tuples
+func (g *GroqClient) ExtractAspects(reviews []Review, themes []string) ([]AspectSentiment, error) {
+	if len(reviews) == 0 {
+		return []AspectSentiment{}, nil
+	}
+
+	prompt, err := g.renderPrompt(promptAspects, map[string]interface{}{
+		"Reviews": formatReviewsForSentiment(reviews),
+		"Themes":  "- " + strings.Join(themes, "\n- "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := g.callGroqAPI("aspects", prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	response = cleanJSONResponse(response)
+
+	var results []AspectSentiment
+	if err := json.Unmarshal([]byte(response), &results); err != nil {
+		return nil, fmt.Errorf("failed to parse aspect results: %w, response: %s", err, response)
+	}
+
+	return results, nil
+}
+
+// extractAspects runs aspect extraction for both phases, tagging each tuple with its phase
+func extractAspects(analyzer AspectAnalyzer, preReviews, postReviews []Review, themes []ThemeResult) ([]AspectSentiment, error) {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.Theme
+	}
+
+	var aspects []AspectSentiment
+	for _, phase := range []struct {
+		name    string
+		reviews []Review
+	}{{"pre_launch", preReviews}, {"post_launch", postReviews}} {
+		results, err := analyzer.ExtractAspects(phase.reviews, names)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s aspects: %w", phase.name, err)
+		}
+		for _, a := range results {
+			a.Phase = phase.name
+			a.Sentiment = strings.ToLower(strings.TrimSpace(a.Sentiment))
+			aspects = append(aspects, a)
+		}
+	}
+	return aspects, nil
+}
+
+// applyAspects recounts each theme from the aspect tuples: mentions are
+// distinct reviews per phase, and sentiment is tallied per phase
+func applyAspects(themes []ThemeResult, aspects []AspectSentiment) {
+	index := make(map[string]int, len(themes))
+	for i, t := range themes {
+		index[themeKey(t.Theme)] = i
+		themes[i].PreCount, themes[i].PostCount = 0, 0
+		themes[i].PreReviewIDs, themes[i].PostReviewIDs = nil, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range aspects {
+		i, ok := index[themeKey(a.Aspect)]
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%d\x00%s\x00%s", i, a.Phase, a.ReviewID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if a.Phase == "pre_launch" {
+			themes[i].PreCount++
+			themes[i].PreReviewIDs = append(themes[i].PreReviewIDs, a.ReviewID)
+		} else {
+			themes[i].PostCount++
+			themes[i].PostReviewIDs = append(themes[i].PostReviewIDs, a.ReviewID)
+		}
+	}
+
+	for i := range themes {
+		themes[i].ChangeRate = changeRate(themes[i].PreCount, themes[i].PostCount)
+	}
+	tallyThemeSentiment(themes, aspects, nil)
+}
+
+// tallyThemeSentiment recounts each theme's sentiment from the reviews it
+// currently holds, so the tallies follow reviews moved by overrides. A review
+// with no aspect tuple for the theme counts once with its overall sentiment,
+// looked up in overall by phase and review ID
+func tallyThemeSentiment(themes []ThemeResult, aspects []AspectSentiment, overall map[string]string) {
+	tuples := make(map[string][]string)
+	for _, a := range aspects {
+		key := aspectKey(a.Aspect, a.Phase, a.ReviewID)
+		tuples[key] = append(tuples[key], a.Sentiment)
+	}
+
+	for i := range themes {
+		themes[i].PreSentiment, themes[i].PostSentiment = SentimentCounts{}, SentimentCounts{}
+		for _, phase := range []struct {
+			name   string
+			ids    []string
+			counts *SentimentCounts
+		}{{"pre_launch", themes[i].PreReviewIDs, &themes[i].PreSentiment}, {"post_launch", themes[i].PostReviewIDs, &themes[i].PostSentiment}} {
+			for _, id := range phase.ids {
+				sentiments, ok := tuples[aspectKey(themes[i].Theme, phase.name, id)]
+				if !ok {
+					sentiments = []string{overall[phase.name+"\x00"+id]}
+				}
+				for _, sentiment := range sentiments {
+					phase.counts.add(sentiment)
+				}
+			}
+		}
+	}
+}
+
+// aspectKey identifies one theme's mention in one review
+func aspectKey(theme, phase, reviewID string) string {
+	return themeKey(theme) + "\x00" + phase + "\x00" + reviewID
+}
+
+// overallSentiments indexes review sentiment by phase and review ID for tallyThemeSentiment
+func overallSentiments(preSentiments, postSentiments []SentimentResult) map[string]string {
+	overall := make(map[string]string, len(preSentiments)+len(postSentiments))
+	for _, s := range preSentiments {
+		overall["pre_launch\x00"+s.ReviewID] = s.Sentiment
+	}
+	for _, s := range postSentiments {
+		overall["post_launch\x00"+s.ReviewID] = s.Sentiment
+	}
+	return overall
+}
+
+// changeRate is the percentage change from pre to post; a theme new in post counts as +100%
+func changeRate(pre, post int) float64 {
+	if pre == 0 {
+		if post == 0 {
+			return 0
+		}
+		return 100
+	}
+	return float64(post-pre) / float64(pre) * 100
+}
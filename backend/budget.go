@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Budget actions recorded on each decision
+const (
+	budgetAllowed = "allowed"
+	budgetSampled = "sampled"
+	budgetRefused = "refused"
+)
+
+// Rough completion sizes used when estimating a run before it happens
+const (
+	estimatedCharsPerToken          = 4
+	estimatedSentimentTokensPerItem = 25
+	estimatedAspectTokensPerItem    = 60
+	estimatedThemesTokens           = 600
+	estimatedClusterTokensPerItem   = 30
+	estimatedImpactTokens           = 500
+)
+
+// CostEstimator is implemented by analyzers that can predict the provider calls of a run
+type CostEstimator interface {
+	EstimateUsage(plan UsagePlan) ([]LLMCall, error)
+}
+
+// UsagePlan describes the run EstimateUsage prices
+type UsagePlan struct {
+	PreReviews     []Review // reviews analyzed in each phase
+	PostReviews    []Review
+	UncachedPre    []Review // those whose sentiment is not cached
+	UncachedPost   []Review
+	TopN           int // themes asked of the theme call
+	Clusters       int // clusters named instead of the theme call; 0 when themes are not clustered
+	ClusterSamples int // representative reviews shown per cluster
+}
+
+// BudgetConfig holds spend limits in USD; zero disables a limit
+type BudgetConfig struct {
+	PerRequestUSD   float64            `json:"per_request_usd"`
+	PerDayUSD       float64            `json:"per_day_usd"`
+	PerKeyPerDayUSD float64            `json:"per_key_per_day_usd"` // for requests without a key, and listed keys without their own limit
+	KeyLimits       map[string]float64 `json:"-"`                   // the accepted keys' daily limits, keyed by apiKeyID
+	OnExceed        string             `json:"on_exceed"`           // "refuse" or "sample"
+}
+
+// ErrUnknownAPIKey is returned for a key outside BUDGET_KEY_LIMITS. Keys are
+// not otherwise authenticated, so any other key would get a fresh allowance
+var ErrUnknownAPIKey = errors.New("unknown API key")
+
+// ErrBudgetUnpriced refuses runs when budgets are set but the analyzer cannot
+// estimate its cost (or usage is not priced), since they could not be enforced
+var ErrBudgetUnpriced = errors.New("budgets are configured but the analyzer's cost cannot be estimated")
+
+// LoadBudgetConfig reads budgets from the environment. BUDGET_KEY_LIMITS lists
+// the accepted keys, comma-separated, each as key=usd or as a bare key that
+// gets BUDGET_PER_KEY_PER_DAY_USD. Requests without a key share one allowance
+func LoadBudgetConfig() BudgetConfig {
+	config := BudgetConfig{
+		PerRequestUSD:   getEnvFloat("BUDGET_PER_REQUEST_USD", 0),
+		PerDayUSD:       getEnvFloat("BUDGET_PER_DAY_USD", 0),
+		PerKeyPerDayUSD: getEnvFloat("BUDGET_PER_KEY_PER_DAY_USD", 0),
+		KeyLimits:       make(map[string]float64),
+		OnExceed:        getEnv("BUDGET_ON_EXCEED", "refuse"),
+	}
+	for _, pair := range strings.Split(getEnv("BUDGET_KEY_LIMITS", ""), ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if key == "" {
+			continue
+		}
+		if !ok {
+			config.KeyLimits[apiKeyID(key)] = config.PerKeyPerDayUSD
+			continue
+		}
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("Invalid BUDGET_KEY_LIMITS entry for key %s, ignoring", apiKeyID(key))
+			continue
+		}
+		config.KeyLimits[apiKeyID(key)] = limit
+	}
+	if len(config.KeyLimits) == 0 && config.PerKeyPerDayUSD > 0 {
+		log.Printf("⚠️  BUDGET_PER_KEY_PER_DAY_USD is set without BUDGET_KEY_LIMITS; requests with an API key will be rejected")
+	}
+	return config
+}
+
+// BudgetDecision explains whether a run fits within the budgets
+type BudgetDecision struct {
+	Action         string  `json:"action"` // allowed, sampled or refused
+	EstimatedUSD   float64 `json:"estimated_usd"`
+	RemainingUSD   float64 `json:"remaining_usd"` // -1 when no limit applies
+	LimitedBy      string  `json:"limited_by,omitempty"`
+	SampleFraction float64 `json:"sample_fraction,omitempty"`
+}
+
+// BudgetExceededError is returned when a run is refused by the budget guard
+type BudgetExceededError struct {
+	Decision BudgetDecision
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("estimated cost $%.4f exceeds the %s budget (remaining $%.4f)",
+		e.Decision.EstimatedUSD, e.Decision.LimitedBy, e.Decision.RemainingUSD)
+}
+
+// CostEstimate is returned by a dry run
+type CostEstimate struct {
+	DatasetID string          `json:"dataset_id"`
+	Calls     []LLMCall       `json:"calls"`
+	Summary   UsageSummary    `json:"summary"`
+	Budget    *BudgetDecision `json:"budget,omitempty"`
+}
+
+// BudgetGuard enforces per-request, per-day and per-key spend limits
+type BudgetGuard struct {
+	config BudgetConfig
+	ledger *UsageLedger
+}
+
+// NewBudgetGuard creates a guard that reads past spend from the usage ledger
+func NewBudgetGuard(config BudgetConfig, ledger *UsageLedger) *BudgetGuard {
+	return &BudgetGuard{config: config, ledger: ledger}
+}
+
+// Authorize rejects API keys the per-key budgets do not know. Requests without
+// a key, and any key when no per-key budget is set, are accepted
+func (g *BudgetGuard) Authorize(keyID string) error {
+	if keyID == apiKeyID("") || len(g.config.KeyLimits) == 0 && g.config.PerKeyPerDayUSD == 0 {
+		return nil
+	}
+	if _, ok := g.config.KeyLimits[keyID]; !ok {
+		return ErrUnknownAPIKey
+	}
+	return nil
+}
+
+// enabled reports whether any limit is set
+func (g *BudgetGuard) enabled() bool {
+	return g.config.PerRequestUSD > 0 || g.config.PerDayUSD > 0 || g.config.PerKeyPerDayUSD > 0 || len(g.config.KeyLimits) > 0
+}
+
+// Check decides whether a run with the given estimated cost may proceed,
+// counting the reservations of runs in progress as spent
+func (g *BudgetGuard) Check(keyID string, estimatedUSD float64) BudgetDecision {
+	g.ledger.mu.Lock()
+	defer g.ledger.mu.Unlock()
+	return g.decide(keyID, time.Now().UTC().Format("2006-01-02"), estimatedUSD)
+}
+
+// Reserve makes the same decision as Check and, when the run is allowed,
+// holds its estimated cost until UsageLedger.Record settles it. Deciding and
+// reserving under one lock keeps concurrent runs from all passing the check
+// and overspending together
+func (g *BudgetGuard) Reserve(keyID string, estimatedUSD float64) (BudgetDecision, *Reservation) {
+	g.ledger.mu.Lock()
+	defer g.ledger.mu.Unlock()
+	day := time.Now().UTC().Format("2006-01-02")
+	decision := g.decide(keyID, day, estimatedUSD)
+	if decision.Action != budgetAllowed {
+		return decision, nil
+	}
+	return decision, g.ledger.reserve(keyID, day, estimatedUSD)
+}
+
+// decide applies the limits to the day's spend; the caller holds the ledger lock
+func (g *BudgetGuard) decide(keyID, day string, estimatedUSD float64) BudgetDecision {
+	remaining, limitedBy := math.Inf(1), ""
+
+	limit := func(name string, value float64) {
+		if value < remaining {
+			remaining, limitedBy = math.Max(value, 0), name
+		}
+	}
+	if g.config.PerRequestUSD > 0 {
+		limit("per-request", g.config.PerRequestUSD)
+	}
+	if g.config.PerDayUSD > 0 {
+		limit("daily", g.config.PerDayUSD-g.ledger.spend("", day))
+	}
+	keyLimit, ok := g.config.KeyLimits[keyID]
+	if !ok && keyID == apiKeyID("") {
+		keyLimit = g.config.PerKeyPerDayUSD
+	}
+	if keyLimit > 0 {
+		limit("per-key", keyLimit-g.ledger.spend(keyID, day))
+	}
+
+	decision := BudgetDecision{
+		Action:       budgetAllowed,
+		EstimatedUSD: estimatedUSD,
+		RemainingUSD: -1,
+		LimitedBy:    limitedBy,
+	}
+	if math.IsInf(remaining, 1) {
+		decision.LimitedBy = ""
+		return decision
+	}
+	decision.RemainingUSD = remaining
+	if estimatedUSD <= remaining {
+		return decision
+	}
+
+	fraction := math.Floor(remaining/estimatedUSD*100) / 100
+	if g.config.OnExceed == "sample" && fraction > 0 {
+		decision.Action = budgetSampled
+		decision.SampleFraction = fraction
+		return decision
+	}
+	decision.Action = budgetRefused
+	return decision
+}
+
+// EstimateUsage predicts the provider calls of an analysis from the rendered prompts
+func (g *GroqClient) EstimateUsage(plan UsagePlan) ([]LLMCall, error) {
+	var calls []LLMCall
+
+	for _, reviews := range [][]Review{plan.UncachedPre, plan.UncachedPost} {
+		if len(reviews) == 0 {
+			continue
+		}
+		prompt, err := g.renderPrompt(promptSentiment, map[string]interface{}{
+			"Reviews": formatReviewsForSentiment(reviews),
+		})
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, estimatedCall(g.model, "sentiment", prompt, estimatedSentimentTokensPerItem*len(reviews)))
+	}
+
+	// Clustering names clusters from their representatives and only reads
+	// those for aspects; otherwise one theme call and the aspect calls see
+	// every review
+	aspectPre, aspectPost := plan.PreReviews, plan.PostReviews
+	if plan.Clusters > 0 {
+		var clusters []ReviewCluster
+		aspectPre, aspectPost, clusters = estimatedRepresentatives(plan)
+		prompt, err := g.renderPrompt(promptClusters, map[string]interface{}{
+			"Clusters": formatClustersForNaming(clusters),
+			"Taxonomy": formatTaxonomyForThemes(nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, estimatedCall(g.model, "clusters", prompt, estimatedClusterTokensPerItem*len(clusters)))
+	} else {
+		prompt, err := g.renderPrompt(promptThemes, map[string]interface{}{
+			"PreReviews":  formatReviewsForThemes(plan.PreReviews),
+			"PostReviews": formatReviewsForThemes(plan.PostReviews),
+			"Taxonomy":    formatTaxonomyForThemes(nil),
+			"TopN":        plan.TopN,
+		})
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, estimatedCall(g.model, "themes", prompt, estimatedThemesTokens))
+	}
+
+	// Aspect extraction sends the reviews again, per phase, with the theme list
+	for _, reviews := range [][]Review{aspectPre, aspectPost} {
+		if len(reviews) == 0 {
+			continue
+		}
+		prompt, err := g.renderPrompt(promptAspects, map[string]interface{}{
+			"Reviews": formatReviewsForSentiment(reviews),
+			"Themes":  "",
+		})
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, estimatedCall(g.model, "aspects", prompt, estimatedAspectTokensPerItem*len(reviews)))
+	}
+
+	// The impact prompt depends on results we do not have yet; its size barely varies
+	impact, err := g.prompts.Get(promptImpact)
+	if err != nil {
+		return nil, err
+	}
+	calls = append(calls, estimatedCall(g.model, "impact", impact.Body, estimatedImpactTokens))
+
+	return calls, nil
+}
+
+// estimatedRepresentatives stands in for the cluster representatives before
+// clustering has run: as many reviews as the clusters would show, split
+// between the phases in proportion and dealt into the clusters
+func estimatedRepresentatives(plan UsagePlan) (pre, post []Review, clusters []ReviewCluster) {
+	total := len(plan.PreReviews) + len(plan.PostReviews)
+	if total == 0 || plan.Clusters <= 0 {
+		return nil, nil, nil
+	}
+	shown := min(plan.Clusters*plan.ClusterSamples, total)
+	preShown := min(shown*len(plan.PreReviews)/total, len(plan.PreReviews))
+	pre = plan.PreReviews[:preShown]
+	post = plan.PostReviews[:min(shown-preShown, len(plan.PostReviews))]
+
+	clusters = make([]ReviewCluster, plan.Clusters)
+	for i := range clusters {
+		clusters[i] = ReviewCluster{ID: i + 1, Size: total / plan.Clusters}
+	}
+	for i, r := range append(append([]Review{}, pre...), post...) {
+		c := &clusters[i%len(clusters)]
+		c.samples = append(c.samples, r)
+	}
+	return pre, post, clusters
+}
+
+// estimatedCall builds an LLMCall with token counts approximated from prompt length
+func estimatedCall(model, operation, prompt string, completionTokens int) LLMCall {
+	promptTokens := (len(prompt) + estimatedCharsPerToken - 1) / estimatedCharsPerToken
+	return LLMCall{
+		Operation: operation,
+		Model:     model,
+		Usage: TokenUsage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}
+}
+
+// apiKeyID derives a stable, non-secret identifier for an API key
+func apiKeyID(key string) string {
+	if key == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:])[:12]
+}
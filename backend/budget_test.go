@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// budgetStubAnalyzer prices every run as a fixed cost plus one dollar per review
+type budgetStubAnalyzer struct {
+	fixedUSD int
+}
+
+func (a *budgetStubAnalyzer) AnalyzeSentiments(reviews []Review) ([]SentimentResult, error) {
+	results := make([]SentimentResult, len(reviews))
+	for i, r := range reviews {
+		results[i] = SentimentResult{ReviewID: r.ID, Sentiment: "neutral"}
+	}
+	return results, nil
+}
+
+func (a *budgetStubAnalyzer) ExtractThemes(preReviews, postReviews []Review) ([]ThemeResult, error) {
+	return nil, nil
+}
+
+func (a *budgetStubAnalyzer) GenerateImpactSummary(pre, post ReviewCollection, comparison ComparisonResult) (*ImpactSummary, error) {
+	return &ImpactSummary{}, nil
+}
+
+func (a *budgetStubAnalyzer) EstimateUsage(plan UsagePlan) ([]LLMCall, error) {
+	return []LLMCall{
+		{Operation: "sentiment", Model: "stub", Usage: TokenUsage{PromptTokens: len(plan.UncachedPre) + len(plan.UncachedPost)}},
+		{Operation: "impact", Model: "stub", Usage: TokenUsage{PromptTokens: a.fixedUSD}},
+	}, nil
+}
+
+// newBudgetTestService prices one prompt token at one dollar
+func newBudgetTestService(t *testing.T, budget BudgetConfig) *DefaultAnalysisService {
+	t.Helper()
+	ledger, err := NewUsageLedger("", PriceTable{"stub": {PromptPerMillion: 1e6}})
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	return NewAnalysisService(&budgetStubAnalyzer{fixedUSD: 20}, AnalysisDependencies{
+		Usage:    ledger,
+		Budget:   NewBudgetGuard(budget, ledger),
+		Sampling: SamplingConfig{DateBucket: "week", Seed: 1},
+	})
+}
+
+func budgetTestReviews(prefix string, n int) []Review {
+	reviews := make([]Review, n)
+	for i := range reviews {
+		reviews[i] = Review{ID: fmt.Sprintf("%s%d", prefix, i), Date: "2024-03-01", ReviewText: "fine", Rating: 1 + i%5, Source: "app_store"}
+	}
+	return reviews
+}
+
+func TestAnalyzeSampledRunFitsTheBudget(t *testing.T) {
+	// 200 reviews cost $220; a proportional cut to 68% still costs $156
+	service := newBudgetTestService(t, BudgetConfig{PerRequestUSD: 150, OnExceed: "sample"})
+	result, err := service.Analyze(budgetTestReviews("pre", 100), budgetTestReviews("post", 100), AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	decision := result.Metadata.Budget
+	if decision == nil || decision.Action != budgetSampled {
+		t.Fatalf("budget decision = %+v, want sampled", decision)
+	}
+	if decision.EstimatedUSD > 150 {
+		t.Fatalf("sampled run is estimated at $%.2f, over the $150 budget", decision.EstimatedUSD)
+	}
+	if analyzed := len(result.PreLaunchReviews.Reviews) + len(result.PostLaunchReviews.Reviews); float64(analyzed)+20 != decision.EstimatedUSD {
+		t.Fatalf("analyzed %d reviews but the estimate is $%.2f", analyzed, decision.EstimatedUSD)
+	}
+}
+
+func TestAnalyzeRefusesWhenFixedCostsExceedTheBudget(t *testing.T) {
+	service := newBudgetTestService(t, BudgetConfig{PerRequestUSD: 15, OnExceed: "sample"})
+	_, err := service.Analyze(budgetTestReviews("pre", 100), budgetTestReviews("post", 100), AnalysisOptions{})
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("got %v, want a budget refusal", err)
+	}
+	if budgetErr.Decision.Action != budgetRefused {
+		t.Fatalf("decision = %+v", budgetErr.Decision)
+	}
+}
+
+func TestBudgetGuardOnlyAcceptsListedKeys(t *testing.T) {
+	ledger, _ := NewUsageLedger("", PriceTable{})
+	guard := NewBudgetGuard(BudgetConfig{
+		PerKeyPerDayUSD: 5,
+		KeyLimits:       map[string]float64{apiKeyID("team-a"): 10, apiKeyID("team-b"): 5},
+	}, ledger)
+
+	for _, key := range []string{"team-a", "team-b", ""} {
+		if err := guard.Authorize(apiKeyID(key)); err != nil {
+			t.Fatalf("Authorize(%q) = %v", key, err)
+		}
+	}
+	if err := guard.Authorize(apiKeyID("made-up")); !errors.Is(err, ErrUnknownAPIKey) {
+		t.Fatalf("Authorize of an unlisted key = %v, want ErrUnknownAPIKey", err)
+	}
+
+	if d := guard.Check(apiKeyID("team-a"), 8); d.Action != budgetAllowed {
+		t.Fatalf("team-a decision = %+v, want allowed under its own $10 limit", d)
+	}
+	if d := guard.Check(apiKeyID(""), 8); d.Action != budgetRefused || d.LimitedBy != "per-key" {
+		t.Fatalf("anonymous decision = %+v, want refused by the shared $5 limit", d)
+	}
+}
+
+func TestBudgetGuardAcceptsAnyKeyWithoutPerKeyLimits(t *testing.T) {
+	ledger, _ := NewUsageLedger("", PriceTable{})
+	guard := NewBudgetGuard(BudgetConfig{PerDayUSD: 5}, ledger)
+	if err := guard.Authorize(apiKeyID("anything")); err != nil {
+		t.Fatalf("Authorize = %v", err)
+	}
+}
+
+func TestLoadBudgetConfigKeyList(t *testing.T) {
+	t.Setenv("BUDGET_PER_KEY_PER_DAY_USD", "3")
+	t.Setenv("BUDGET_KEY_LIMITS", "team-a=10, team-b ,bad=x")
+	config := LoadBudgetConfig()
+	if len(config.KeyLimits) != 2 || config.KeyLimits[apiKeyID("team-a")] != 10 || config.KeyLimits[apiKeyID("team-b")] != 3 {
+		t.Fatalf("key limits = %v", config.KeyLimits)
+	}
+}
+
+func TestAnalyzeRejectsUnknownAPIKeys(t *testing.T) {
+	service := newBudgetTestService(t, BudgetConfig{KeyLimits: map[string]float64{apiKeyID("team-a"): 1000}})
+	_, err := service.Estimate(budgetTestReviews("pre", 5), budgetTestReviews("post", 5), AnalysisOptions{APIKey: "random"})
+	if !errors.Is(err, ErrUnknownAPIKey) {
+		t.Fatalf("Estimate with an unlisted key = %v, want ErrUnknownAPIKey", err)
+	}
+	if _, err := service.Analyze(budgetTestReviews("pre", 5), budgetTestReviews("post", 5), AnalysisOptions{APIKey: "team-a"}); err != nil {
+		t.Fatalf("Analyze with a listed key: %v", err)
+	}
+}
+
+func TestBudgetReservationsCountUntilSettled(t *testing.T) {
+	ledger, _ := NewUsageLedger("", PriceTable{})
+	guard := NewBudgetGuard(BudgetConfig{PerDayUSD: 10}, ledger)
+
+	first, held := guard.Reserve(apiKeyID(""), 6)
+	if first.Action != budgetAllowed || held == nil {
+		t.Fatalf("first run = %+v, want allowed", first)
+	}
+	if second, r := guard.Reserve(apiKeyID(""), 6); second.Action != budgetRefused || r != nil {
+		t.Fatalf("second run = %+v, want refused while the first holds $6", second)
+	}
+
+	// Settling swaps the estimate for the actual cost
+	ledger.Record("dataset", apiKeyID(""), UsageSummary{CostUSD: 3}, held)
+	held.Release()
+	if d := guard.Check(apiKeyID(""), 7); d.Action != budgetAllowed {
+		t.Fatalf("after settling at $3, a $7 run = %+v, want allowed", d)
+	}
+
+	// A failed run gives its reservation back
+	_, failed := guard.Reserve(apiKeyID(""), 7)
+	failed.Release()
+	if d := guard.Check(apiKeyID(""), 7); d.Action != budgetAllowed {
+		t.Fatalf("after a release, a $7 run = %+v, want allowed", d)
+	}
+}
+
+// blockingAnalyzer holds every run in sentiment analysis until released
+type blockingAnalyzer struct {
+	budgetStubAnalyzer
+	release chan struct{}
+}
+
+func (a *blockingAnalyzer) AnalyzeSentiments(reviews []Review) ([]SentimentResult, error) {
+	<-a.release
+	return a.budgetStubAnalyzer.AnalyzeSentiments(reviews)
+}
+
+func TestConcurrentAnalysesShareTheDailyBudget(t *testing.T) {
+	// Each run is estimated at $30 and the day allows $45, so while one run
+	// is in progress every other must be refused
+	ledger, _ := NewUsageLedger("", PriceTable{"stub": {PromptPerMillion: 1e6}})
+	analyzer := &blockingAnalyzer{budgetStubAnalyzer{fixedUSD: 20}, make(chan struct{})}
+	service := NewAnalysisService(analyzer, AnalysisDependencies{Usage: ledger, Budget: NewBudgetGuard(BudgetConfig{PerDayUSD: 45}, ledger)})
+
+	var wg sync.WaitGroup
+	var succeeded, refused int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Analyze(budgetTestReviews("pre", 5), budgetTestReviews("post", 5), AnalysisOptions{})
+			var budgetErr *BudgetExceededError
+			switch {
+			case err == nil:
+				atomic.AddInt32(&succeeded, 1)
+			case errors.As(err, &budgetErr):
+				atomic.AddInt32(&refused, 1)
+			default:
+				t.Errorf("Analyze: %v", err)
+			}
+		}()
+	}
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&refused) < 7 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	close(analyzer.release)
+	wg.Wait()
+	if succeeded != 1 || refused != 7 {
+		t.Fatalf("%d runs succeeded and %d were refused, want 1 and 7", succeeded, refused)
+	}
+}
+
+func TestAnalyzeRefusesUnpricedRunsUnderABudget(t *testing.T) {
+	ledger, _ := NewUsageLedger("", PriceTable{})
+	unpriced := struct{ LLMAnalyzer }{&budgetStubAnalyzer{}}
+	service := NewAnalysisService(unpriced, AnalysisDependencies{Usage: ledger, Budget: NewBudgetGuard(BudgetConfig{PerDayUSD: 1}, ledger)})
+	if _, err := service.Analyze(budgetTestReviews("pre", 2), budgetTestReviews("post", 2), AnalysisOptions{}); !errors.Is(err, ErrBudgetUnpriced) {
+		t.Fatalf("got %v, want ErrBudgetUnpriced", err)
+	}
+
+	service = NewAnalysisService(unpriced, AnalysisDependencies{Usage: ledger, Budget: NewBudgetGuard(BudgetConfig{}, ledger)})
+	if _, err := service.Analyze(budgetTestReviews("pre", 2), budgetTestReviews("post", 2), AnalysisOptions{}); err != nil {
+		t.Fatalf("without limits: %v", err)
+	}
+}
+
+func TestEstimateUsagePricesClusterNaming(t *testing.T) {
+	prompts, err := NewPromptRegistry("")
+	if err != nil {
+		t.Fatalf("NewPromptRegistry: %v", err)
+	}
+	client := NewGroqClient("", GenerationConfig{Model: "stub"}, prompts)
+	pre, post := budgetTestReviews("pre", 60), budgetTestReviews("post", 40)
+	operations := func(plan UsagePlan) map[string]int {
+		calls, err := client.EstimateUsage(plan)
+		if err != nil {
+			t.Fatalf("EstimateUsage: %v", err)
+		}
+		ops := make(map[string]int)
+		for _, c := range calls {
+			ops[c.Operation] += c.Usage.PromptTokens
+		}
+		return ops
+	}
+
+	themes := operations(UsagePlan{PreReviews: pre, PostReviews: post, UncachedPre: pre, UncachedPost: post, TopN: 8})
+	clusters := operations(UsagePlan{PreReviews: pre, PostReviews: post, UncachedPre: pre, UncachedPost: post, Clusters: 4, ClusterSamples: 3})
+	if themes["themes"] == 0 || themes["clusters"] != 0 {
+		t.Fatalf("LLM discovery calls = %v", themes)
+	}
+	if clusters["clusters"] == 0 || clusters["themes"] != 0 {
+		t.Fatalf("cluster discovery calls = %v, want a cluster naming call instead of the theme call", clusters)
+	}
+	if clusters["aspects"] >= themes["aspects"] {
+		t.Fatalf("cluster aspects cost %d prompt tokens, want fewer than the %d for every review", clusters["aspects"], themes["aspects"])
+	}
+}
+
+func TestAnalysisPlansClusterNaming(t *testing.T) {
+	analyzer := &clusterStubAnalyzer{}
+	ledger, _ := NewUsageLedger("", PriceTable{})
+	service := NewAnalysisService(analyzer, AnalysisDependencies{
+		Usage:    ledger,
+		Embedder: NewHashingEmbedder(0),
+		Clusters: ClusterConfig{K: 3, Samples: 4},
+		Themes:   ThemeConfig{Discovery: discoveryClusters},
+	})
+	if _, err := service.Estimate(budgetTestReviews("pre", 10), budgetTestReviews("post", 10), AnalysisOptions{}); err != nil {
+		t.Fatalf("Estimate: %v", err)
+	}
+	if analyzer.plan.Clusters != 3 || analyzer.plan.ClusterSamples != 4 {
+		t.Fatalf("plan = %+v, want 3 clusters of 4 representatives", analyzer.plan)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// SentimentCache stores sentiment results so unchanged reviews are not re-sent to the LLM
+type SentimentCache interface {
+	Get(key string) (SentimentResult, bool)
+	Set(key string, result SentimentResult)
+}
+
+// VersionedAnalyzer is implemented by analyzers whose output depends on a model and prompt version
+type VersionedAnalyzer interface {
+	Version() string
+}
+
+// MemorySentimentCache implements SentimentCache in process memory
+type MemorySentimentCache struct {
+	mu      sync.RWMutex
+	entries map[string]SentimentResult
+}
+
+// NewMemorySentimentCache creates an empty in-memory sentiment cache
+func NewMemorySentimentCache() *MemorySentimentCache {
+	return &MemorySentimentCache{
+		entries: make(map[string]SentimentResult),
+	}
+}
+
+// Get returns the cached result for a key
+func (c *MemorySentimentCache) Get(key string) (SentimentResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok := c.entries[key]
+	return result, ok
+}
+
+// Set stores a result under a key
+func (c *MemorySentimentCache) Set(key string, result SentimentResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = result
+}
+
+// sentimentCacheKey hashes the review text together with the analyzer version,
+// so a model or prompt change invalidates every entry
+func sentimentCacheKey(version string, review Review) string {
+	h := sha256.New()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(review.ReviewText))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// analyzerVersion returns the analyzer's version or "unversioned"
+func analyzerVersion(analyzer LLMAnalyzer) string {
+	if v, ok := analyzer.(VersionedAnalyzer); ok {
+		return v.Version()
+	}
+	return "unversioned"
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// Chart colours, matching the dashboard palette
+const (
+	colorPre      = "#8b5cf6"
+	colorPost     = "#6366f1"
+	colorPositive = "#10b981"
+	colorNegative = "#ef4444"
+	colorNeutral  = "#a1a1aa"
+	colorGrid     = "#e4e4e7"
+	colorText     = "#3f3f46"
+)
+
+// barChart is a grouped bar chart; each series has one value per category
+type barChart struct {
+	Title      string
+	Categories []string
+	Series     []chartSeries
+	Unit       string // appended to axis labels, e.g. "%"
+}
+
+type chartSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+// chartShape is a drawing primitive in chart coordinates (origin top left, y
+// down), so the same layout can be rendered to SVG and PDF
+type chartShape struct {
+	Kind   string // rect, line or text
+	X, Y   float64
+	W, H   float64 // rect size, or the line's end point offset
+	Color  string
+	Text   string
+	Size   float64
+	Anchor string // start, middle or end
+}
+
+// sentimentChart compares the sentiment distribution before and after launch
+func sentimentChart(comparison ComparisonResult) barChart {
+	share := func(s SentimentSummary) []float64 {
+		total := float64(sentimentTotal(s))
+		if total == 0 {
+			return []float64{0, 0, 0}
+		}
+		return []float64{
+			round2(float64(s.Positive) / total * 100),
+			round2(float64(s.Negative) / total * 100),
+			round2(float64(s.Neutral) / total * 100),
+		}
+	}
+	return barChart{
+		Title:      "Sentiment distribution (% of reviews)",
+		Categories: []string{"Positive", "Negative", "Neutral"},
+		Series: []chartSeries{
+			{Name: "Pre-launch", Color: colorPre, Values: share(comparison.PreLaunchSentiment)},
+			{Name: "Post-launch", Color: colorPost, Values: share(comparison.PostLaunchSentiment)},
+		},
+		Unit: "%",
+	}
+}
+
+// themeChart compares mention counts for the largest reported themes
+func themeChart(comparison ComparisonResult, limit int) barChart {
+	chart := barChart{
+		Title: "Theme mentions",
+		Series: []chartSeries{
+			{Name: "Pre-launch", Color: colorPre},
+			{Name: "Post-launch", Color: colorPost},
+		},
+	}
+	for i, t := range comparison.Themes {
+		if i >= limit {
+			break
+		}
+		chart.Categories = append(chart.Categories, t.Theme)
+		chart.Series[0].Values = append(chart.Series[0].Values, float64(t.PreCount))
+		chart.Series[1].Values = append(chart.Series[1].Values, float64(t.PostCount))
+	}
+	return chart
+}
+
+// layout positions the chart's bars, gridlines, labels and legend in a w x h box
+func (c barChart) layout(width, height float64) []chartShape {
+	const (
+		top, bottom, left, right = 28.0, 36.0, 40.0, 8.0
+		gridLines                = 4
+	)
+	shapes := []chartShape{{Kind: "text", X: 0, Y: 14, Text: c.Title, Size: 12, Color: colorText}}
+
+	// Legend, right-aligned on the title line
+	x := width - right
+	for i := len(c.Series) - 1; i >= 0; i-- {
+		s := c.Series[i]
+		x -= textWidth(s.Name, 9, false)
+		shapes = append(shapes, chartShape{Kind: "text", X: x, Y: 14, Text: s.Name, Size: 9, Color: colorText})
+		x -= 14
+		shapes = append(shapes, chartShape{Kind: "rect", X: x, Y: 6, W: 10, H: 10, Color: s.Color})
+		x -= 12
+	}
+
+	maxValue := 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	maxValue = niceCeiling(maxValue)
+
+	plotW, plotH := width-left-right, height-top-bottom
+	for i := 0; i <= gridLines; i++ {
+		value := maxValue * float64(i) / gridLines
+		y := top + plotH - plotH*float64(i)/gridLines
+		shapes = append(shapes,
+			chartShape{Kind: "line", X: left, Y: y, W: plotW, Color: colorGrid},
+			chartShape{Kind: "text", X: left - 4, Y: y + 3, Text: formatAxisValue(value) + c.Unit, Size: 8, Color: colorText, Anchor: "end"},
+		)
+	}
+
+	if len(c.Categories) == 0 {
+		return shapes
+	}
+	groupW := plotW / float64(len(c.Categories))
+	barW := groupW * 0.7 / float64(len(c.Series))
+	for i, category := range c.Categories {
+		groupX := left + groupW*float64(i) + groupW*0.15
+		for j, s := range c.Series {
+			if i >= len(s.Values) || maxValue == 0 {
+				continue
+			}
+			h := plotH * s.Values[i] / maxValue
+			shapes = append(shapes, chartShape{Kind: "rect", X: groupX + barW*float64(j), Y: top + plotH - h, W: barW - 1, H: h, Color: s.Color})
+		}
+		label := truncateToWidth(category, 8, groupW-4)
+		shapes = append(shapes, chartShape{Kind: "text", X: left + groupW*(float64(i)+0.5), Y: top + plotH + 14, Text: label, Size: 8, Color: colorText, Anchor: "middle"})
+	}
+	return shapes
+}
+
+// SVG renders the chart as an inline SVG element
+func (c barChart) SVG(width, height float64) template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" width="%.0f" height="%.0f" role="img" aria-label="%s">`,
+		width, height, width, height, template.HTMLEscapeString(c.Title))
+	for _, s := range c.layout(width, height) {
+		switch s.Kind {
+		case "rect":
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, s.X, s.Y, s.W, s.H, s.Color)
+		case "line":
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1"/>`, s.X, s.Y, s.X+s.W, s.Y+s.H, s.Color)
+		case "text":
+			anchor := s.Anchor
+			if anchor == "" {
+				anchor = "start"
+			}
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="%.0f" fill="%s" text-anchor="%s" font-family="Helvetica, Arial, sans-serif">%s</text>`,
+				s.X, s.Y, s.Size, s.Color, anchor, template.HTMLEscapeString(s.Text))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// niceCeiling rounds an axis maximum up to 1, 2 or 5 times a power of ten
+func niceCeiling(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// formatAxisValue prints whole numbers without decimals
+func formatAxisValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// truncateToWidth shortens a label with an ellipsis to fit maxWidth points
+func truncateToWidth(s string, size, maxWidth float64) string {
+	if textWidth(s, size, false) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 1 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "..."; textWidth(candidate, size, false) <= maxWidth {
+			return candidate
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// CLI exit codes
+const (
+	exitOK        = 0
+	exitFailure   = 1 // the command could not complete
+	exitUsage     = 2 // invalid arguments
+	exitThreshold = 3 // the analysis completed but breached a threshold
+)
+
+// cliUsage lists the subcommands
+const cliUsage = `Usage: analyzer <command> [flags]
+
+Commands:
+  serve     start the HTTP API (the default when no command is given)
+  run       analyze two review CSVs and write the result as JSON
+  validate  parse review CSVs and report problems without calling the LLM
+  export    write tables or a report from a saved result
+  check     evaluate a saved result against a regression policy
+  sync      pull new reviews from a configured connector
+  evaluate  score analyzer configurations against a labeled dataset
+
+Run "analyzer <command> -h" for the flags of a command.
+Exit codes: 0 success, 1 failure, 2 invalid usage, 3 threshold breached.
+`
+
+// runCLI dispatches a subcommand and returns the process exit code
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		return runServe(nil)
+	}
+	command, rest := args[0], args[1:]
+	switch command {
+	case "serve":
+		return runServe(rest)
+	case "run":
+		return runAnalysis(rest)
+	case "validate":
+		return runValidate(rest)
+	case "export":
+		return runExport(rest)
+	case "check":
+		return runCheck(rest)
+	case "sync":
+		return runSync(rest)
+	case "evaluate":
+		return runEvaluate(rest)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, cliUsage)
+		return exitOK
+	default:
+		// Flags without a command keep starting the server, as before subcommands existed
+		if strings.HasPrefix(command, "-") {
+			return runServe(args)
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, cliUsage)
+		return exitUsage
+	}
+}
+
+// runServe starts the HTTP API
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.Int("port", getPort(), "port to listen on (default from PORT)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	stack, err := newAnalysisStack()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+		return exitFailure
+	}
+	stack.prompts.Watch(5 * time.Second)
+	policy, err := LoadPolicy(getEnv("POLICY_FILE", ""))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+		return exitFailure
+	}
+
+	jobs, err := NewJobScheduler(statePath("jobs.json"), statePath("job_runs.json"), statePath("job_results"), LoadJobConfig(), stack.service, NewCSVReviewParser(), policy, stack.webhooks, stack.connectors)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve: failed to load scheduled jobs: %v\n", err)
+		return exitFailure
+	}
+	jobs.Start()
+
+	apiHandler := NewAPIHandler(NewCSVReviewParser(), stack.service, HandlerDependencies{
+		Prompts:    stack.prompts,
+		Usage:      stack.usage,
+		Taxonomy:   stack.taxonomy,
+		Overrides:  stack.overrides,
+		Reports:    NewReportTemplates(getEnv("REPORT_TEMPLATE_DIR", "")),
+		Policy:     policy,
+		Webhooks:   stack.webhooks,
+		Jobs:       jobs,
+		Connectors: stack.connectors,
+	})
+
+	// Create and start server
+	server := NewServer(apiHandler, *port)
+	if err := server.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "serve: failed to start server: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runThresholds are the CI gates checked after `run`
+type runThresholds struct {
+	MinScore       float64
+	RequireSuccess bool
+	FailOnIssue    string // fail when a new issue is at or above this level
+}
+
+// severityRank orders emerging-issue levels
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// check returns a description of every breached threshold
+func (t runThresholds) check(result *AnalysisResult) []string {
+	var breaches []string
+	if result.Impact.SuccessScore < t.MinScore {
+		breaches = append(breaches, fmt.Sprintf("success score %.1f is below the minimum %.1f", result.Impact.SuccessScore, t.MinScore))
+	}
+	if t.RequireSuccess && !result.Impact.OverallSuccess {
+		breaches = append(breaches, "the launch was not judged successful")
+	}
+	if rank := severityRank[t.FailOnIssue]; rank > 0 {
+		for _, issue := range result.NewIssues {
+			if severityRank[issue.Level] >= rank {
+				breaches = append(breaches, fmt.Sprintf("new %s issue: %s (severity %.0f)", issue.Level, issue.Theme, issue.Severity))
+			}
+		}
+	}
+	return breaches
+}
+
+// runAnalysis analyzes two review files, e.g. from scripts and cron jobs
+func runAnalysis(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	pre := fs.String("pre", "", "pre-launch reviews CSV")
+	post := fs.String("post", "", "post-launch reviews CSV")
+	out := fs.String("out", "-", "file to write the result JSON to (- for stdout)")
+	discovery := fs.String("discovery", "", "theme discovery: llm or clusters (default from THEME_DISCOVERY)")
+	topN := fs.Int("top-n", 0, "themes to report (default from THEME_TOP_N)")
+	var thresholds runThresholds
+	fs.Float64Var(&thresholds.MinScore, "min-score", 0, "exit 3 if the success score is below this")
+	fs.BoolVar(&thresholds.RequireSuccess, "require-success", false, "exit 3 unless the launch is judged successful")
+	fs.StringVar(&thresholds.FailOnIssue, "fail-on-issue", "", "exit 3 if a new issue reaches this level: low, medium, high or critical")
+	policyPath := fs.String("policy", getEnv("POLICY_FILE", ""), "YAML or JSON regression policy; exit 3 when it fails (default from POLICY_FILE)")
+	failOnWarn := fs.Bool("fail-on-warn", false, "with --policy, also exit 3 when the policy warns")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *pre == "" || *post == "" {
+		fmt.Fprintln(os.Stderr, "run: --pre and --post are required")
+		return exitUsage
+	}
+	if thresholds.FailOnIssue != "" && severityRank[thresholds.FailOnIssue] == 0 {
+		fmt.Fprintln(os.Stderr, "run: --fail-on-issue must be low, medium, high or critical")
+		return exitUsage
+	}
+
+	var policy *Policy
+	if *policyPath != "" {
+		var err error
+		if policy, err = LoadPolicy(*policyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "run: %v\n", err)
+			return policyLoadExitCode(err)
+		}
+	}
+
+	parser := NewCSVReviewParser()
+	preReviews, err := parseReviewFile(parser, *pre)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitFailure
+	}
+	postReviews, err := parseReviewFile(parser, *post)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitFailure
+	}
+
+	stack, err := newAnalysisStack()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitFailure
+	}
+
+	opts := AnalysisOptions{APIKey: os.Getenv("ANALYZER_API_KEY")}
+	if *discovery != "" || *topN > 0 {
+		config := LoadThemeConfig()
+		if *discovery != "" {
+			config.Discovery = *discovery
+		}
+		if *topN > 0 {
+			config.TopN = *topN
+		}
+		opts.Themes = &config
+	}
+
+	// Deliveries run in the background; wait for them before the process exits
+	defer stack.webhooks.Wait()
+
+	result, err := stack.service.Analyze(preReviews, postReviews, opts)
+	if err != nil {
+		stack.webhooks.NotifyFailure(err, len(preReviews), len(postReviews), "cli")
+		var budgetErr *BudgetExceededError
+		if errors.As(err, &budgetErr) {
+			fmt.Fprintf(os.Stderr, "run: analysis exceeds budget: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "run: analysis failed: %v\n", err)
+		}
+		return exitFailure
+	}
+	if policy != nil {
+		policyResult := policy.Evaluate(result)
+		result.Policy = &policyResult
+	}
+	stack.webhooks.NotifyResult(result, "cli")
+
+	if err := writeOutput(*out, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(os.Stderr, "Analyzed %d pre-launch and %d post-launch reviews: score %.1f, sentiment shift %+.1f, %d new issues\n",
+		len(preReviews), len(postReviews), result.Impact.SuccessScore, result.Comparison.SentimentShift, len(result.NewIssues))
+	code := exitOK
+	if breaches := thresholds.check(result); len(breaches) > 0 {
+		for _, b := range breaches {
+			fmt.Fprintf(os.Stderr, "threshold breached: %s\n", b)
+		}
+		code = exitThreshold
+	}
+	if result.Policy != nil && policyExitCode(*result.Policy, *failOnWarn) != exitOK {
+		code = exitThreshold
+	}
+	return code
+}
+
+// runCheck evaluates a saved result against a policy, for release pipelines
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	resultPath := fs.String("result", "", "result JSON written by run")
+	policyPath := fs.String("policy", getEnv("POLICY_FILE", ""), "YAML or JSON regression policy (default from POLICY_FILE, else the built-in policy)")
+	failOnWarn := fs.Bool("fail-on-warn", false, "also exit 3 when the policy warns")
+	asJSON := fs.Bool("json", false, "print the policy result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *resultPath == "" {
+		fmt.Fprintln(os.Stderr, "check: --result is required")
+		return exitUsage
+	}
+
+	policy, err := LoadPolicy(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return policyLoadExitCode(err)
+	}
+	result, err := readResultFile(*resultPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return exitFailure
+	}
+
+	outcome := policy.Evaluate(result)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(outcome)
+	}
+	return policyExitCode(outcome, *failOnWarn)
+}
+
+// runSync pulls reviews from a connector saved through /api/connectors and
+// optionally writes everything synced so far as a review CSV
+func runSync(args []string) int {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	name := fs.String("connector", "", "connector name")
+	full := fs.Bool("full", false, "ignore the saved cursor and fetch everything again")
+	out := fs.String("out", "", "also write the connector's reviews as CSV to this file")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "sync: --connector is required")
+		return exitUsage
+	}
+
+	store, err := NewConnectorStore(statePath("connectors.json"), statePath("connector_reviews"), time.Duration(getEnvInt("CONNECTOR_TIMEOUT_SECONDS", 60))*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return exitFailure
+	}
+	report, err := store.Sync(*name, *full)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stderr, "%s: fetched %d, added %d, updated %d, %d total\n", report.Name, report.Fetched, report.Added, report.Updated, report.Total)
+
+	if *out != "" {
+		reviews, err := store.Reviews(*name)
+		if err == nil {
+			err = writeReviewsCSV(*out, reviews)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// policyExitCode reports each triggered rule on stderr and maps the status to an exit code
+func policyExitCode(outcome PolicyResult, failOnWarn bool) int {
+	for _, rule := range outcome.Rules {
+		if rule.Status != policyPass {
+			fmt.Fprintf(os.Stderr, "policy %s: %s: %s\n", rule.Status, rule.Rule, rule.Reason)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "policy %s: %s\n", outcome.Policy, outcome.Status)
+	if outcome.Status == policyFail || (failOnWarn && outcome.Status == policyWarn) {
+		return exitThreshold
+	}
+	return exitOK
+}
+
+// policyLoadExitCode is exitFailure when the policy file could not be read
+// and exitUsage when it was read but is not a valid policy
+func policyLoadExitCode(err error) int {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return exitFailure
+	}
+	return exitUsage
+}
+
+// runValidate parses review files and reports data problems; --strict also fails on warnings
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	pre := fs.String("pre", "", "pre-launch reviews CSV")
+	post := fs.String("post", "", "post-launch reviews CSV")
+	strict := fs.Bool("strict", false, "exit 1 on warnings as well as errors")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	files := append([]string{}, fs.Args()...)
+	for _, path := range []string{*pre, *post} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "validate: pass --pre, --post or CSV paths")
+		return exitUsage
+	}
+
+	parser := NewCSVReviewParser()
+	code := exitOK
+	for _, path := range files {
+		reviews, err := parseReviewFile(parser, path)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		problems, warnings := validateReviews(reviews)
+		status := "✓"
+		if len(problems) > 0 || (*strict && len(warnings) > 0) {
+			status = "✗"
+			code = exitFailure
+		}
+		fmt.Printf("%s %s: %d reviews\n", status, path, len(reviews))
+		for _, p := range problems {
+			fmt.Printf("    error: %s\n", p)
+		}
+		for _, w := range warnings {
+			fmt.Printf("    warning: %s\n", w)
+		}
+	}
+	return code
+}
+
+// validateReviews checks parsed reviews for problems that break an analysis
+// (errors) and ones that only degrade it (warnings)
+func validateReviews(reviews []Review) (problems, warnings []string) {
+	if len(reviews) == 0 {
+		return []string{"no reviews"}, nil
+	}
+	seen := make(map[string]bool)
+	var missingID, emptyText, badRating, badDate int
+	var duplicates []string
+	for _, r := range reviews {
+		switch {
+		case r.ID == "":
+			missingID++
+		case seen[r.ID]:
+			duplicates = append(duplicates, r.ID)
+		}
+		seen[r.ID] = true
+		if strings.TrimSpace(r.ReviewText) == "" {
+			emptyText++
+		}
+		if r.Rating < 1 || r.Rating > 5 {
+			badRating++
+		}
+		if len(r.Date) < 10 {
+			badDate++
+		} else if _, err := time.Parse("2006-01-02", r.Date[:10]); err != nil {
+			badDate++
+		}
+	}
+
+	if emptyText == len(reviews) {
+		problems = append(problems, "every review_text is empty; is the column missing?")
+	} else if emptyText > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d reviews have no text", emptyText))
+	}
+	if missingID > 0 {
+		problems = append(problems, fmt.Sprintf("%d reviews have no id", missingID))
+	}
+	if len(duplicates) > 0 {
+		problems = append(problems, fmt.Sprintf("%d duplicate ids, e.g. %q", len(duplicates), duplicates[0]))
+	}
+	if badRating > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d ratings are missing or outside 1-5", badRating))
+	}
+	if badDate > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d dates are missing or not YYYY-MM-DD", badDate))
+	}
+	return problems, warnings
+}
+
+// runExport writes a table or report from a result saved by `run`
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	resultPath := fs.String("result", "", "result JSON written by run")
+	table := fs.String("table", "", "table to export: reviews or themes")
+	format := fs.String("format", exportCSV, "table format: csv, xlsx or parquet")
+	report := fs.String("report", "", "report to render instead of a table: html, pdf or markdown")
+	templateName := fs.String("template", "", "Markdown report template name")
+	out := fs.String("out", "-", "file to write to (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *resultPath == "" || (*table == "") == (*report == "") {
+		fmt.Fprintln(os.Stderr, "export: --result and exactly one of --table or --report are required")
+		return exitUsage
+	}
+
+	result, err := readResultFile(*resultPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return exitFailure
+	}
+
+	var render func(io.Writer) error
+	switch *report {
+	case "":
+		render = func(w io.Writer) error { return ExportTable(result, *table, *format, w) }
+	case reportHTML:
+		render = func(w io.Writer) error { return RenderHTMLReport(result, w) }
+	case reportPDF:
+		render = func(w io.Writer) error { return RenderPDFReport(result, w) }
+	case reportMarkdown, "md":
+		tmpl, err := NewReportTemplates(getEnv("REPORT_TEMPLATE_DIR", "")).Markdown(*templateName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return exitUsage
+		}
+		render = func(w io.Writer) error { return RenderMarkdownReport(result, tmpl, w) }
+	default:
+		fmt.Fprintln(os.Stderr, "export: --report must be html, pdf or markdown")
+		return exitUsage
+	}
+
+	if err := writeOutput(*out, render); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// readResultFile loads an analysis result saved by run
+func readResultFile(path string) (*AnalysisResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result AnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// parseReviewFile reads a review CSV with the server's parser
+func parseReviewFile(parser ReviewParser, path string) ([]Review, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reviews, err := parser.ParseCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return reviews, nil
+}
+
+// writeOutput writes to a file, or to stdout for "-"; a partly written file is removed on error
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" || path == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
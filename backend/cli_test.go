@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReviews(t *testing.T) {
+	good := Review{ID: "r1", Date: "2024-03-01", ReviewText: "Works well", Rating: 5}
+	with := func(change func(*Review)) Review {
+		r := good
+		change(&r)
+		return r
+	}
+	cases := []struct {
+		name     string
+		reviews  []Review
+		problems string
+		warnings string
+	}{
+		{"clean", []Review{good}, "", ""},
+		{"empty file", nil, "no reviews", ""},
+		{"no text at all", []Review{with(func(r *Review) { r.ReviewText = " " })}, "every review_text is empty; is the column missing?", ""},
+		{"some text missing", []Review{good, with(func(r *Review) { r.ID, r.ReviewText = "r2", "" })}, "", "1 reviews have no text"},
+		{"missing ids", []Review{with(func(r *Review) { r.ID = "" }), with(func(r *Review) { r.ID = "" })}, "2 reviews have no id", ""},
+		{"duplicate ids", []Review{good, good, with(func(r *Review) { r.ID = "r2" })}, `1 duplicate ids, e.g. "r1"`, ""},
+		{"ratings", []Review{with(func(r *Review) { r.Rating = 0 }), with(func(r *Review) { r.ID, r.Rating = "r2", 6 })}, "", "2 ratings are missing or outside 1-5"},
+		{"dates", []Review{
+			with(func(r *Review) { r.Date = "" }),
+			with(func(r *Review) { r.ID, r.Date = "r2", "03/01/2024" }),
+			with(func(r *Review) { r.ID, r.Date = "r3", "2024-03-01T10:00:00Z" }),
+		}, "", "2 dates are missing or not YYYY-MM-DD"},
+	}
+	for _, c := range cases {
+		problems, warnings := validateReviews(c.reviews)
+		if got := strings.Join(problems, "; "); got != c.problems {
+			t.Errorf("%s: problems = %q, want %q", c.name, got, c.problems)
+		}
+		if got := strings.Join(warnings, "; "); got != c.warnings {
+			t.Errorf("%s: warnings = %q, want %q", c.name, got, c.warnings)
+		}
+	}
+}
+
+func TestRunThresholdsCheck(t *testing.T) {
+	result := &AnalysisResult{
+		Impact: ImpactSummary{SuccessScore: 55, OverallSuccess: false},
+		NewIssues: []EmergingIssue{
+			{Theme: "Login loop", Level: "high", Severity: 72},
+			{Theme: "Dark mode", Level: "low", Severity: 12},
+		},
+	}
+	cases := []struct {
+		name       string
+		thresholds runThresholds
+		breaches   string
+	}{
+		{"no gates", runThresholds{}, ""},
+		{"score at the minimum", runThresholds{MinScore: 55}, ""},
+		{"score below the minimum", runThresholds{MinScore: 60}, "success score 55.0 is below the minimum 60.0"},
+		{"success required", runThresholds{RequireSuccess: true}, "the launch was not judged successful"},
+		{"issue level reached", runThresholds{FailOnIssue: "high"}, "new high issue: Login loop (severity 72)"},
+		{"issue levels at or above", runThresholds{FailOnIssue: "low"}, "new high issue: Login loop (severity 72); new low issue: Dark mode (severity 12)"},
+		{"issue level not reached", runThresholds{FailOnIssue: "critical"}, ""},
+		{"every gate", runThresholds{MinScore: 60, RequireSuccess: true, FailOnIssue: "medium"},
+			"success score 55.0 is below the minimum 60.0; the launch was not judged successful; new high issue: Login loop (severity 72)"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(c.thresholds.check(result), "; "); got != c.breaches {
+			t.Errorf("%s: breaches = %q, want %q", c.name, got, c.breaches)
+		}
+	}
+}
+
+func TestRunCheckPolicyExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	resultPath := filepath.Join(dir, "result.json")
+	data, _ := json.Marshal(AnalysisResult{})
+	if err := os.WriteFile(resultPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("rules:\n  - when: happiness < 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	passing := filepath.Join(dir, "passing.yaml")
+	if err := os.WriteFile(passing, []byte("rules:\n  - when: new_issues > 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"unreadable policy", []string{"--result", resultPath, "--policy", filepath.Join(dir, "missing.yaml")}, exitFailure},
+		{"policy is a directory", []string{"--result", resultPath, "--policy", dir}, exitFailure},
+		{"invalid policy", []string{"--result", resultPath, "--policy", invalid}, exitUsage},
+		{"missing result", []string{"--policy", passing}, exitUsage},
+		{"unreadable result", []string{"--result", filepath.Join(dir, "missing.json"), "--policy", passing}, exitFailure},
+		{"policy passes", []string{"--result", resultPath, "--policy", passing}, exitOK},
+	}
+	for _, c := range cases {
+		if got := runCheck(c.args); got != c.want {
+			t.Errorf("%s: exit %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Theme discovery modes
+const (
+	discoveryLLM      = "llm"      // one LLM call over every review
+	discoveryClusters = "clusters" // embed and cluster locally, LLM only names clusters
+)
+
+// ClusterConfig controls embedding-based theme discovery
+type ClusterConfig struct {
+	K              int     `json:"k"`                // clusters to form; 0 picks sqrt(n/2)
+	MinClusterSize int     `json:"min_cluster_size"` // smaller clusters are treated as noise
+	MinSimilarity  float64 `json:"min_similarity"`   // reviews less similar to their centroid are noise
+	Samples        int     `json:"samples"`          // representative reviews sent to the LLM per cluster
+	Seed           int64   `json:"seed"`
+}
+
+// LoadClusterConfig reads clustering settings from the environment
+func LoadClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		K:              getEnvInt("CLUSTER_K", 0),
+		MinClusterSize: getEnvInt("CLUSTER_MIN_SIZE", 3),
+		MinSimilarity:  getEnvFloat("CLUSTER_MIN_SIMILARITY", 0.1),
+		Samples:        getEnvInt("CLUSTER_SAMPLES", 5),
+		Seed:           int64(getEnvInt("CLUSTER_SEED", 1)),
+	}
+}
+
+// ClusterNamer is implemented by analyzers that can name review clusters
+type ClusterNamer interface {
+	NameClusters(clusters []ReviewCluster, taxonomy []TaxonomyTheme) ([]ClusterName, error)
+}
+
+// ReviewCluster is a group of semantically similar reviews
+type ReviewCluster struct {
+	ID        int     `json:"id"`
+	Theme     string  `json:"theme"`
+	Size      int     `json:"size"`
+	PreCount  int     `json:"pre_count"`
+	PostCount int     `json:"post_count"`
+	Cohesion  float64 `json:"cohesion"` // mean similarity of members to the centroid
+
+	samples []Review // members closest to the centroid
+	preIDs  []string
+	postIDs []string
+}
+
+// ClusterName is the LLM's label for one cluster
+type ClusterName struct {
+	Cluster int    `json:"cluster"`
+	Theme   string `json:"theme"`
+	Parent  string `json:"parent"`
+}
+
+// ClusteringInfo describes the clustering behind a cluster-based analysis
+type ClusteringInfo struct {
+	Embedder    string          `json:"embedder"`
+	K           int             `json:"k"`
+	Clusters    []ReviewCluster `json:"clusters"`
+	Unclustered int             `json:"unclustered"` // reviews treated as noise
+}
+
+// clusterReviews embeds both phases together and groups them with k-means.
+// Like HDBSCAN, it leaves outliers and tiny clusters unassigned instead of
+// forcing every review into a theme
+func clusterReviews(embedder Embedder, preReviews, postReviews []Review, config ClusterConfig) (*ClusteringInfo, error) {
+	type entry struct {
+		review Review
+		phase  string
+	}
+	var entries []entry
+	var texts []string
+	for _, r := range preReviews {
+		entries = append(entries, entry{r, "pre_launch"})
+		texts = append(texts, r.ReviewText)
+	}
+	for _, r := range postReviews {
+		entries = append(entries, entry{r, "post_launch"})
+		texts = append(texts, r.ReviewText)
+	}
+
+	info := &ClusteringInfo{Embedder: embedder.Name(), Clusters: []ReviewCluster{}}
+	if len(entries) == 0 {
+		return info, nil
+	}
+
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed reviews: %w", err)
+	}
+
+	k := clusterCount(len(vectors), config)
+	info.K = k
+
+	assignment, similarity := kMeans(vectors, k, config.Seed)
+
+	members := make([][]int, k)
+	for i, c := range assignment {
+		if similarity[i] < config.MinSimilarity {
+			info.Unclustered++
+			continue
+		}
+		members[c] = append(members[c], i)
+	}
+
+	for _, idx := range members {
+		if len(idx) == 0 {
+			continue
+		}
+		if len(idx) < config.MinClusterSize {
+			info.Unclustered += len(idx)
+			continue
+		}
+
+		sort.SliceStable(idx, func(a, b int) bool { return similarity[idx[a]] > similarity[idx[b]] })
+		cluster := ReviewCluster{ID: len(info.Clusters) + 1, Size: len(idx)}
+		total := 0.0
+		for rank, i := range idx {
+			e := entries[i]
+			total += similarity[i]
+			if rank < config.Samples {
+				cluster.samples = append(cluster.samples, e.review)
+			}
+			if e.phase == "pre_launch" {
+				cluster.PreCount++
+				cluster.preIDs = append(cluster.preIDs, e.review.ID)
+			} else {
+				cluster.PostCount++
+				cluster.postIDs = append(cluster.postIDs, e.review.ID)
+			}
+		}
+		cluster.Cohesion = math.Round(total/float64(len(idx))*1000) / 1000
+		info.Clusters = append(info.Clusters, cluster)
+	}
+	return info, nil
+}
+
+// representatives returns the reviews shown to the LLM when naming the
+// clusters, split by phase
+func (info *ClusteringInfo) representatives() (pre, post []Review) {
+	for _, c := range info.Clusters {
+		preIDs := newIDSet(c.preIDs)
+		for _, r := range c.samples {
+			if preIDs.seen[r.ID] {
+				pre = append(pre, r)
+			} else {
+				post = append(post, r)
+			}
+		}
+	}
+	return pre, post
+}
+
+// clusterCount is the k used for n reviews: the configured K, or sqrt(n/2)
+func clusterCount(n int, config ClusterConfig) int {
+	k := config.K
+	if k <= 0 {
+		k = max(int(math.Round(math.Sqrt(float64(n)/2))), 2)
+	}
+	return min(k, n)
+}
+
+// kMeans clusters unit vectors by cosine similarity with k-means++ seeding. It
+// returns each vector's cluster and its similarity to that cluster's centroid
+func kMeans(vectors [][]float64, k int, seed int64) ([]int, []float64) {
+	const maxIterations = 50
+	rng := rand.New(rand.NewSource(seed))
+
+	// k-means++: pick each next centroid with probability proportional to its distance
+	centroids := [][]float64{append([]float64{}, vectors[rng.Intn(len(vectors))]...)}
+	distances := make([]float64, len(vectors))
+	for len(centroids) < k {
+		total := 0.0
+		for i, v := range vectors {
+			best := math.Inf(1)
+			for _, c := range centroids {
+				best = math.Min(best, 1-dot(v, c))
+			}
+			distances[i] = math.Max(best, 0)
+			total += distances[i]
+		}
+		next := rng.Intn(len(vectors))
+		if total > 0 {
+			target := rng.Float64() * total
+			for i, d := range distances {
+				if target -= d; target <= 0 {
+					next = i
+					break
+				}
+			}
+		}
+		centroids = append(centroids, append([]float64{}, vectors[next]...))
+	}
+
+	assignment := make([]int, len(vectors))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	similarity := make([]float64, len(vectors))
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		changed := false
+		for i, v := range vectors {
+			best, bestSim := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if sim := dot(v, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+			similarity[i] = bestSim
+		}
+		if !changed {
+			break
+		}
+
+		for c := range centroids {
+			sum := make([]float64, len(vectors[0]))
+			n := 0
+			for i, v := range vectors {
+				if assignment[i] != c {
+					continue
+				}
+				n++
+				for d, x := range v {
+					sum[d] += x
+				}
+			}
+			if n > 0 {
+				centroids[c] = normalize(sum)
+			}
+		}
+	}
+	return assignment, similarity
+}
+
+// dot is the cosine similarity of two unit vectors
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		if i < len(b) {
+			sum += a[i] * b[i]
+		}
+	}
+	return sum
+}
+
+// themesFromClusters turns named clusters into theme results, merging clusters
+// that were given the same name
+func themesFromClusters(info *ClusteringInfo, names []ClusterName) []ThemeResult {
+	named := make(map[int]ClusterName, len(names))
+	for _, n := range names {
+		named[n.Cluster] = n
+	}
+
+	themes := []ThemeResult{}
+	position := make(map[string]int)
+	for i, cluster := range info.Clusters {
+		name, ok := named[cluster.ID]
+		if !ok || name.Theme == "" {
+			info.Unclustered += cluster.Size
+			continue
+		}
+		info.Clusters[i].Theme = name.Theme
+
+		key := themeKey(name.Theme)
+		j, ok := position[key]
+		if !ok {
+			j = len(themes)
+			position[key] = j
+			themes = append(themes, ThemeResult{Theme: name.Theme, Parent: name.Parent, Evidence: []Evidence{}})
+		}
+		pre, post := newIDSet(themes[j].PreReviewIDs), newIDSet(themes[j].PostReviewIDs)
+		pre.add(cluster.preIDs)
+		post.add(cluster.postIDs)
+		themes[j].PreReviewIDs, themes[j].PostReviewIDs = pre.ids, post.ids
+		themes[j].PreCount, themes[j].PostCount = len(themes[j].PreReviewIDs), len(themes[j].PostReviewIDs)
+		themes[j].ChangeRate = changeRate(themes[j].PreCount, themes[j].PostCount)
+	}
+	return themes
+}
+
+// NameClusters asks the LLM for a theme name per cluster from its representative reviews
+func (g *GroqClient) NameClusters(clusters []ReviewCluster, taxonomy []TaxonomyTheme) ([]ClusterName, error) {
+	if len(clusters) == 0 {
+		return []ClusterName{}, nil
+	}
+
+	prompt, err := g.renderPrompt(promptClusters, map[string]interface{}{
+		"Clusters": formatClustersForNaming(clusters),
+		"Taxonomy": formatTaxonomyForThemes(taxonomy),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := g.callGroqAPI("clusters", prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	response = cleanJSONResponse(response)
+
+	var names []ClusterName
+	if err := json.Unmarshal([]byte(response), &names); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster names: %w, response: %s", err, response)
+	}
+
+	return names, nil
+}
+
+// formatClustersForNaming lists each cluster's size and representative reviews
+func formatClustersForNaming(clusters []ReviewCluster) string {
+	result := ""
+	for _, c := range clusters {
+		result += fmt.Sprintf("CLUSTER %d (%d reviews):\n", c.ID, c.Size)
+		for _, r := range c.samples {
+			result += fmt.Sprintf("- %s\n", r.ReviewText)
+		}
+		result += "\n"
+	}
+	return result
+}
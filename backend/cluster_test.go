@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// clusterStubAnalyzer names every cluster "Topic N" and records the reviews
+// sent for aspect extraction
+type clusterStubAnalyzer struct {
+	budgetStubAnalyzer
+	aspectReviews int
+	plan          UsagePlan
+}
+
+func (a *clusterStubAnalyzer) EstimateUsage(plan UsagePlan) ([]LLMCall, error) {
+	a.plan = plan
+	return a.budgetStubAnalyzer.EstimateUsage(plan)
+}
+
+func (a *clusterStubAnalyzer) NameClusters(clusters []ReviewCluster, taxonomy []TaxonomyTheme) ([]ClusterName, error) {
+	names := make([]ClusterName, len(clusters))
+	for i, c := range clusters {
+		names[i] = ClusterName{Cluster: c.ID, Theme: fmt.Sprintf("Topic %d", c.ID)}
+	}
+	return names, nil
+}
+
+func (a *clusterStubAnalyzer) ExtractAspects(reviews []Review, themes []string) ([]AspectSentiment, error) {
+	a.aspectReviews += len(reviews)
+	aspects := make([]AspectSentiment, 0, len(reviews))
+	for _, r := range reviews {
+		aspects = append(aspects, AspectSentiment{ReviewID: r.ID, Aspect: themes[0], Sentiment: "negative"})
+	}
+	return aspects, nil
+}
+
+func TestAnalyzeClustersKeepsMembershipCounts(t *testing.T) {
+	var pre, post []Review
+	for i := 0; i < 30; i++ {
+		text := []string{"battery drains overnight", "checkout button crashes"}[i%2]
+		pre = append(pre, Review{ID: fmt.Sprintf("pre%d", i), Date: "2024-01-01", ReviewText: text, Rating: 2})
+		post = append(post, Review{ID: fmt.Sprintf("post%d", i), Date: "2024-03-01", ReviewText: text, Rating: 2})
+	}
+
+	analyzer := &clusterStubAnalyzer{}
+	service := NewAnalysisService(analyzer, AnalysisDependencies{
+		Embedder: NewHashingEmbedder(0),
+		Clusters: ClusterConfig{K: 2, MinClusterSize: 3, Samples: 2, Seed: 1},
+		Themes:   ThemeConfig{Discovery: discoveryClusters},
+	})
+	result, err := service.Analyze(pre, post, AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	clustering := result.Metadata.Clustering
+	if clustering == nil || len(clustering.Clusters) == 0 {
+		t.Fatal("analysis did not cluster")
+	}
+	representatives := 0
+	for _, c := range clustering.Clusters {
+		representatives += min(c.Size, 2)
+	}
+	if analyzer.aspectReviews != representatives {
+		t.Fatalf("aspects read %d reviews, want only the %d cluster representatives", analyzer.aspectReviews, representatives)
+	}
+
+	members := 0
+	for _, theme := range result.Comparison.Themes {
+		members += theme.PreCount + theme.PostCount
+		tallied := theme.PreSentiment.Positive + theme.PreSentiment.Negative + theme.PreSentiment.Neutral +
+			theme.PostSentiment.Positive + theme.PostSentiment.Negative + theme.PostSentiment.Neutral
+		if tallied != theme.PreCount+theme.PostCount {
+			t.Errorf("%s tallies %d of %d members", theme.Theme, tallied, theme.PreCount+theme.PostCount)
+		}
+	}
+	if want := len(pre) + len(post) - clustering.Unclustered; members != want {
+		t.Fatalf("themes count %d members, want the %d clustered reviews", members, want)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// GenerationConfig holds the model and sampling parameters sent with every LLM request
+type GenerationConfig struct {
+	Model          string  `json:"model"`
+	Temperature    float64 `json:"temperature"`
+	Seed           *int    `json:"seed,omitempty"`
+	MaxTokens      int     `json:"max_tokens,omitempty"`
+	ResponseFormat string  `json:"response_format,omitempty"` // "" or "text"; the prompts ask for JSON arrays, which json_object cannot return
+}
+
+// DefaultGenerationConfig returns settings that keep output as repeatable as the provider allows
+func DefaultGenerationConfig() GenerationConfig {
+	seed := 42
+	return GenerationConfig{
+		Model:       "llama-3.3-70b-versatile",
+		Temperature: 0,
+		Seed:        &seed,
+		MaxTokens:   8192,
+	}
+}
+
+// LoadGenerationConfig reads generation settings from the environment, falling back to defaults
+func LoadGenerationConfig() GenerationConfig {
+	config := DefaultGenerationConfig()
+	config.Model = getEnv("GROQ_MODEL", config.Model)
+	config.Temperature = getEnvFloat("GROQ_TEMPERATURE", config.Temperature)
+	config.MaxTokens = getEnvInt("GROQ_MAX_TOKENS", config.MaxTokens)
+	config.ResponseFormat = getEnv("GROQ_RESPONSE_FORMAT", config.ResponseFormat)
+	if config.ResponseFormat != "" && config.ResponseFormat != "text" {
+		log.Printf("⚠️  Unsupported GROQ_RESPONSE_FORMAT '%s': the prompts ask for a top-level JSON array, using plain text", config.ResponseFormat)
+		config.ResponseFormat = ""
+	}
+
+	// GROQ_SEED=none disables seeding entirely
+	if seedStr := getEnv("GROQ_SEED", ""); seedStr == "none" {
+		config.Seed = nil
+	} else if seedStr != "" {
+		seed := getEnvInt("GROQ_SEED", *config.Seed)
+		config.Seed = &seed
+	}
+
+	return config
+}
+
+// fingerprint summarises the sampling parameters that change generated output
+func (c GenerationConfig) fingerprint() string {
+	seed := "none"
+	if c.Seed != nil {
+		seed = strconv.Itoa(*c.Seed)
+	}
+	return fmt.Sprintf("temperature=%g,seed=%s,max_tokens=%d", c.Temperature, seed, c.MaxTokens)
+}
+
+// getEnvInt returns an integer environment variable or a default value
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		log.Printf("Invalid %s value '%s', using default %d", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvFloat returns a float environment variable or a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		log.Printf("Invalid %s value '%s', using default %g", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
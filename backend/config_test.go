@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestLoadGenerationConfigRejectsJSONObject(t *testing.T) {
+	cases := map[string]string{"": "", "text": "text", "json_object": "", "json_schema": ""}
+	for value, want := range cases {
+		t.Setenv("GROQ_RESPONSE_FORMAT", value)
+		if got := LoadGenerationConfig().ResponseFormat; got != want {
+			t.Errorf("GROQ_RESPONSE_FORMAT=%q gave %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestGroqClientVersionTracksGenerationSettings(t *testing.T) {
+	prompts, err := NewPromptRegistry("")
+	if err != nil {
+		t.Fatalf("NewPromptRegistry: %v", err)
+	}
+	base := DefaultGenerationConfig()
+	version := NewGroqClient("", base, prompts).Version()
+
+	seed := 7
+	changes := map[string]func(*GenerationConfig){
+		"model":       func(c *GenerationConfig) { c.Model = "other" },
+		"temperature": func(c *GenerationConfig) { c.Temperature = 0.7 },
+		"seed":        func(c *GenerationConfig) { c.Seed = &seed },
+		"no seed":     func(c *GenerationConfig) { c.Seed = nil },
+		"max_tokens":  func(c *GenerationConfig) { c.MaxTokens = 1024 },
+	}
+	for name, change := range changes {
+		config := base
+		change(&config)
+		if NewGroqClient("", config, prompts).Version() == version {
+			t.Errorf("changing %s kept the sentiment cache version", name)
+		}
+	}
+	if NewGroqClient("", base, prompts).Version() != version {
+		t.Error("the version is not stable for the same settings")
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connector types
+const (
+	connectorZendesk    = "zendesk"
+	connectorIntercom   = "intercom"
+	connectorAppStore   = "appstore"
+	connectorGooglePlay = "googleplay"
+	connectorREST       = "rest"
+)
+
+// Connector limits
+const (
+	connectorMaxPages    = 1000 // pages read in one sync, guarding against pagination loops
+	connectorMaxRetries  = 3    // retries of a rate-limited request
+	connectorMaxRetryAge = time.Minute
+)
+
+// connectorName restricts names to plain file names, since reviews are stored per connector
+var connectorName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Connector pulls reviews from an external API
+type Connector interface {
+	// Sync fetches the reviews created or updated after cursor ("" fetches the
+	// full history) and returns them with the cursor for the next sync
+	Sync(cursor string) ([]Review, string, error)
+}
+
+// ConnectorConfig configures one connector. Credentials may reference the
+// environment variables job sources may use (see secretAllowed) as ${NAME},
+// which keeps them out of the state file
+type ConnectorConfig struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	BaseURL    string            `json:"base_url,omitempty"` // overrides the API host, e.g. for a sandbox
+	Source     string            `json:"source,omitempty"`   // Review.Source for synced reviews; defaults per type
+	Since      string            `json:"since,omitempty"`    // YYYY-MM-DD the first sync starts from, where the API allows it
+	Zendesk    *ZendeskConfig    `json:"zendesk,omitempty"`
+	Intercom   *IntercomConfig   `json:"intercom,omitempty"`
+	AppStore   *AppStoreConfig   `json:"appstore,omitempty"`
+	GooglePlay *GooglePlayConfig `json:"googleplay,omitempty"`
+	REST       *RESTConfig       `json:"rest,omitempty"`
+	CreatedAt  string            `json:"created_at"`
+
+	Cursor      string `json:"cursor,omitempty"` // where the next sync resumes
+	LastSyncAt  string `json:"last_sync_at,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	ReviewCount int    `json:"review_count"`
+}
+
+// defaultConnectorSources match the source labels of uploaded CSVs
+var defaultConnectorSources = map[string]string{
+	connectorZendesk:    "support_ticket",
+	connectorIntercom:   "support_chat",
+	connectorAppStore:   "app_store",
+	connectorGooglePlay: "google_play",
+}
+
+// source returns the Review.Source for the connector's reviews
+func (c ConnectorConfig) source() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	if source, ok := defaultConnectorSources[c.Type]; ok {
+		return source
+	}
+	return c.Name
+}
+
+// sinceUnix returns Since in Unix seconds, 0 for the full history
+func (c ConnectorConfig) sinceUnix() int64 {
+	since, err := time.Parse(launchDateLayout, c.Since)
+	if err != nil {
+		return 0
+	}
+	return since.Unix()
+}
+
+// newConnector builds the connector described by a config
+func newConnector(config ConnectorConfig, client *http.Client) (Connector, error) {
+	switch config.Type {
+	case connectorZendesk:
+		if config.Zendesk == nil {
+			return nil, fmt.Errorf("a zendesk connector needs zendesk settings")
+		}
+		return newZendeskConnector(config, client)
+	case connectorIntercom:
+		if config.Intercom == nil {
+			return nil, fmt.Errorf("an intercom connector needs intercom settings")
+		}
+		return newIntercomConnector(config, client)
+	case connectorAppStore:
+		if config.AppStore == nil {
+			return nil, fmt.Errorf("an appstore connector needs appstore settings")
+		}
+		return newAppStoreConnector(config, client)
+	case connectorGooglePlay:
+		if config.GooglePlay == nil {
+			return nil, fmt.Errorf("a googleplay connector needs googleplay settings")
+		}
+		return newGooglePlayConnector(config, client)
+	case connectorREST:
+		if config.REST == nil {
+			return nil, fmt.Errorf("a rest connector needs rest settings")
+		}
+		return newRESTConnector(config, client)
+	}
+	return nil, fmt.Errorf("connector type must be %s, %s, %s, %s or %s",
+		connectorZendesk, connectorIntercom, connectorAppStore, connectorGooglePlay, connectorREST)
+}
+
+// SyncReport summarises one sync
+type SyncReport struct {
+	Name       string `json:"name"`
+	Fetched    int    `json:"fetched"` // reviews returned by the API
+	Added      int    `json:"added"`
+	Updated    int    `json:"updated"`
+	Total      int    `json:"total"` // reviews stored for the connector
+	Cursor     string `json:"cursor"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// ConnectorStore keeps connector configs and the reviews each one has synced.
+// Syncs are incremental: a review synced again replaces the stored copy
+type ConnectorStore struct {
+	mu         sync.Mutex
+	path       string
+	reviewsDir string
+	client     *http.Client
+	connectors []ConnectorConfig
+	reviews    map[string][]Review // by connector name, loaded on first use
+	syncing    map[string]bool
+}
+
+// NewConnectorStore loads configs from path and keeps synced reviews as
+// <reviewsDir>/<name>.json ("" keeps either in memory)
+func NewConnectorStore(path, reviewsDir string, timeout time.Duration) (*ConnectorStore, error) {
+	s := &ConnectorStore{
+		path:       path,
+		reviewsDir: reviewsDir,
+		client:     &http.Client{Timeout: timeout},
+		reviews:    make(map[string][]Review),
+		syncing:    make(map[string]bool),
+	}
+	if err := loadJSONFile(path, &s.connectors); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the connectors with literal credentials removed
+func (s *ConnectorStore) List() []ConnectorConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := make([]ConnectorConfig, len(s.connectors))
+	for i, c := range s.connectors {
+		configs[i] = c.redacted()
+	}
+	return configs
+}
+
+// Upsert validates and stores a connector, replacing the one with the same
+// name. Empty credentials keep the stored values, so listed configs can be
+// posted back; changing the type or API restarts the sync from scratch
+func (s *ConnectorStore) Upsert(config ConnectorConfig) (ConnectorConfig, error) {
+	if !connectorName.MatchString(config.Name) {
+		return config, fmt.Errorf("name must contain only letters, digits, - and _")
+	}
+	if config.Since != "" {
+		if _, err := time.Parse(launchDateLayout, config.Since); err != nil {
+			return config, fmt.Errorf("since must be YYYY-MM-DD")
+		}
+	}
+	if err := config.checkSecrets(); err != nil {
+		return config, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := -1
+	for i, existing := range s.connectors {
+		if existing.Name == config.Name {
+			index = i
+		}
+	}
+	config.Cursor, config.LastSyncAt, config.LastError, config.ReviewCount = "", "", "", 0
+	config.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	if index >= 0 {
+		previous := s.connectors[index]
+		config.CreatedAt = previous.CreatedAt
+		// Stored credentials only carry over while the connector talks to the
+		// same API, so a caller cannot redirect them to another host
+		if config.Type == previous.Type && config.endpoint() == previous.endpoint() {
+			config.keepCredentials(previous)
+			config.Cursor, config.LastSyncAt, config.LastError, config.ReviewCount = previous.Cursor, previous.LastSyncAt, previous.LastError, previous.ReviewCount
+		}
+	}
+	if _, err := newConnector(config, s.client); err != nil {
+		return config, err
+	}
+
+	if index >= 0 {
+		s.connectors[index] = config
+	} else {
+		s.connectors = append(s.connectors, config)
+	}
+	if config.Cursor == "" {
+		s.reviews[config.Name] = nil
+		if err := saveJSONFile(s.reviewsPath(config.Name), []Review{}); err != nil {
+			return config, err
+		}
+	}
+	return config.redacted(), saveJSONFile(s.path, s.connectors)
+}
+
+// Delete removes a connector and its synced reviews
+func (s *ConnectorStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.connectors {
+		if c.Name == name {
+			s.connectors = append(s.connectors[:i], s.connectors[i+1:]...)
+			delete(s.reviews, name)
+			if path := s.reviewsPath(name); path != "" {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to delete reviews of connector %q: %w", name, err)
+				}
+			}
+			return saveJSONFile(s.path, s.connectors)
+		}
+	}
+	return fmt.Errorf("connector %q not found", name)
+}
+
+// Reviews returns every review a connector has synced, oldest first
+func (s *ConnectorStore) Reviews(name string) ([]Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(name) < 0 {
+		return nil, fmt.Errorf("connector %q not found", name)
+	}
+	reviews, err := s.loadReviews(name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Review{}, reviews...), nil
+}
+
+// Sync fetches new and updated reviews; full ignores the stored cursor
+func (s *ConnectorStore) Sync(name string, full bool) (SyncReport, error) {
+	start := time.Now()
+	report := SyncReport{Name: name}
+
+	s.mu.Lock()
+	index := s.find(name)
+	if index < 0 {
+		s.mu.Unlock()
+		return report, fmt.Errorf("connector %q not found", name)
+	}
+	if s.syncing[name] {
+		s.mu.Unlock()
+		return report, fmt.Errorf("connector %q is already syncing", name)
+	}
+	config := s.connectors[index]
+	s.syncing[name] = true
+	s.mu.Unlock()
+
+	cursor := config.Cursor
+	if full {
+		cursor = ""
+	}
+	var fetched []Review
+	connector, err := newConnector(config, s.client)
+	if err == nil {
+		fetched, cursor, err = connector.Sync(cursor)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.syncing, name)
+	index = s.find(name)
+	if index < 0 {
+		return report, fmt.Errorf("connector %q was deleted during the sync", name)
+	}
+	c := &s.connectors[index]
+	c.LastSyncAt = time.Now().UTC().Format(time.RFC3339)
+	if err != nil {
+		c.LastError = err.Error()
+		log.Printf("⚠️  Connector %s sync failed: %v", name, err)
+		if saveErr := saveJSONFile(s.path, s.connectors); saveErr != nil {
+			log.Printf("⚠️  Failed to save connectors: %v", saveErr)
+		}
+		return report, err
+	}
+
+	stored, err := s.loadReviews(name)
+	if err != nil {
+		return report, err
+	}
+	if full {
+		stored = nil
+	}
+	byID := make(map[string]int, len(stored))
+	for i, r := range stored {
+		byID[r.ID] = i
+	}
+	for _, r := range fetched {
+		if i, ok := byID[r.ID]; ok {
+			stored[i] = r
+			report.Updated++
+			continue
+		}
+		byID[r.ID] = len(stored)
+		stored = append(stored, r)
+		report.Added++
+	}
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].Date < stored[j].Date })
+	s.reviews[name] = stored
+	if err := saveJSONFile(s.reviewsPath(name), stored); err != nil {
+		return report, err
+	}
+
+	c.Cursor, c.LastError, c.ReviewCount = cursor, "", len(stored)
+	report.Fetched, report.Total, report.Cursor = len(fetched), len(stored), cursor
+	report.DurationMS = time.Since(start).Milliseconds()
+	return report, saveJSONFile(s.path, s.connectors)
+}
+
+// find returns the index of a connector; the caller holds s.mu
+func (s *ConnectorStore) find(name string) int {
+	for i, c := range s.connectors {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadReviews returns a connector's stored reviews; the caller holds s.mu
+func (s *ConnectorStore) loadReviews(name string) ([]Review, error) {
+	if reviews, ok := s.reviews[name]; ok {
+		return reviews, nil
+	}
+	var reviews []Review
+	if err := loadJSONFile(s.reviewsPath(name), &reviews); err != nil {
+		return nil, err
+	}
+	s.reviews[name] = reviews
+	return reviews, nil
+}
+
+// reviewsPath is where a connector's reviews are stored
+func (s *ConnectorStore) reviewsPath(name string) string {
+	if s.reviewsDir == "" {
+		return ""
+	}
+	return filepath.Join(s.reviewsDir, name+".json")
+}
+
+// endpoint identifies the API a config talks to
+func (c ConnectorConfig) endpoint() string {
+	switch {
+	case c.Type == connectorZendesk && c.Zendesk != nil:
+		return c.BaseURL + " " + c.Zendesk.Subdomain
+	case c.Type == connectorREST && c.REST != nil:
+		return c.REST.URL
+	}
+	return c.BaseURL
+}
+
+// checkSecrets rejects ${NAME} references outside the allowlist in any field
+// that is expanded from the environment
+func (c ConnectorConfig) checkSecrets() error {
+	fields := map[string]string{"base_url": c.BaseURL}
+	switch {
+	case c.Type == connectorZendesk && c.Zendesk != nil:
+		fields["email"] = c.Zendesk.Email
+		fields["api_token"] = c.Zendesk.APIToken
+	case c.Type == connectorIntercom && c.Intercom != nil:
+		fields["access_token"] = c.Intercom.AccessToken
+	case c.Type == connectorAppStore && c.AppStore != nil:
+		fields["app_id"] = c.AppStore.AppID
+		fields["issuer_id"] = c.AppStore.IssuerID
+		fields["key_id"] = c.AppStore.KeyID
+		fields["private_key"] = c.AppStore.PrivateKey
+	case c.Type == connectorGooglePlay && c.GooglePlay != nil:
+		fields["package_name"] = c.GooglePlay.PackageName
+		fields["service_account"] = c.GooglePlay.ServiceAccount
+		fields["access_token"] = c.GooglePlay.AccessToken
+	case c.Type == connectorREST && c.REST != nil:
+		fields["url"] = c.REST.URL
+		for key, value := range c.REST.Headers {
+			fields["header "+key] = value
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkSecrets(name, fields[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redacted blanks credentials that are not ${ENV} references
+func (c ConnectorConfig) redacted() ConnectorConfig {
+	c = c.copySettings()
+	hide := func(secret *string) {
+		if !isEnvReference(*secret) {
+			*secret = ""
+		}
+	}
+	switch {
+	case c.Type == connectorZendesk && c.Zendesk != nil:
+		hide(&c.Zendesk.APIToken)
+	case c.Type == connectorIntercom && c.Intercom != nil:
+		hide(&c.Intercom.AccessToken)
+	case c.Type == connectorAppStore && c.AppStore != nil:
+		hide(&c.AppStore.PrivateKey)
+	case c.Type == connectorGooglePlay && c.GooglePlay != nil:
+		hide(&c.GooglePlay.ServiceAccount)
+		hide(&c.GooglePlay.AccessToken)
+	case c.Type == connectorREST && c.REST != nil:
+		for key, value := range c.REST.Headers {
+			hide(&value)
+			c.REST.Headers[key] = value
+		}
+	}
+	return c
+}
+
+// keepCredentials fills empty credentials from the previous config of the same type
+func (c *ConnectorConfig) keepCredentials(previous ConnectorConfig) {
+	if c.Type != previous.Type {
+		return
+	}
+	*c = c.copySettings()
+	keep := func(secret *string, old string) {
+		if *secret == "" {
+			*secret = old
+		}
+	}
+	switch {
+	case c.Zendesk != nil && previous.Zendesk != nil:
+		keep(&c.Zendesk.APIToken, previous.Zendesk.APIToken)
+	case c.Intercom != nil && previous.Intercom != nil:
+		keep(&c.Intercom.AccessToken, previous.Intercom.AccessToken)
+	case c.AppStore != nil && previous.AppStore != nil:
+		keep(&c.AppStore.PrivateKey, previous.AppStore.PrivateKey)
+	case c.GooglePlay != nil && previous.GooglePlay != nil:
+		keep(&c.GooglePlay.ServiceAccount, previous.GooglePlay.ServiceAccount)
+		keep(&c.GooglePlay.AccessToken, previous.GooglePlay.AccessToken)
+	case c.REST != nil && previous.REST != nil:
+		for key, value := range c.REST.Headers {
+			keep(&value, previous.REST.Headers[key])
+			c.REST.Headers[key] = value
+		}
+	}
+}
+
+// copySettings returns the config with its settings copied, so edits do not
+// reach the stored config
+func (c ConnectorConfig) copySettings() ConnectorConfig {
+	if c.Zendesk != nil {
+		settings := *c.Zendesk
+		c.Zendesk = &settings
+	}
+	if c.Intercom != nil {
+		settings := *c.Intercom
+		c.Intercom = &settings
+	}
+	if c.AppStore != nil {
+		settings := *c.AppStore
+		c.AppStore = &settings
+	}
+	if c.GooglePlay != nil {
+		settings := *c.GooglePlay
+		c.GooglePlay = &settings
+	}
+	if c.REST != nil {
+		settings := *c.REST
+		settings.Headers = make(map[string]string, len(c.REST.Headers))
+		for key, value := range c.REST.Headers {
+			settings.Headers[key] = value
+		}
+		c.REST = &settings
+	}
+	return c
+}
+
+// envReference matches a value that is only a ${NAME} reference
+var envReference = regexp.MustCompile(`^\$\{[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// isEnvReference reports whether a credential names an environment variable
+func isEnvReference(s string) bool {
+	return envReference.MatchString(strings.TrimSpace(s))
+}
+
+// connectorBaseURL returns the configured base URL or the API's default
+func connectorBaseURL(config ConnectorConfig, fallback string) string {
+	if config.BaseURL != "" {
+		return strings.TrimSuffix(expandEnv(config.BaseURL), "/")
+	}
+	return fallback
+}
+
+// expandEnv replaces ${NAME} references with the allowed environment variables
+func expandEnv(s string) string {
+	return strings.TrimSpace(expandSecrets(s))
+}
+
+// followLink resolves a pagination link against the current page. Links to
+// another origin are refused, since they would receive the credentials
+func followLink(current *url.URL, link string) (*url.URL, error) {
+	next, err := current.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("invalid next link %q: %w", link, err)
+	}
+	if !strings.EqualFold(next.Scheme, current.Scheme) || !strings.EqualFold(next.Host, current.Host) {
+		return nil, fmt.Errorf("next link %q leaves %s://%s", link, current.Scheme, current.Host)
+	}
+	return next, nil
+}
+
+// doConnectorRequest sends a request, retrying when the API rate-limits it,
+// decodes a JSON response into v and returns the response headers
+func doConnectorRequest(client *http.Client, req *http.Request, v interface{}) (http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+		resp, err := client.Do(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && attempt < connectorMaxRetries {
+			wait := time.Duration(1<<attempt) * time.Second
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			resp.Body.Close()
+			if wait > connectorMaxRetryAge {
+				return nil, fmt.Errorf("%s is rate limited for %s", req.URL.Redacted(), wait)
+			}
+			time.Sleep(wait)
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return nil, fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(snippet)))
+		}
+		decoder := json.NewDecoder(resp.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(v); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", req.URL.Redacted(), err)
+		}
+		return resp.Header, nil
+	}
+}
+
+// normalizeDate converts an API timestamp (RFC 3339, a date or Unix seconds
+// or milliseconds) to the YYYY-MM-DD form of uploaded CSVs
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n).UTC().Format(launchDateLayout)
+		}
+		return time.Unix(n, 0).UTC().Format(launchDateLayout)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(launchDateLayout)
+	}
+	if len(value) >= 10 {
+		if _, err := time.Parse(launchDateLayout, value[:10]); err == nil {
+			return value[:10]
+		}
+	}
+	return value
+}
+
+// htmlTag matches markup in support messages
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips markup and collapses whitespace
+func plainText(s string) string {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n").Replace(s)
+	s = htmlTag.ReplaceAllString(s, " ")
+	s = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'", "&nbsp;", " ").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// joinText joins non-empty parts, e.g. a title and a body
+func joinText(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestConnectorStore keeps configs and reviews in a temporary directory
+func newTestConnectorStore(t *testing.T) *ConnectorStore {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewConnectorStore(dir+"/connectors.json", dir+"/reviews", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewConnectorStore: %v", err)
+	}
+	return store
+}
+
+// reviewIDs lists the IDs of reviews in order
+func reviewIDs(reviews []Review) []string {
+	ids := make([]string, len(reviews))
+	for i, r := range reviews {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestDoConnectorRequestRetriesAfterRateLimit(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	var body struct {
+		OK bool `json:"ok"`
+	}
+	if _, err := doConnectorRequest(srv.Client(), req, &body); err != nil {
+		t.Fatalf("doConnectorRequest: %v", err)
+	}
+	if !body.OK || calls != 2 {
+		t.Fatalf("got ok=%v after %d calls, want ok after 2", body.OK, calls)
+	}
+}
+
+func TestDoConnectorRequestGivesUpOnLongRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	var body interface{}
+	if _, err := doConnectorRequest(srv.Client(), req, &body); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("got %v, want a rate limit error", err)
+	}
+}
+
+func TestConnectorStoreSyncMergesUpdatesByID(t *testing.T) {
+	// The first sync returns reviews 1 and 2; the next one an edited 2 and a new 3
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("since") == "" {
+			fmt.Fprint(w, `[{"id":1,"text":"first","updated":"2024-02-01"},{"id":2,"text":"second","updated":"2024-02-02"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":2,"text":"second, edited","updated":"2024-02-05"},{"id":3,"text":"third","updated":"2024-02-03"}]`)
+	}))
+	defer srv.Close()
+
+	store := newTestConnectorStore(t)
+	_, err := store.Upsert(ConnectorConfig{Name: "feedback", Type: connectorREST, REST: &RESTConfig{
+		URL:         srv.URL,
+		Fields:      map[string]string{"id": "id", "review_text": "text", "date": "updated"},
+		SinceParam:  "since",
+		CursorField: "updated",
+	}})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	report, err := store.Sync("feedback", false)
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if report.Added != 2 || report.Total != 2 || report.Cursor != "2024-02-02" {
+		t.Fatalf("first sync report = %+v", report)
+	}
+
+	report, err = store.Sync("feedback", false)
+	if err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if report.Fetched != 2 || report.Added != 1 || report.Updated != 1 || report.Total != 3 || report.Cursor != "2024-02-05" {
+		t.Fatalf("second sync report = %+v", report)
+	}
+
+	reviews, err := store.Reviews("feedback")
+	if err != nil {
+		t.Fatalf("Reviews: %v", err)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "feedback-1,feedback-3,feedback-2" {
+		t.Fatalf("reviews sorted by date = %s", got)
+	}
+	if reviews[2].ReviewText != "second, edited" {
+		t.Fatalf("review 2 was not replaced: %+v", reviews[2])
+	}
+
+	// A new store reads the same state back and resumes from the saved cursor
+	reloaded, err := NewConnectorStore(store.path, store.reviewsDir, time.Second)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := reloaded.List(); len(list) != 1 || list[0].Cursor != "2024-02-05" || list[0].ReviewCount != 3 {
+		t.Fatalf("reloaded connectors = %+v", list)
+	}
+	if reviews, _ := reloaded.Reviews("feedback"); len(reviews) != 3 {
+		t.Fatalf("reloaded %d reviews, want 3", len(reviews))
+	}
+}
+
+func TestConnectorStoreFullSyncReplacesReviews(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			fmt.Fprint(w, `[{"id":1,"text":"gone later"},{"id":2,"text":"kept"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":2,"text":"kept"}]`)
+	}))
+	defer srv.Close()
+
+	store := newTestConnectorStore(t)
+	store.Upsert(ConnectorConfig{Name: "api", Type: connectorREST, REST: &RESTConfig{
+		URL:    srv.URL,
+		Fields: map[string]string{"id": "id", "review_text": "text"},
+	}})
+	if _, err := store.Sync("api", false); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	report, err := store.Sync("api", true)
+	if err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	if report.Total != 1 || report.Added != 1 {
+		t.Fatalf("full sync report = %+v", report)
+	}
+}
+
+func TestConnectorStoreRecordsSyncErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	store := newTestConnectorStore(t)
+	store.Upsert(ConnectorConfig{Name: "api", Type: connectorREST, REST: &RESTConfig{
+		URL:    srv.URL,
+		Fields: map[string]string{"id": "id", "review_text": "text"},
+	}})
+	if _, err := store.Sync("api", false); err == nil {
+		t.Fatal("sync succeeded against a 401")
+	}
+	if list := store.List(); !strings.Contains(list[0].LastError, "401") {
+		t.Fatalf("last_error = %q", list[0].LastError)
+	}
+	if _, err := store.Sync("missing", false); err == nil {
+		t.Fatal("sync of an unknown connector succeeded")
+	}
+}
+
+func TestConnectorStoreUpsertRejectsUnlistedSecrets(t *testing.T) {
+	t.Setenv("GROQ_API_KEY", "server-secret")
+	store := newTestConnectorStore(t)
+	_, err := store.Upsert(ConnectorConfig{Name: "leak", Type: connectorREST, REST: &RESTConfig{
+		URL:     "https://example.com/reviews",
+		Headers: map[string]string{"X-Key": "${GROQ_API_KEY}"},
+		Fields:  map[string]string{"id": "id", "review_text": "text"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "GROQ_API_KEY") {
+		t.Fatalf("got %v, want the reference rejected", err)
+	}
+
+	_, err = store.Upsert(ConnectorConfig{Name: "zd", Type: connectorZendesk, BaseURL: "https://${GROQ_API_KEY}.example.com",
+		Zendesk: &ZendeskConfig{Email: "a@example.com", APIToken: "t"}})
+	if err == nil {
+		t.Fatal("a base_url reading GROQ_API_KEY was accepted")
+	}
+}
+
+func TestConnectorStoreKeepsCredentialsOnlyForTheSameAPI(t *testing.T) {
+	store := newTestConnectorStore(t)
+	config := ConnectorConfig{Name: "support", Type: connectorIntercom, BaseURL: "https://api.intercom.io",
+		Intercom: &IntercomConfig{AccessToken: "literal-token"}}
+	if _, err := store.Upsert(config); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	listed := store.List()[0]
+	if listed.Intercom.AccessToken != "" {
+		t.Fatalf("List leaked the token %q", listed.Intercom.AccessToken)
+	}
+
+	// Posting the listed config back keeps the stored token
+	if _, err := store.Upsert(listed); err != nil {
+		t.Fatalf("Upsert of the listed config: %v", err)
+	}
+	if got := store.connectors[0].Intercom.AccessToken; got != "literal-token" {
+		t.Fatalf("token after re-posting = %q", got)
+	}
+
+	// Pointing the connector elsewhere without a token must not carry it over
+	listed.BaseURL = "https://attacker.example.com"
+	if _, err := store.Upsert(listed); err == nil {
+		t.Fatal("the stored token followed a new base_url")
+	}
+	if got := store.connectors[0].BaseURL; got != "https://api.intercom.io" {
+		t.Fatalf("base_url changed to %q", got)
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	cases := map[string]string{
+		"2024-02-03T10:00:00Z":      "2024-02-03",
+		"2024-02-03T23:30:00-05:00": "2024-02-04",
+		"2024-02-03":                "2024-02-03",
+		"1706918400":                "2024-02-03",
+		"1706918400000":             "2024-02-03",
+		"last week":                 "last week",
+	}
+	for in, want := range cases {
+		if got := normalizeDate(in); got != want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := plainText("<p>Crash on <b>login</b></p><p>Tom &amp; Jerry</p>")
+	if want := "Crash on login Tom & Jerry"; got != want {
+		t.Fatalf("plainText = %q, want %q", got, want)
+	}
+}
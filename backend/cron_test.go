@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	cases := []struct {
+		expr string
+		ok   bool
+	}{
+		{"*/15 * * * *", true},
+		{"0-30/10 9-17 * * mon-fri", true},
+		{"5/15 * * * *", true},
+		{"0 0 1,15 jan,JUL *", true},
+		{"0 0 * * 7", true},
+		{"@weekly", true},
+		{"@Daily", true},
+		{"0 0 * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"30-10 * * * *", false},
+		{"* * * foo *", false},
+		{"@reboot", false},
+	}
+	for _, c := range cases {
+		if _, err := ParseCron(c.expr, nil); (err == nil) != c.ok {
+			t.Errorf("ParseCron(%q) error = %v, want ok %v", c.expr, err, c.ok)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data: %v", err)
+	}
+	utc := func(s string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		return parsed
+	}
+
+	cases := []struct {
+		name     string
+		expr     string
+		location *time.Location
+		from     string
+		want     string // "" when the schedule never fires
+	}{
+		{"strictly after", "0 6 * * *", nil, "2024-03-01T06:00:00Z", "2024-03-02T06:00:00Z"},
+		{"seconds truncated", "* * * * *", nil, "2024-03-01T06:00:59Z", "2024-03-01T06:01:00Z"},
+		{"step", "*/15 * * * *", nil, "2024-03-01T06:16:00Z", "2024-03-01T06:30:00Z"},
+		{"step from a start", "5/20 * * * *", nil, "2024-03-01T06:26:00Z", "2024-03-01T06:45:00Z"},
+		{"range with step", "0-30/10 * * * *", nil, "2024-03-01T06:31:00Z", "2024-03-01T07:00:00Z"},
+		{"weekday names", "0 9 * * mon-fri", nil, "2024-03-01T10:00:00Z", "2024-03-04T09:00:00Z"},
+		{"month names", "0 0 1 jul *", nil, "2024-03-01T00:00:00Z", "2024-07-01T00:00:00Z"},
+		{"sunday as 7", "0 0 * * 7", nil, "2024-03-01T00:00:00Z", "2024-03-03T00:00:00Z"},
+		{"sunday in a range ending at 7", "0 0 * * 6-7", nil, "2024-03-02T12:00:00Z", "2024-03-03T00:00:00Z"},
+		{"day of month or day of week", "0 0 15 * mon", nil, "2024-03-05T00:00:00Z", "2024-03-11T00:00:00Z"},
+		{"day of month when it comes first", "0 0 15 * mon", nil, "2024-03-12T00:00:00Z", "2024-03-15T00:00:00Z"},
+		{"restricted day of week only", "0 0 * * fri", nil, "2024-03-01T00:00:00Z", "2024-03-08T00:00:00Z"},
+		{"leap day", "0 0 29 2 *", nil, "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"impossible date", "0 0 30 2 *", nil, "2024-03-01T00:00:00Z", ""},
+		{"31st skips short months", "0 0 31 * *", nil, "2024-03-31T00:00:00Z", "2024-05-31T00:00:00Z"},
+		{"in a time zone", "0 6 * * *", newYork, "2024-03-01T12:00:00Z", "2024-03-02T11:00:00Z"},
+		{"skipped spring-forward hour", "30 2 * * *", newYork, "2024-03-10T05:00:00Z", "2024-03-11T06:30:00Z"},
+		{"across spring forward", "0 6 * * *", newYork, "2024-03-09T12:00:00Z", "2024-03-10T10:00:00Z"},
+		{"repeated fall-back hour runs once", "30 1 * * *", newYork, "2024-11-03T05:30:00Z", "2024-11-04T06:30:00Z"},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expr, c.location)
+		if err != nil {
+			t.Fatalf("%s: ParseCron(%q): %v", c.name, c.expr, err)
+		}
+		got := schedule.Next(utc(c.from))
+		if c.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%s: Next = %v, want never", c.name, got)
+			}
+			continue
+		}
+		if want := utc(c.want); !got.Equal(want) {
+			t.Errorf("%s: Next(%s) = %v, want %v", c.name, c.from, got.UTC(), want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns review texts into vectors for clustering
+type Embedder interface {
+	Name() string
+	Embed(texts []string) ([][]float64, error)
+}
+
+// LoadEmbedder picks an embedder from EMBEDDINGS_PROVIDER: "hashing" (default,
+// offline) or "openai" for any OpenAI-compatible /embeddings endpoint
+func LoadEmbedder() Embedder {
+	switch getEnv("EMBEDDINGS_PROVIDER", "hashing") {
+	case "openai":
+		return NewHTTPEmbedder(
+			getEnv("EMBEDDINGS_BASE_URL", "https://api.openai.com/v1"),
+			getEnv("EMBEDDINGS_API_KEY", ""),
+			getEnv("EMBEDDINGS_MODEL", "text-embedding-3-small"),
+		)
+	default:
+		return NewHashingEmbedder(getEnvInt("EMBEDDINGS_DIMENSIONS", 512))
+	}
+}
+
+// HashingEmbedder is a local hashing vectorizer over word unigrams and bigrams.
+// It needs no network access and gives the same vectors on every run
+type HashingEmbedder struct {
+	dimensions int
+}
+
+// NewHashingEmbedder creates a hashing vectorizer with the given vector size
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	if dimensions <= 0 {
+		dimensions = 512
+	}
+	return &HashingEmbedder{dimensions: dimensions}
+}
+
+// Name identifies the embedder in analysis metadata
+func (h *HashingEmbedder) Name() string {
+	return fmt.Sprintf("hashing-%d", h.dimensions)
+}
+
+// Embed returns L2-normalised, sublinear term-frequency vectors
+func (h *HashingEmbedder) Embed(texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		counts := make(map[int]float64)
+		tokens := tokenize(text)
+		for j, token := range tokens {
+			h.addFeature(counts, token)
+			if j > 0 {
+				h.addFeature(counts, tokens[j-1]+" "+token)
+			}
+		}
+
+		vector := make([]float64, h.dimensions)
+		for index, count := range counts {
+			// Features that collided with opposite signs cancelled out; log(0) would be -Inf
+			if count == 0 {
+				continue
+			}
+			sign := 1.0
+			if count < 0 {
+				sign, count = -1, -count
+			}
+			vector[index] = sign * (1 + math.Log(count))
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
+
+// addFeature hashes a feature into a bucket, using a second hash bit for the
+// sign so collisions tend to cancel out rather than accumulate
+func (h *HashingEmbedder) addFeature(counts map[int]float64, feature string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(feature))
+	sum := hash.Sum64()
+	index := int(sum % uint64(h.dimensions))
+	if sum>>63 == 1 {
+		counts[index]--
+	} else {
+		counts[index]++
+	}
+}
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint
+type HTTPEmbedder struct {
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewHTTPEmbedder creates an embedder backed by a provider API
+func NewHTTPEmbedder(baseURL, apiKey, model string) *HTTPEmbedder {
+	return &HTTPEmbedder{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, model: model}
+}
+
+// Name identifies the embedder in analysis metadata
+func (e *HTTPEmbedder) Name() string {
+	return e.model
+}
+
+// Embed requests embeddings in batches to stay within provider input limits
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float64, error) {
+	const batchSize = 256
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := e.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *HTTPEmbedder) embedBatch(texts []string) ([][]float64, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embeddings request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", e.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embeddings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings API returned %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings API returned out-of-range index %d", d.Index)
+		}
+		vectors[d.Index] = normalize(d.Embedding)
+	}
+	return vectors, nil
+}
+
+// stopwords are dropped before hashing; they carry no theme signal
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "has": true, "have": true, "i": true,
+	"in": true, "is": true, "it": true, "its": true, "me": true, "my": true, "of": true,
+	"on": true, "or": true, "so": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "we": true, "were": true, "with": true, "you": true, "your": true,
+}
+
+// tokenize lower-cases text and splits it into words, dropping stopwords
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		w = strings.Trim(w, "'")
+		if w != "" && !stopwords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// normalize scales a vector to unit length so dot products are cosine similarities
+func normalize(v []float64) []float64 {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
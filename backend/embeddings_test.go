@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"testing"
+)
+
+// assertFinite fails when any component is NaN or infinite
+func assertFinite(t *testing.T, text string, vector []float64) {
+	t.Helper()
+	for i, x := range vector {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			t.Fatalf("component %d of %q is %v", i, text, x)
+		}
+	}
+}
+
+func TestHashingEmbedderCancelledBucketsStayFinite(t *testing.T) {
+	const dimensions = 8
+	h := NewHashingEmbedder(dimensions)
+	bucket := func(feature string) (int, bool) {
+		hash := fnv.New64a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum64()
+		return int(sum % dimensions), sum>>63 == 1
+	}
+
+	// Find two words that hash to the same bucket with opposite signs, and
+	// whose bigram lands elsewhere, so the bucket ends at exactly zero
+	var text string
+	for i := 0; i < 1000 && text == ""; i++ {
+		a := fmt.Sprintf("word%d", i)
+		indexA, negA := bucket(a)
+		for j := i + 1; j < 1000; j++ {
+			b := fmt.Sprintf("word%d", j)
+			indexB, negB := bucket(b)
+			if indexBigram, _ := bucket(a + " " + b); indexA == indexB && negA != negB && indexBigram != indexA {
+				text = a + " " + b
+				break
+			}
+		}
+	}
+	if text == "" {
+		t.Fatal("no cancelling pair found")
+	}
+
+	vectors, err := h.Embed([]string{text})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	assertFinite(t, text, vectors[0])
+}
+
+func TestHashingEmbedderSampleDataIsFinite(t *testing.T) {
+	var texts []string
+	for _, path := range []string{"../data/pre_launch.csv", "../data/post_launch.csv"} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Skipf("sample data: %v", err)
+		}
+		reviews, err := NewCSVReviewParser().ParseCSV(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		for _, r := range reviews {
+			texts = append(texts, r.ReviewText)
+		}
+	}
+
+	vectors, err := NewHashingEmbedder(0).Embed(texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	for i, vector := range vectors {
+		assertFinite(t, texts[i], vector)
+		norm := 0.0
+		for _, x := range vector {
+			norm += x * x
+		}
+		if math.Abs(norm-1) > 1e-9 {
+			t.Fatalf("%q has norm %v, want 1", texts[i], math.Sqrt(norm))
+		}
+	}
+}
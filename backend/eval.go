@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// sentimentClasses are the labels scored by the evaluation harness
+var sentimentClasses = []string{"positive", "negative", "neutral"}
+
+// noPrediction marks reviews the analyzer returned no sentiment for
+const noPrediction = "(none)"
+
+// LabeledReview is a review with gold-standard annotations
+type LabeledReview struct {
+	Review
+	Phase         string   `json:"phase"` // "pre_launch" or "post_launch"
+	GoldSentiment string   `json:"gold_sentiment"`
+	GoldThemes    []string `json:"gold_themes"`
+}
+
+// ClassMetrics holds per-class classification scores
+type ClassMetrics struct {
+	Class     string  `json:"class"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// ClassificationReport scores sentiment predictions against gold labels
+type ClassificationReport struct {
+	Classes   []ClassMetrics            `json:"classes"`
+	Accuracy  float64                   `json:"accuracy"`
+	MacroF1   float64                   `json:"macro_f1"`
+	Confusion map[string]map[string]int `json:"confusion"` // gold -> predicted -> count
+	Missing   int                       `json:"missing"`   // reviews with no prediction
+}
+
+// ThemeAgreement compares extracted themes with the gold theme tags
+type ThemeAgreement struct {
+	Precision float64  `json:"precision"`
+	Recall    float64  `json:"recall"`
+	F1        float64  `json:"f1"`
+	Matched   []string `json:"matched"`
+	Missed    []string `json:"missed"`    // gold themes the analyzer did not find
+	Spurious  []string `json:"spurious"`  // extracted themes with no gold tag
+	CountMAE  float64  `json:"count_mae"` // mean absolute error of pre/post counts over matched themes
+}
+
+// EvaluationReport is the result of running one analyzer configuration over a labeled dataset
+type EvaluationReport struct {
+	Name       string               `json:"name"`
+	Generation *GenerationInfo      `json:"generation,omitempty"`
+	Reviews    int                  `json:"reviews"`
+	Sentiment  ClassificationReport `json:"sentiment"`
+	Themes     ThemeAgreement       `json:"themes"`
+}
+
+// EvalConfig describes an analyzer configuration to evaluate
+type EvalConfig struct {
+	Name       string           `json:"name"`
+	Generation GenerationConfig `json:"generation"`
+	PromptDir  string           `json:"prompt_dir"`
+}
+
+// ParseLabeledCSV reads reviews with gold_sentiment, gold_themes (separated by
+// ";" or "|") and an optional phase column; rows without a phase are post-launch
+func ParseLabeledCSV(reader io.Reader) ([]LabeledReview, error) {
+	csvReader := csv.NewReader(reader)
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	colIndex := make(map[string]int)
+	for i, col := range header {
+		colIndex[col] = i
+	}
+	if _, ok := colIndex["gold_sentiment"]; !ok {
+		return nil, fmt.Errorf("labeled CSV must have a gold_sentiment column")
+	}
+
+	var labeled []LabeledReview
+	lineNum := 1
+	for {
+		lineNum++
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading line %d: %w", lineNum, err)
+		}
+
+		lr := LabeledReview{
+			Review: reviewFromRecord(colIndex, record),
+			Phase:  "post_launch",
+		}
+		if idx, ok := colIndex["phase"]; ok && idx < len(record) && record[idx] != "" {
+			lr.Phase = strings.TrimSpace(record[idx])
+		}
+		if idx := colIndex["gold_sentiment"]; idx < len(record) {
+			lr.GoldSentiment = strings.ToLower(strings.TrimSpace(record[idx]))
+		}
+		if idx, ok := colIndex["gold_themes"]; ok && idx < len(record) {
+			for _, t := range strings.FieldsFunc(record[idx], func(r rune) bool { return r == ';' || r == '|' }) {
+				if t = strings.TrimSpace(t); t != "" {
+					lr.GoldThemes = append(lr.GoldThemes, t)
+				}
+			}
+		}
+		labeled = append(labeled, lr)
+	}
+
+	return labeled, nil
+}
+
+// Evaluate runs an analyzer over a labeled dataset and scores its output;
+// review IDs must be unique across both phases so predictions can be matched
+func Evaluate(name string, analyzer LLMAnalyzer, dataset []LabeledReview) (*EvaluationReport, error) {
+	var pre, post, all []Review
+	seen := make(map[string]bool, len(dataset))
+	for _, lr := range dataset {
+		if seen[lr.ID] {
+			return nil, fmt.Errorf("duplicate review id %q in labeled dataset", lr.ID)
+		}
+		seen[lr.ID] = true
+		all = append(all, lr.Review)
+		if lr.Phase == "pre_launch" {
+			pre = append(pre, lr.Review)
+		} else {
+			post = append(post, lr.Review)
+		}
+	}
+
+	sentiments, err := analyzer.AnalyzeSentiments(all)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze sentiments: %w", err)
+	}
+	themes, err := analyzer.ExtractThemes(pre, post)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract themes: %w", err)
+	}
+
+	report := &EvaluationReport{
+		Name:      name,
+		Reviews:   len(dataset),
+		Sentiment: scoreSentiments(dataset, sentiments),
+		Themes:    scoreThemes(dataset, themes),
+	}
+	if ra, ok := analyzer.(ReproducibleAnalyzer); ok {
+		info := ra.Describe()
+		report.Generation = &info
+	}
+	return report, nil
+}
+
+// scoreSentiments builds the confusion matrix and per-class precision/recall/F1
+func scoreSentiments(dataset []LabeledReview, predictions []SentimentResult) ClassificationReport {
+	predicted := make(map[string]string, len(predictions))
+	for _, p := range predictions {
+		predicted[p.ReviewID] = strings.ToLower(strings.TrimSpace(p.Sentiment))
+	}
+
+	report := ClassificationReport{Confusion: make(map[string]map[string]int)}
+	correct := 0
+	for _, lr := range dataset {
+		pred, ok := predicted[lr.ID]
+		if !ok {
+			pred = noPrediction
+			report.Missing++
+		}
+		if report.Confusion[lr.GoldSentiment] == nil {
+			report.Confusion[lr.GoldSentiment] = make(map[string]int)
+		}
+		report.Confusion[lr.GoldSentiment][pred]++
+		if pred == lr.GoldSentiment {
+			correct++
+		}
+	}
+	if len(dataset) > 0 {
+		report.Accuracy = float64(correct) / float64(len(dataset))
+	}
+
+	for _, class := range sentimentClasses {
+		tp := report.Confusion[class][class]
+		support, predictedTotal := 0, 0
+		for gold, row := range report.Confusion {
+			for pred, n := range row {
+				if gold == class {
+					support += n
+				}
+				if pred == class {
+					predictedTotal += n
+				}
+			}
+		}
+		m := ClassMetrics{Class: class, Support: support}
+		m.Precision = ratio(tp, predictedTotal)
+		m.Recall = ratio(tp, support)
+		m.F1 = f1(m.Precision, m.Recall)
+		report.Classes = append(report.Classes, m)
+		report.MacroF1 += m.F1 / float64(len(sentimentClasses))
+	}
+
+	return report
+}
+
+// scoreThemes matches extracted theme names to gold tags case-insensitively
+func scoreThemes(dataset []LabeledReview, themes []ThemeResult) ThemeAgreement {
+	type counts struct{ pre, post int }
+	gold := make(map[string]*counts)
+	display := make(map[string]string)
+	for _, lr := range dataset {
+		for _, t := range lr.GoldThemes {
+			key := strings.ToLower(t)
+			if gold[key] == nil {
+				gold[key] = &counts{}
+				display[key] = t
+			}
+			if lr.Phase == "pre_launch" {
+				gold[key].pre++
+			} else {
+				gold[key].post++
+			}
+		}
+	}
+
+	agreement := ThemeAgreement{Matched: []string{}, Missed: []string{}, Spurious: []string{}}
+	found := make(map[string]bool)
+	totalError := 0
+	for _, t := range themes {
+		key := strings.ToLower(strings.TrimSpace(t.Theme))
+		g, ok := gold[key]
+		if !ok {
+			agreement.Spurious = append(agreement.Spurious, t.Theme)
+			continue
+		}
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		agreement.Matched = append(agreement.Matched, display[key])
+		totalError += absInt(t.PreCount-g.pre) + absInt(t.PostCount-g.post)
+	}
+	for key := range gold {
+		if !found[key] {
+			agreement.Missed = append(agreement.Missed, display[key])
+		}
+	}
+	sort.Strings(agreement.Missed)
+
+	agreement.Precision = ratio(len(agreement.Matched), len(agreement.Matched)+len(agreement.Spurious))
+	agreement.Recall = ratio(len(agreement.Matched), len(gold))
+	agreement.F1 = f1(agreement.Precision, agreement.Recall)
+	if len(agreement.Matched) > 0 {
+		agreement.CountMAE = float64(totalError) / float64(2*len(agreement.Matched))
+	}
+	return agreement
+}
+
+// runEvaluate implements the "evaluate" command and returns the process exit code
+func runEvaluate(args []string) int {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	dataset := fs.String("dataset", "", "labeled CSV with gold_sentiment and gold_themes columns")
+	configA := fs.String("config-a", "", "JSON analyzer configuration (defaults to the environment)")
+	configB := fs.String("config-b", "", "optional second configuration to compare against")
+	asJSON := fs.Bool("json", false, "print reports as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *dataset == "" {
+		fmt.Fprintln(os.Stderr, "evaluate: --dataset is required")
+		return 2
+	}
+
+	file, err := os.Open(*dataset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+		return 1
+	}
+	labeled, err := ParseLabeledCSV(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+		return 1
+	}
+
+	paths := []string{*configA}
+	if *configB != "" {
+		paths = append(paths, *configB)
+	}
+
+	var reports []*EvaluationReport
+	for i, path := range paths {
+		config, err := loadEvalConfig(path, fmt.Sprintf("config-%c", 'a'+i))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+			return 1
+		}
+		analyzer, err := newEvalAnalyzer(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "evaluate: %v\n", err)
+			return 1
+		}
+		report, err := Evaluate(config.Name, analyzer, labeled)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "evaluate %s: %v\n", config.Name, err)
+			return 1
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+		return 0
+	}
+	printEvaluationReports(os.Stdout, reports)
+	return 0
+}
+
+// loadEvalConfig reads a configuration file, or builds one from the environment when path is empty
+func loadEvalConfig(path, defaultName string) (EvalConfig, error) {
+	config := EvalConfig{
+		Name:       defaultName,
+		Generation: LoadGenerationConfig(),
+		PromptDir:  getEnv("PROMPT_DIR", ""),
+	}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// newEvalAnalyzer builds a Groq analyzer for an evaluation configuration
+func newEvalAnalyzer(config EvalConfig) (LLMAnalyzer, error) {
+	apiKey := getEnv("GROQ_API_KEY", "")
+	if apiKey == "" {
+		return nil, fmt.Errorf("GROQ_API_KEY environment variable is required")
+	}
+	prompts, err := NewPromptRegistry(config.PromptDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompts for %s: %w", config.Name, err)
+	}
+	return NewGroqClient(apiKey, config.Generation, prompts), nil
+}
+
+// printEvaluationReports writes the reports as side-by-side tables
+func printEvaluationReports(w io.Writer, reports []*EvaluationReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(tw, "metric")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "\t%s", r.Name)
+	}
+	fmt.Fprintln(tw)
+
+	row := func(label string, value func(*EvaluationReport) string) {
+		fmt.Fprint(tw, label)
+		for _, r := range reports {
+			fmt.Fprintf(tw, "\t%s", value(r))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	row("model", func(r *EvaluationReport) string {
+		if r.Generation == nil {
+			return "-"
+		}
+		return r.Generation.Model
+	})
+	row("prompt version", func(r *EvaluationReport) string {
+		if r.Generation == nil {
+			return "-"
+		}
+		return r.Generation.PromptVersion
+	})
+	row("accuracy", func(r *EvaluationReport) string { return fmt.Sprintf("%.3f", r.Sentiment.Accuracy) })
+	row("macro F1", func(r *EvaluationReport) string { return fmt.Sprintf("%.3f", r.Sentiment.MacroF1) })
+	for i, class := range sentimentClasses {
+		i := i
+		row(class+" P/R/F1", func(r *EvaluationReport) string {
+			m := r.Sentiment.Classes[i]
+			return fmt.Sprintf("%.2f/%.2f/%.2f", m.Precision, m.Recall, m.F1)
+		})
+	}
+	row("missing predictions", func(r *EvaluationReport) string { return fmt.Sprintf("%d", r.Sentiment.Missing) })
+	row("theme P/R/F1", func(r *EvaluationReport) string {
+		return fmt.Sprintf("%.2f/%.2f/%.2f", r.Themes.Precision, r.Themes.Recall, r.Themes.F1)
+	})
+	row("theme count MAE", func(r *EvaluationReport) string { return fmt.Sprintf("%.2f", r.Themes.CountMAE) })
+	tw.Flush()
+
+	for _, r := range reports {
+		fmt.Fprintf(w, "\nConfusion matrix (%s, rows = gold, columns = predicted):\n", r.Name)
+		printConfusionMatrix(w, r.Sentiment.Confusion)
+	}
+}
+
+// printConfusionMatrix writes a gold x predicted count table
+func printConfusionMatrix(w io.Writer, confusion map[string]map[string]int) {
+	rows, columns := confusionLabels(confusion)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\t")
+	for _, c := range columns {
+		fmt.Fprintf(tw, "%s\t", c)
+	}
+	fmt.Fprintln(tw)
+	for _, gold := range rows {
+		fmt.Fprintf(tw, "%s\t", gold)
+		for _, c := range columns {
+			fmt.Fprintf(tw, "%d\t", confusion[gold][c])
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// confusionLabels lists the matrix rows and columns: the sentiment classes,
+// then any other label found in the data in sorted order, and the column for
+// missing predictions last
+func confusionLabels(confusion map[string]map[string]int) (rows, columns []string) {
+	var otherGold, otherPredicted []string
+	for gold, row := range confusion {
+		if !containsString(sentimentClasses, gold) && !containsString(otherGold, gold) {
+			otherGold = append(otherGold, gold)
+		}
+		for pred := range row {
+			if pred != noPrediction && !containsString(sentimentClasses, pred) && !containsString(otherPredicted, pred) {
+				otherPredicted = append(otherPredicted, pred)
+			}
+		}
+	}
+	sort.Strings(otherGold)
+	sort.Strings(otherPredicted)
+	rows = append(append([]string{}, sentimentClasses...), otherGold...)
+	columns = append(append(append([]string{}, sentimentClasses...), otherPredicted...), noPrediction)
+	return rows, columns
+}
+
+func ratio(num, den int) float64 {
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func labeled(id, phase, sentiment string, themes ...string) LabeledReview {
+	return LabeledReview{Review: Review{ID: id}, Phase: phase, GoldSentiment: sentiment, GoldThemes: themes}
+}
+
+func TestScoreSentiments(t *testing.T) {
+	dataset := []LabeledReview{
+		labeled("r1", "post_launch", "positive"),
+		labeled("r2", "post_launch", "positive"),
+		labeled("r3", "post_launch", "positive"),
+		labeled("r4", "post_launch", "negative"),
+		labeled("r5", "post_launch", "negative"),
+		labeled("r6", "post_launch", "neutral"),
+		labeled("r7", "post_launch", "neutral"),
+		labeled("r8", "post_launch", "neutral"),
+	}
+	predictions := []SentimentResult{
+		{ReviewID: "r1", Sentiment: "positive"},
+		{ReviewID: "r2", Sentiment: "positive"},
+		{ReviewID: "r3", Sentiment: "negative"},
+		{ReviewID: "r4", Sentiment: "negative"},
+		{ReviewID: "r5", Sentiment: "mixed"},
+		{ReviewID: "r6", Sentiment: "neutral"},
+		{ReviewID: "r8", Sentiment: " Positive "},
+	}
+	report := scoreSentiments(dataset, predictions)
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(report.Accuracy, 0.5) || report.Missing != 1 {
+		t.Fatalf("accuracy %g with %d missing, want 0.5 with 1", report.Accuracy, report.Missing)
+	}
+	want := []ClassMetrics{
+		{Class: "positive", Precision: 2.0 / 3, Recall: 2.0 / 3, F1: 2.0 / 3, Support: 3},
+		{Class: "negative", Precision: 0.5, Recall: 0.5, F1: 0.5, Support: 2},
+		{Class: "neutral", Precision: 1, Recall: 1.0 / 3, F1: 0.5, Support: 3},
+	}
+	for i, w := range want {
+		m := report.Classes[i]
+		if m.Class != w.Class || m.Support != w.Support || !near(m.Precision, w.Precision) || !near(m.Recall, w.Recall) || !near(m.F1, w.F1) {
+			t.Errorf("class %d = %+v, want %+v", i, m, w)
+		}
+	}
+	if !near(report.MacroF1, (2.0/3+0.5+0.5)/3) {
+		t.Errorf("macro F1 = %g", report.MacroF1)
+	}
+	if report.Confusion["negative"]["mixed"] != 1 || report.Confusion["neutral"][noPrediction] != 1 {
+		t.Errorf("confusion = %v, want the off-class and missing predictions counted", report.Confusion)
+	}
+}
+
+func TestScoreSentimentsEmpty(t *testing.T) {
+	report := scoreSentiments(nil, nil)
+	if report.Accuracy != 0 || report.MacroF1 != 0 || len(report.Classes) != len(sentimentClasses) {
+		t.Fatalf("report = %+v, want zero scores for every class", report)
+	}
+}
+
+func TestScoreThemes(t *testing.T) {
+	dataset := []LabeledReview{
+		labeled("r1", "pre_launch", "positive", "Battery"),
+		labeled("r2", "post_launch", "negative", "battery", "Login"),
+		labeled("r3", "post_launch", "negative", "Sync"),
+	}
+	themes := []ThemeResult{
+		{Theme: "BATTERY ", PreCount: 1, PostCount: 3},
+		{Theme: "Login", PostCount: 1},
+		{Theme: "battery", PostCount: 9}, // a repeat is neither matched again nor spurious
+		{Theme: "Pricing", PostCount: 2},
+	}
+	agreement := scoreThemes(dataset, themes)
+
+	if got := strings.Join(agreement.Matched, ","); got != "Battery,Login" {
+		t.Errorf("matched = %s", got)
+	}
+	if got := strings.Join(agreement.Missed, ","); got != "Sync" {
+		t.Errorf("missed = %s", got)
+	}
+	if got := strings.Join(agreement.Spurious, ","); got != "Pricing" {
+		t.Errorf("spurious = %s", got)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(agreement.Precision, 2.0/3) || !near(agreement.Recall, 2.0/3) || !near(agreement.F1, 2.0/3) {
+		t.Errorf("P/R/F1 = %g/%g/%g, want 2/3 each", agreement.Precision, agreement.Recall, agreement.F1)
+	}
+	// Battery is off by 2 in the post-launch count, over 2 matched themes x 2 phases
+	if !near(agreement.CountMAE, 0.5) {
+		t.Errorf("count MAE = %g, want 0.5", agreement.CountMAE)
+	}
+}
+
+func TestPrintConfusionMatrixKeepsOffClassLabels(t *testing.T) {
+	confusion := map[string]map[string]int{
+		"positive": {"positive": 3, "very positive": 1},
+		"negative": {"mixed": 2, noPrediction: 1},
+		"sarcasm":  {"negative": 1},
+	}
+	var buf bytes.Buffer
+	printConfusionMatrix(&buf, confusion)
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	header := strings.Join(strings.Fields(lines[0]), "|")
+	if header != "positive|negative|neutral|mixed|very|positive|(none)" {
+		t.Fatalf("columns = %s, want the classes, the off-class predictions and (none)", header)
+	}
+	want := []string{
+		"positive 3 0 0 0 1 0",
+		"negative 0 0 0 2 0 1",
+		"neutral 0 0 0 0 0 0",
+		"sarcasm 0 1 0 0 0 0",
+	}
+	for i, w := range want {
+		if got := strings.Join(strings.Fields(lines[i+1]), " "); got != w {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+}
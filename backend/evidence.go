@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the quotes attached to themes and impact claims
+const (
+	maxQuoteLength       = 300
+	maxEvidencePerTheme  = 3
+	maxEvidencePerClaim  = 3
+	maxQuotesInImpactRun = 30
+)
+
+// Evidence is a verbatim span from a review supporting a theme or claim
+type Evidence struct {
+	ReviewID string `json:"review_id"`
+	Phase    string `json:"phase"` // "pre_launch" or "post_launch"
+	Quote    string `json:"quote"`
+}
+
+// ImpactClaim is an impact bullet together with the reviews that support it
+type ImpactClaim struct {
+	Text     string     `json:"text"`
+	Evidence []Evidence `json:"evidence"`
+}
+
+// UnmarshalJSON accepts either a claim object or a bare string, since models
+// do not always follow the requested format
+func (c *ImpactClaim) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ImpactClaim{Text: text, Evidence: []Evidence{}}
+		return nil
+	}
+	type claim ImpactClaim
+	var parsed claim
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	*c = ImpactClaim(parsed)
+	return nil
+}
+
+// reviewIndex looks up review text by phase and review ID
+type reviewIndex map[string]map[string]Review
+
+// newReviewIndex indexes the reviews that were sent to the LLM
+func newReviewIndex(preReviews, postReviews []Review) reviewIndex {
+	index := reviewIndex{"pre_launch": {}, "post_launch": {}}
+	for _, r := range preReviews {
+		index["pre_launch"][r.ID] = r
+	}
+	for _, r := range postReviews {
+		index["post_launch"][r.ID] = r
+	}
+	return index
+}
+
+// verify returns the evidence with its phase resolved and the quote replaced
+// by the matching span of the review, or false if the quote is not found.
+// Matching ignores case, spacing and surrounding quote marks, but the span is
+// the review's own text, so a verified quote always appears verbatim
+func (idx reviewIndex) verify(e Evidence) (Evidence, bool) {
+	quote := normalizeQuote(e.Quote)
+	if quote == "" || len(quote) > maxQuoteLength {
+		return e, false
+	}
+
+	phases := []string{e.Phase}
+	if e.Phase != "pre_launch" && e.Phase != "post_launch" {
+		phases = []string{"post_launch", "pre_launch"}
+	}
+	for _, phase := range phases {
+		review, ok := idx[phase][e.ReviewID]
+		if !ok {
+			continue
+		}
+		if span, found := findQuote(review.ReviewText, quote); found {
+			return Evidence{ReviewID: e.ReviewID, Phase: phase, Quote: span}, true
+		}
+	}
+	return e, false
+}
+
+// verifyAll keeps only evidence that can be found in the referenced reviews
+func (idx reviewIndex) verifyAll(evidence []Evidence, limit int) ([]Evidence, int) {
+	verified := []Evidence{}
+	dropped := 0
+	for _, e := range evidence {
+		v, ok := idx.verify(e)
+		if !ok {
+			dropped++
+			continue
+		}
+		if len(verified) < limit {
+			verified = append(verified, v)
+		}
+	}
+	return verified, dropped
+}
+
+// verifyAspectEvidence replaces aspect evidence with the matching span of its
+// review, clearing evidence that is not found there
+func verifyAspectEvidence(idx reviewIndex, aspects []AspectSentiment) int {
+	dropped := 0
+	for i, a := range aspects {
+		if a.Evidence == "" {
+			continue
+		}
+		v, ok := idx.verify(Evidence{ReviewID: a.ReviewID, Phase: a.Phase, Quote: a.Evidence})
+		if !ok {
+			aspects[i].Evidence = ""
+			dropped++
+			continue
+		}
+		aspects[i].Evidence = v.Quote
+	}
+	return dropped
+}
+
+// attachThemeEvidence gives each theme up to maxEvidencePerTheme verified quotes,
+// preferring post-launch reviews since they describe the launched product
+func attachThemeEvidence(themes []ThemeResult, aspects []AspectSentiment) {
+	index := make(map[string]int, len(themes))
+	for i, t := range themes {
+		index[strings.ToLower(t.Theme)] = i
+		themes[i].Evidence = []Evidence{}
+	}
+	for _, phase := range []string{"post_launch", "pre_launch"} {
+		for _, a := range aspects {
+			i, ok := index[strings.ToLower(strings.TrimSpace(a.Aspect))]
+			if !ok || a.Phase != phase || a.Evidence == "" || len(themes[i].Evidence) >= maxEvidencePerTheme {
+				continue
+			}
+			themes[i].Evidence = append(themes[i].Evidence, Evidence{ReviewID: a.ReviewID, Phase: a.Phase, Quote: a.Evidence})
+		}
+	}
+}
+
+// verifyImpactEvidence drops claim evidence that does not match the referenced reviews
+func verifyImpactEvidence(idx reviewIndex, impact *ImpactSummary) int {
+	dropped := 0
+	for _, claims := range [][]ImpactClaim{impact.KeyImprovements, impact.CriticalIssues} {
+		for i := range claims {
+			var n int
+			claims[i].Evidence, n = idx.verifyAll(claims[i].Evidence, maxEvidencePerClaim)
+			dropped += n
+		}
+	}
+	return dropped
+}
+
+// formatQuotesForSummary lists the verified theme quotes the impact summary may cite
+func formatQuotesForSummary(themes []ThemeResult) string {
+	result := ""
+	count := 0
+	for _, t := range themes {
+		for _, e := range t.Evidence {
+			if count >= maxQuotesInImpactRun {
+				return result
+			}
+			result += fmt.Sprintf("- [%s] review_id=%s phase=%s: %q\n", t.Theme, e.ReviewID, e.Phase, e.Quote)
+			count++
+		}
+	}
+	if result == "" {
+		return "(no quotes available)\n"
+	}
+	return result
+}
+
+// normalizeQuote collapses whitespace and lower-cases text so formatting
+// differences do not cause genuine quotes to be rejected
+func normalizeQuote(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), "\"'“”")
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// findQuote finds a normalized quote in text, lower-cased and with whitespace
+// collapsed the same way, and returns the matching span of the original text
+func findQuote(text, quote string) (string, bool) {
+	var normalized strings.Builder
+	var starts, ends []int // the original span behind each normalized byte
+	space := -1            // start of a whitespace run not yet written
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			if normalized.Len() > 0 && space < 0 {
+				space = i
+			}
+			i += size
+			continue
+		}
+		if space >= 0 {
+			normalized.WriteByte(' ')
+			starts, ends = append(starts, space), append(ends, i)
+			space = -1
+		}
+		lower := strings.ToLower(text[i : i+size])
+		normalized.WriteString(lower)
+		for range []byte(lower) {
+			starts, ends = append(starts, i), append(ends, i+size)
+		}
+		i += size
+	}
+
+	at := strings.Index(normalized.String(), quote)
+	if quote == "" || at < 0 {
+		return "", false
+	}
+	return text[starts[at]:ends[at+len(quote)-1]], true
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestVerifyReturnsTheReviewsOwnText(t *testing.T) {
+	idx := newReviewIndex(nil, []Review{{ID: "r1", ReviewText: "Love it!  The new Dashboard\nis SO fast. Café crème too."}})
+	cases := []struct {
+		quote string
+		want  string
+	}{
+		{`"the new dashboard is so fast"`, "The new Dashboard\nis SO fast"},
+		{"LOVE IT!", "Love it!"},
+		{"café CRÈME", "Café crème"},
+		{"fast.", "fast."},
+	}
+	for _, c := range cases {
+		got, ok := idx.verify(Evidence{ReviewID: "r1", Quote: c.quote})
+		if !ok || got.Quote != c.want || got.Phase != "post_launch" {
+			t.Errorf("verify(%q) = %+v, %v; want quote %q", c.quote, got, ok, c.want)
+		}
+	}
+	for _, quote := range []string{"the old dashboard", "", "fast. café crème too. more"} {
+		if _, ok := idx.verify(Evidence{ReviewID: "r1", Quote: quote}); ok {
+			t.Errorf("verify(%q) found a quote that is not in the review", quote)
+		}
+	}
+	if _, ok := idx.verify(Evidence{ReviewID: "r2", Quote: "love it"}); ok {
+		t.Error("verify found a quote in an unknown review")
+	}
+}
+
+func TestVerifyAspectEvidenceUsesTheReviewSpan(t *testing.T) {
+	idx := newReviewIndex([]Review{{ID: "r1", ReviewText: "Search is Broken again"}}, nil)
+	aspects := []AspectSentiment{
+		{ReviewID: "r1", Phase: "pre_launch", Evidence: "search is broken"},
+		{ReviewID: "r1", Phase: "pre_launch", Evidence: "export is broken"},
+	}
+	if dropped := verifyAspectEvidence(idx, aspects); dropped != 1 {
+		t.Fatalf("dropped %d, want 1", dropped)
+	}
+	if aspects[0].Evidence != "Search is Broken" || aspects[1].Evidence != "" {
+		t.Fatalf("evidence = %q, %q", aspects[0].Evidence, aspects[1].Evidence)
+	}
+}
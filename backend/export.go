@@ -0,0 +1,361 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Export formats
+const (
+	exportCSV     = "csv"
+	exportXLSX    = "xlsx"
+	exportParquet = "parquet"
+)
+
+// Exportable tables
+const (
+	tableReviews = "reviews"
+	tableThemes  = "themes"
+)
+
+// columnKind is the type of an exported column's values
+type columnKind int
+
+const (
+	textColumn  columnKind = iota // string
+	intColumn                     // int
+	floatColumn                   // float64
+	boolColumn                    // bool
+)
+
+// ExportColumn names and types one column of an exported table
+type ExportColumn struct {
+	Name string
+	Kind columnKind
+}
+
+// reviewColumns is the per-review table: review fields plus the analysis
+// labels. A sampled run exports only the sampled reviews, so every row has a
+// sentiment
+var reviewColumns = []ExportColumn{
+	{"phase", textColumn},
+	{"id", textColumn},
+	{"date", textColumn},
+	{"user_id", textColumn},
+	{"source", textColumn},
+	{"rating", intColumn},
+	{"review_text", textColumn},
+	{"sentiment", textColumn},
+	{"sentiment_score", floatColumn},
+	{"overridden", boolColumn},
+	{"themes", textColumn}, // semicolon-separated, as in the labeled dataset
+}
+
+// themeColumns is the theme table, including sub-themes and the long tail
+var themeColumns = []ExportColumn{
+	{"theme", textColumn},
+	{"parent", textColumn},
+	{"pre_count", intColumn},
+	{"post_count", intColumn},
+	{"change_rate", floatColumn},
+	{"pre_positive", intColumn},
+	{"pre_negative", intColumn},
+	{"pre_neutral", intColumn},
+	{"post_positive", intColumn},
+	{"post_negative", intColumn},
+	{"post_neutral", intColumn},
+	{"proposed", boolColumn},
+	{"highlighted", boolColumn},
+	{"long_tail", boolColumn},
+}
+
+// TableWriter streams the rows of one table in a file format. Row values
+// follow the column order and use the Go type of each column's kind
+type TableWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error // finishes the file; the underlying writer is left open
+}
+
+// newTableWriter creates a writer for the format and writes the header
+func newTableWriter(format string, w io.Writer, name string, columns []ExportColumn) (TableWriter, error) {
+	switch format {
+	case exportCSV:
+		return newCSVTableWriter(w, columns)
+	case exportXLSX:
+		return newXLSXTableWriter(w, name, columns)
+	case exportParquet:
+		return newParquetTableWriter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q (use csv, xlsx or parquet)", format)
+	}
+}
+
+// ExportTable writes one table of an analysis result to w
+func ExportTable(result *AnalysisResult, table, format string, w io.Writer) error {
+	var columns []ExportColumn
+	var rows func(TableWriter) error
+	switch table {
+	case tableReviews:
+		columns, rows = reviewColumns, func(tw TableWriter) error { return writeReviewRows(result, tw) }
+	case tableThemes:
+		columns, rows = themeColumns, func(tw TableWriter) error { return writeThemeRows(result.Comparison, tw) }
+	default:
+		return fmt.Errorf("unknown export table %q (use reviews or themes)", table)
+	}
+
+	tw, err := newTableWriter(format, w, table, columns)
+	if err != nil {
+		return err
+	}
+	if err := rows(tw); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// exportFilename is the download name for a table in a format
+func exportFilename(table, format string) string {
+	return table + "." + format
+}
+
+// exportContentType is the MIME type of an export format
+func exportContentType(format string) string {
+	switch format {
+	case exportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case exportParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+// writeReviewRows writes one row per analyzed review, both phases
+func writeReviewRows(result *AnalysisResult, tw TableWriter) error {
+	themes := reviewThemes(result.Comparison)
+	for _, collection := range []ReviewCollection{result.PreLaunchReviews, result.PostLaunchReviews} {
+		sentiments := make(map[string]SentimentResult, len(collection.Sentiments))
+		for _, s := range collection.Sentiments {
+			sentiments[s.ReviewID] = s
+		}
+		for _, r := range collection.Reviews {
+			s := sentiments[r.ID]
+			row := []interface{}{
+				collection.Type, r.ID, r.Date, r.UserID, r.Source, r.Rating, r.ReviewText,
+				s.Sentiment, s.Score, s.Overridden, strings.Join(themes[collection.Type][r.ID], ";"),
+			}
+			if err := tw.WriteRow(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeThemeRows writes the reported themes and then the long tail, each
+// parent followed by its sub-themes
+func writeThemeRows(comparison ComparisonResult, tw TableWriter) error {
+	write := func(themes []ThemeResult, longTail bool) error {
+		for _, t := range flattenThemes(themes) {
+			row := []interface{}{
+				t.Theme, t.Parent, t.PreCount, t.PostCount, t.ChangeRate,
+				t.PreSentiment.Positive, t.PreSentiment.Negative, t.PreSentiment.Neutral,
+				t.PostSentiment.Positive, t.PostSentiment.Negative, t.PostSentiment.Neutral,
+				t.Proposed, t.Highlighted, longTail,
+			}
+			if err := tw.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := write(comparison.Themes, false); err != nil {
+		return err
+	}
+	if comparison.LongTail != nil {
+		return write(comparison.LongTail.Themes, true)
+	}
+	return nil
+}
+
+// reviewThemes maps phase -> review ID -> the themes the review was assigned to
+func reviewThemes(comparison ComparisonResult) map[string]map[string][]string {
+	themes := map[string]map[string][]string{"pre_launch": {}, "post_launch": {}}
+	for _, t := range flattenThemes(allThemes(comparison)) {
+		for _, id := range t.PreReviewIDs {
+			themes["pre_launch"][id] = append(themes["pre_launch"][id], t.Theme)
+		}
+		for _, id := range t.PostReviewIDs {
+			themes["post_launch"][id] = append(themes["post_launch"][id], t.Theme)
+		}
+	}
+	return themes
+}
+
+// formatCell renders a value as text for the text-based formats
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvTableWriter writes rows as CSV with a header line
+type csvTableWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVTableWriter(w io.Writer, columns []ExportColumn) (*csvTableWriter, error) {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	t := &csvTableWriter{writer: csv.NewWriter(w), record: make([]string, len(columns))}
+	return t, t.writer.Write(header)
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	for i, v := range values {
+		t.record[i] = formatCell(v)
+		if _, text := v.(string); text {
+			t.record[i] = escapeFormula(t.record[i])
+		}
+	}
+	return t.writer.Write(t.record)
+}
+
+// escapeFormula prefixes text a spreadsheet would evaluate as a formula with a
+// quote, so review text cannot inject formulas into the opened export. Numbers
+// are written unescaped, so negative change rates stay numeric
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (t *csvTableWriter) Close() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+// xlsxTableWriter writes a single-sheet workbook. The fixed package parts are
+// written up front so the worksheet, the last zip entry, can be streamed
+type xlsxTableWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	columns []ExportColumn
+	row     int
+}
+
+// xlsxParts are the package parts every workbook needs besides the worksheet
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXTableWriter(w io.Writer, name string, columns []ExportColumn) (*xlsxTableWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		if err := writeZipEntry(archive, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + xmlEscape(name) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	if err := writeZipEntry(archive, "xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	t := &xlsxTableWriter{archive: archive, sheet: sheet, columns: columns}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	return t, t.WriteRow(header)
+}
+
+func (t *xlsxTableWriter) WriteRow(values []interface{}) error {
+	t.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, t.row)
+	for i, v := range values {
+		ref := xlsxColumnName(i) + strconv.Itoa(t.row)
+		switch v := v.(type) {
+		case int, float64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, formatCell(v))
+		case bool:
+			flag := 0
+			if v {
+				flag = 1
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, flag)
+		default:
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(formatCell(v)))
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(t.sheet, b.String())
+	return err
+}
+
+func (t *xlsxTableWriter) Close() error {
+	if _, err := io.WriteString(t.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return t.archive.Close()
+}
+
+// writeZipEntry adds a small, fully known file to an archive
+func writeZipEntry(archive *zip.Writer, name, content string) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(entry, content)
+	return err
+}
+
+// xlsxColumnName converts a zero-based column index to a spreadsheet column (A, B, ... AA)
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML, replacing characters XML cannot represent
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCSVTableWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := newCSVTableWriter(&buf, []ExportColumn{{Name: "text"}, {Name: "change_rate"}})
+	if err != nil {
+		t.Fatalf("newCSVTableWriter: %v", err)
+	}
+	cells := map[string]string{
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1+1":                     "'+1+1",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\tcmd":                    "'\tcmd",
+		"\rcmd":                    "'\rcmd",
+		"fine = ok":                "fine = ok",
+		"":                         "",
+	}
+	var inputs []string
+	for cell := range cells {
+		inputs = append(inputs, cell)
+		if err := writer.WriteRow([]interface{}{cell, -12.5}); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	for i, record := range records[1:] {
+		if want := cells[inputs[i]]; record[0] != want {
+			t.Errorf("cell %q written as %q, want %q", inputs[i], record[0], want)
+		}
+		if record[1] != "-12.5" {
+			t.Errorf("number written as %q, want it unescaped", record[1])
+		}
+	}
+}
+
+// thriftReader decodes Thrift compact structs into field ID -> value maps,
+// enough to read back the Parquet metadata the exporter writes
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (t thriftReader) varint() int64 {
+	v, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		panic(err)
+	}
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return t.varint()
+	case thriftBinary:
+		n, err := binary.ReadUvarint(t.r)
+		if err != nil {
+			panic(err)
+		}
+		b := make([]byte, n)
+		io.ReadFull(t.r, b)
+		return string(b)
+	case thriftList:
+		header, _ := t.r.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(t.r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = t.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return t.structure()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", typ))
+}
+
+func (t thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header, err := t.r.ReadByte()
+		if err != nil {
+			panic(err)
+		}
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(t.varint())
+		}
+		fields[id] = t.value(header & 0x0f)
+	}
+}
+
+func TestParquetTableWriterRoundTrip(t *testing.T) {
+	columns := []ExportColumn{{"id", textColumn}, {"rating", intColumn}, {"score", floatColumn}, {"overridden", boolColumn}}
+	rows := parquetRowGroupSize + 3 // spills into a second row group
+	var buf bytes.Buffer
+	writer := newParquetTableWriter(&buf, columns)
+	for i := 0; i < rows; i++ {
+		if err := writer.WriteRow([]interface{}{fmt.Sprintf("r%d", i), i % 5, float64(i) / 4, i%3 == 0}); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	file := buf.Bytes()
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("file is not framed by PAR1")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := thriftReader{bytes.NewReader(file[len(file)-8-footerLength : len(file)-8])}.structure()
+
+	if meta[3] != int64(rows) {
+		t.Fatalf("num_rows = %v, want %d", meta[3], rows)
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(columns)+1 || schema[0].(map[int16]interface{})[5] != int64(len(columns)) {
+		t.Fatalf("schema = %v, want a root with %d children", schema, len(columns))
+	}
+	for i, c := range columns {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != c.Name || element[1] != int64(parquetType(c.Kind)) || element[3] != int64(parquetRequired) {
+			t.Errorf("schema element %d = %v, want required %s", i, element, c.Name)
+		}
+	}
+
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("got %d row groups, want 2", len(groups))
+	}
+	// Read every column of the second row group back from its data page
+	group := groups[1].(map[int16]interface{})
+	chunks := group[1].([]interface{})
+	if group[3] != int64(3) || len(chunks) != len(columns) {
+		t.Fatalf("second row group = %v, want 3 rows in %d chunks", group, len(columns))
+	}
+	for i, c := range columns {
+		chunk := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		page := bytes.NewReader(file[chunk[9].(int64):])
+		header := thriftReader{page}.structure()
+		if header[1] != int64(parquetDataPage) || header[5].(map[int16]interface{})[1] != int64(3) {
+			t.Fatalf("%s page header = %v, want a data page of 3 values", c.Name, header)
+		}
+		values := make([]byte, header[3].(int64))
+		if _, err := io.ReadFull(page, values); err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		if size := int64(len(file[chunk[9].(int64):]) - page.Len()); size != chunk[6] {
+			t.Errorf("%s chunk is %d bytes, metadata says %v", c.Name, size, chunk[6])
+		}
+
+		var got []string
+		for j := 0; j < 3; j++ {
+			switch c.Kind {
+			case textColumn:
+				n := binary.LittleEndian.Uint32(values)
+				got, values = append(got, string(values[4:4+n])), values[4+n:]
+			case intColumn:
+				got, values = append(got, fmt.Sprint(int64(binary.LittleEndian.Uint64(values)))), values[8:]
+			case floatColumn:
+				got, values = append(got, fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(values)))), values[8:]
+			case boolColumn:
+				got = append(got, fmt.Sprint(values[0]&(1<<j) != 0))
+			}
+		}
+		var want []string
+		for j := parquetRowGroupSize; j < rows; j++ {
+			want = append(want, fmt.Sprint([]interface{}{fmt.Sprintf("r%d", j), j % 5, float64(j) / 4, j%3 == 0}[i]))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s = %v, want %v", c.Name, got, want)
+		}
+	}
+}
+
+func TestXLSXTableWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := newXLSXTableWriter(&buf, "reviews & more", []ExportColumn{{"text", textColumn}, {"rating", intColumn}, {"score", floatColumn}, {"overridden", boolColumn}})
+	if err != nil {
+		t.Fatalf("newXLSXTableWriter: %v", err)
+	}
+	if err := writer.WriteRow([]interface{}{"=1+1 <b> & \"quotes\"\x01", 4, -0.25, true}); err != nil {
+		t.Fatalf("WriteRow: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/workbook.xml"]), &workbook); err != nil || len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "reviews & more" {
+		t.Fatalf("workbook = %+v (%v), want one sheet named after the table", workbook, err)
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     string `xml:"r,attr"`
+			Cells []struct {
+				R      string `xml:"r,attr"`
+				T      string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatalf("sheet XML: %v", err)
+	}
+	if len(sheet.Rows) != 2 || sheet.Rows[1].R != "2" {
+		t.Fatalf("sheet has rows %+v, want the header and one row", sheet.Rows)
+	}
+	var cells []string
+	for _, row := range sheet.Rows {
+		for _, c := range row.Cells {
+			cells = append(cells, fmt.Sprintf("%s[%s]=%s%s", c.R, c.T, c.Value, c.Inline))
+		}
+	}
+	want := []string{
+		"A1[inlineStr]=text", "B1[inlineStr]=rating", "C1[inlineStr]=score", "D1[inlineStr]=overridden",
+		"A2[inlineStr]==1+1 <b> & \"quotes\"\uFFFD", "B2[]=4", "C2[]=-0.25", "D2[b]=1",
+	}
+	if strings.Join(cells, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("cells =\n%s\nwant\n%s", strings.Join(cells, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(i); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -20,21 +20,61 @@ type GroqClient struct {
 	apiKey  string
 	baseURL string
 	model   string
+	config  GenerationConfig
+	prompts *PromptRegistry
+	calls   *callLog
 }
 
 // NewGroqClient creates a new Groq client instance
-func NewGroqClient(apiKey string) *GroqClient {
+func NewGroqClient(apiKey string, config GenerationConfig, prompts *PromptRegistry) *GroqClient {
 	return &GroqClient{
 		apiKey:  apiKey,
 		baseURL: "https://api.groq.com/openai/v1",
-		model:   "llama-3.3-70b-versatile",
+		model:   config.Model,
+		config:  config,
+		prompts: prompts,
+		calls:   &callLog{},
+	}
+}
+
+// Version identifies the model, generation settings and prompt used for
+// sentiment analysis, so changing any of them invalidates cached sentiments
+func (g *GroqClient) Version() string {
+	return g.model + "/" + g.config.fingerprint() + "/" + g.prompts.Fingerprint(promptSentiment)
+}
+
+// NewSession returns a client with its own call log and a frozen copy of the prompt templates
+func (g *GroqClient) NewSession() LLMAnalyzer {
+	session := *g
+	session.prompts = g.prompts.Snapshot()
+	session.calls = &callLog{}
+	return &session
+}
+
+// Calls returns the provider calls recorded by this client
+func (g *GroqClient) Calls() []LLMCall {
+	return g.calls.list()
+}
+
+// Describe reports the settings used to generate this client's output
+func (g *GroqClient) Describe() GenerationInfo {
+	return GenerationInfo{
+		Provider:      "groq",
+		Model:         g.model,
+		Parameters:    g.config,
+		PromptVersion: g.prompts.Fingerprint(),
+		Prompts:       g.prompts.Versions(),
 	}
 }
 
 // GroqRequest represents the request payload for Groq API
 type GroqRequest struct {
-	Model    string        `json:"model"`
-	Messages []GroqMessage `json:"messages"`
+	Model          string              `json:"model"`
+	Messages       []GroqMessage       `json:"messages"`
+	Temperature    *float64            `json:"temperature,omitempty"`
+	Seed           *int                `json:"seed,omitempty"`
+	MaxTokens      int                 `json:"max_tokens,omitempty"`
+	ResponseFormat *GroqResponseFormat `json:"response_format,omitempty"`
 }
 
 // GroqMessage represents a message in Groq request
@@ -43,22 +83,32 @@ type GroqMessage struct {
 	Content string `json:"content"`
 }
 
+// GroqResponseFormat constrains the shape of the model output
+type GroqResponseFormat struct {
+	Type string `json:"type"`
+}
+
 // GroqResponse represents the response from Groq API
 type GroqResponse struct {
-	Choices []struct {
+	ID                string `json:"id"`
+	Model             string `json:"model"`
+	SystemFingerprint string `json:"system_fingerprint"`
+	Choices           []struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage TokenUsage `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
 // callGroqAPI makes a request to the Groq API
-func (g *GroqClient) callGroqAPI(prompt string) (string, error) {
+func (g *GroqClient) callGroqAPI(operation, prompt string) (string, error) {
 	url := fmt.Sprintf("%s/chat/completions", g.baseURL)
 
+	temperature := g.config.Temperature
 	request := GroqRequest{
 		Model: g.model,
 		Messages: []GroqMessage{
@@ -67,6 +117,12 @@ func (g *GroqClient) callGroqAPI(prompt string) (string, error) {
 				Content: prompt,
 			},
 		},
+		Temperature: &temperature,
+		Seed:        g.config.Seed,
+		MaxTokens:   g.config.MaxTokens,
+	}
+	if g.config.ResponseFormat != "" {
+		request.ResponseFormat = &GroqResponseFormat{Type: g.config.ResponseFormat}
 	}
 
 	jsonData, err := json.Marshal(request)
@@ -107,6 +163,17 @@ func (g *GroqClient) callGroqAPI(prompt string) (string, error) {
 		return "", fmt.Errorf("Groq API error: %s", groqResp.Error.Message)
 	}
 
+	if groqResp.Model == "" {
+		groqResp.Model = g.model
+	}
+	g.calls.add(LLMCall{
+		Operation:         operation,
+		ResponseID:        groqResp.ID,
+		Model:             groqResp.Model,
+		SystemFingerprint: groqResp.SystemFingerprint,
+		Usage:             groqResp.Usage,
+	})
+
 	if len(groqResp.Choices) == 0 {
 		return "", fmt.Errorf("empty response from Groq")
 	}
@@ -120,21 +187,14 @@ func (g *GroqClient) AnalyzeSentiments(reviews []Review) ([]SentimentResult, err
 		return []SentimentResult{}, nil
 	}
 
-	// Prepare reviews text for analysis
-	reviewsText := ""
-	for _, r := range reviews {
-		reviewsText += fmt.Sprintf("ID: %s | Rating: %d | Review: %s\n", r.ID, r.Rating, r.ReviewText)
+	prompt, err := g.renderPrompt(promptSentiment, map[string]interface{}{
+		"Reviews": formatReviewsForSentiment(reviews),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	prompt := fmt.Sprintf(`Analyze the sentiment of these customer reviews. For each review, classify as "positive", "negative", or "neutral" with a confidence score (0-1).
-
-Reviews:
-%s
-
-Respond ONLY with a valid JSON array in this exact format (no markdown, no explanation):
-[{"review_id": "id", "sentiment": "positive/negative/neutral", "score": 0.95}]`, reviewsText)
-
-	response, err := g.callGroqAPI(prompt)
+	response, err := g.callGroqAPI("sentiment", prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -152,23 +212,25 @@ Respond ONLY with a valid JSON array in this exact format (no markdown, no expla
 
 // ExtractThemes extracts and compares themes between pre and post launch reviews
 func (g *GroqClient) ExtractThemes(preReviews, postReviews []Review) ([]ThemeResult, error) {
+	return g.ExtractThemesFor(preReviews, postReviews, ThemeRequest{TopN: defaultThemeTopN})
+}
+
+// ExtractThemesFor extracts the requested number of themes, preferring the canonical names of the taxonomy
+func (g *GroqClient) ExtractThemesFor(preReviews, postReviews []Review, request ThemeRequest) ([]ThemeResult, error) {
 	preText := formatReviewsForThemes(preReviews)
 	postText := formatReviewsForThemes(postReviews)
 
-	prompt := fmt.Sprintf(`Analyze and compare themes between pre-launch and post-launch customer reviews.
-
-PRE-LAUNCH REVIEWS:
-%s
-
-POST-LAUNCH REVIEWS:
-%s
-
-Extract the top 8 themes mentioned across both sets. For each theme, count occurrences in pre and post launch, calculate percentage change, and determine overall sentiment.
-
-Respond ONLY with a valid JSON array in this exact format (no markdown, no explanation):
-[{"theme": "theme name", "pre_count": 5, "post_count": 8, "change_rate": 60.0, "sentiment": "positive/negative/neutral"}]`, preText, postText)
+	prompt, err := g.renderPrompt(promptThemes, map[string]interface{}{
+		"PreReviews":  preText,
+		"PostReviews": postText,
+		"Taxonomy":    formatTaxonomyForThemes(request.Taxonomy),
+		"TopN":        request.TopN,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	response, err := g.callGroqAPI(prompt)
+	response, err := g.callGroqAPI("themes", prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -185,48 +247,34 @@ Respond ONLY with a valid JSON array in this exact format (no markdown, no expla
 
 // GenerateImpactSummary generates an executive summary of the launch impact
 func (g *GroqClient) GenerateImpactSummary(pre, post ReviewCollection, comparison ComparisonResult) (*ImpactSummary, error) {
-	prompt := fmt.Sprintf(`You are analyzing the impact of a feature launch based on customer reviews.
-
-PRE-LAUNCH DATA:
-- Total reviews: %d
-- Positive: %d, Negative: %d, Neutral: %d
-- Average rating: %.2f
-
-POST-LAUNCH DATA:
-- Total reviews: %d
-- Positive: %d, Negative: %d, Neutral: %d
-- Average rating: %.2f
-
-SENTIMENT SHIFT: %.2f%%
-
-KEY THEMES IDENTIFIED:
-%s
-
-Based on this data, provide a comprehensive launch impact analysis.
-
-Respond ONLY with a valid JSON object in this exact format (no markdown, no explanation):
-{
-  "overall_success": true/false,
-  "success_score": 75.5,
-  "key_improvements": ["improvement 1", "improvement 2"],
-  "critical_issues": ["issue 1", "issue 2"],
-  "recommendations": ["recommendation 1", "recommendation 2"],
-  "executive_summary": "A 2-3 sentence summary of the launch impact"
-}`,
-		pre.Count,
-		comparison.PreLaunchSentiment.Positive,
-		comparison.PreLaunchSentiment.Negative,
-		comparison.PreLaunchSentiment.Neutral,
-		comparison.PreLaunchSentiment.Average,
-		post.Count,
-		comparison.PostLaunchSentiment.Positive,
-		comparison.PostLaunchSentiment.Negative,
-		comparison.PostLaunchSentiment.Neutral,
-		comparison.PostLaunchSentiment.Average,
-		comparison.SentimentShift,
-		formatThemesForSummary(comparison.Themes))
-
-	response, err := g.callGroqAPI(prompt)
+	score, _ := computeSuccessScore(comparison)
+	verdict := "successful launch"
+	if score < successThreshold {
+		verdict = "unsuccessful launch"
+	}
+
+	prompt, err := g.renderPrompt(promptImpact, map[string]interface{}{
+		"PreCount":       pre.Count,
+		"PrePositive":    comparison.PreLaunchSentiment.Positive,
+		"PreNegative":    comparison.PreLaunchSentiment.Negative,
+		"PreNeutral":     comparison.PreLaunchSentiment.Neutral,
+		"PreAverage":     comparison.PreLaunchSentiment.Average,
+		"PostCount":      post.Count,
+		"PostPositive":   comparison.PostLaunchSentiment.Positive,
+		"PostNegative":   comparison.PostLaunchSentiment.Negative,
+		"PostNeutral":    comparison.PostLaunchSentiment.Neutral,
+		"PostAverage":    comparison.PostLaunchSentiment.Average,
+		"SentimentShift": comparison.SentimentShift,
+		"Themes":         formatThemesForSummary(comparison.Themes),
+		"Quotes":         formatQuotesForSummary(comparison.Themes),
+		"SuccessScore":   score,
+		"Verdict":        verdict,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := g.callGroqAPI("impact", prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -241,6 +289,15 @@ Respond ONLY with a valid JSON object in this exact format (no markdown, no expl
 	return &result, nil
 }
 
+// renderPrompt renders a named template from the client's prompt registry
+func (g *GroqClient) renderPrompt(name string, data map[string]interface{}) (string, error) {
+	t, err := g.prompts.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(data)
+}
+
 // Helper functions
 
 func cleanJSONResponse(response string) string {
@@ -271,6 +328,14 @@ func removeSuffix(s, suffix string) string {
 	return s
 }
 
+func formatReviewsForSentiment(reviews []Review) string {
+	result := ""
+	for _, r := range reviews {
+		result += fmt.Sprintf("ID: %s | Rating: %d | Review: %s\n", r.ID, r.Rating, r.ReviewText)
+	}
+	return result
+}
+
 func formatReviewsForThemes(reviews []Review) string {
 	result := ""
 	for _, r := range reviews {
@@ -281,9 +346,15 @@ func formatReviewsForThemes(reviews []Review) string {
 
 func formatThemesForSummary(themes []ThemeResult) string {
 	result := ""
-	for _, t := range themes {
-		result += fmt.Sprintf("- %s: Pre=%d, Post=%d, Change=%.1f%%, Sentiment=%s\n",
-			t.Theme, t.PreCount, t.PostCount, t.ChangeRate, t.Sentiment)
+	for _, t := range flattenThemes(themes) {
+		indent := "- "
+		if t.Parent != "" {
+			indent = "  - "
+		}
+		result += fmt.Sprintf("%s%s: Pre=%d, Post=%d, Change=%.1f%%, Pre sentiment=+%d/-%d/~%d, Post sentiment=+%d/-%d/~%d\n",
+			indent, t.Theme, t.PreCount, t.PostCount, t.ChangeRate,
+			t.PreSentiment.Positive, t.PreSentiment.Negative, t.PreSentiment.Neutral,
+			t.PostSentiment.Positive, t.PostSentiment.Negative, t.PostSentiment.Neutral)
 	}
 	return result
 }
@@ -5,4 +5,5 @@ go 1.21
 require (
 	github.com/google/generative-ai-go v0.7.0
 	google.golang.org/api v0.150.0
+	gopkg.in/yaml.v3 v3.0.1
 )
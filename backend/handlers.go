@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,7 +23,15 @@ type ReviewParser interface {
 
 // AnalysisService defines the interface for the analysis service
 type AnalysisService interface {
-	Analyze(preReviews, postReviews []Review) (*AnalysisResult, error)
+	Analyze(preReviews, postReviews []Review, opts AnalysisOptions) (*AnalysisResult, error)
+	Estimate(preReviews, postReviews []Review, opts AnalysisOptions) (*CostEstimate, error)
+}
+
+// AnalysisOptions carries per-request settings for an analysis
+type AnalysisOptions struct {
+	APIKey   string          // caller's key, used for per-key budgets
+	Sampling *SamplingConfig // overrides the service's sampling settings when set
+	Themes   *ThemeConfig    // overrides the service's theme thresholds when set
 }
 
 // CSVReviewParser implements ReviewParser for CSV files
@@ -31,7 +45,7 @@ func NewCSVReviewParser() *CSVReviewParser {
 // ParseCSV parses CSV data into Review structs
 func (p *CSVReviewParser) ParseCSV(reader io.Reader) ([]Review, error) {
 	csvReader := csv.NewReader(reader)
-	
+
 	// Read header
 	header, err := csvReader.Read()
 	if err != nil {
@@ -57,29 +71,7 @@ func (p *CSVReviewParser) ParseCSV(reader io.Reader) ([]Review, error) {
 			return nil, fmt.Errorf("error reading line %d: %w", lineNum, err)
 		}
 
-		review := Review{}
-
-		// Parse each field with safe access
-		if idx, ok := colIndex["id"]; ok && idx < len(record) {
-			review.ID = record[idx]
-		}
-		if idx, ok := colIndex["date"]; ok && idx < len(record) {
-			review.Date = record[idx]
-		}
-		if idx, ok := colIndex["user_id"]; ok && idx < len(record) {
-			review.UserID = record[idx]
-		}
-		if idx, ok := colIndex["review_text"]; ok && idx < len(record) {
-			review.ReviewText = record[idx]
-		}
-		if idx, ok := colIndex["rating"]; ok && idx < len(record) {
-			if rating, err := strconv.Atoi(record[idx]); err == nil {
-				review.Rating = rating
-			}
-		}
-		if idx, ok := colIndex["source"]; ok && idx < len(record) {
-			review.Source = record[idx]
-		}
+		review := reviewFromRecord(colIndex, record)
 
 		reviews = append(reviews, review)
 	}
@@ -87,52 +79,291 @@ func (p *CSVReviewParser) ParseCSV(reader io.Reader) ([]Review, error) {
 	return reviews, nil
 }
 
+// reviewFromRecord maps a CSV record onto a Review using the header column index
+func reviewFromRecord(colIndex map[string]int, record []string) Review {
+	review := Review{}
+
+	// Parse each field with safe access
+	if idx, ok := colIndex["id"]; ok && idx < len(record) {
+		review.ID = record[idx]
+	}
+	if idx, ok := colIndex["date"]; ok && idx < len(record) {
+		review.Date = record[idx]
+	}
+	if idx, ok := colIndex["user_id"]; ok && idx < len(record) {
+		review.UserID = record[idx]
+	}
+	if idx, ok := colIndex["review_text"]; ok && idx < len(record) {
+		review.ReviewText = record[idx]
+	}
+	if idx, ok := colIndex["rating"]; ok && idx < len(record) {
+		if rating, err := strconv.Atoi(record[idx]); err == nil {
+			review.Rating = rating
+		}
+	}
+	if idx, ok := colIndex["source"]; ok && idx < len(record) {
+		review.Source = record[idx]
+	}
+
+	return review
+}
+
+// writeReviewsCSV writes reviews with the columns ParseCSV reads
+func writeReviewsCSV(path string, reviews []Review) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"id", "date", "user_id", "review_text", "rating", "source"})
+	for _, r := range reviews {
+		writer.Write([]string{r.ID, r.Date, r.UserID, r.ReviewText, strconv.Itoa(r.Rating), r.Source})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 // DefaultAnalysisService implements AnalysisService
 type DefaultAnalysisService struct {
 	llmClient LLMAnalyzer
+	cache     SentimentCache
+	usage     *UsageLedger
+	budget    *BudgetGuard
+	sampling  SamplingConfig
+	themes    ThemeConfig
+	taxonomy  *ThemeTaxonomy
+	embedder  Embedder
+	clusters  ClusterConfig
+	issues    IssueConfig
+	overrides *OverrideStore
+}
+
+// AnalysisDependencies holds the optional components of an analysis service;
+// nil cache, budget, taxonomy, embedder and overrides disable those features
+type AnalysisDependencies struct {
+	Cache     SentimentCache
+	Usage     *UsageLedger
+	Budget    *BudgetGuard
+	Sampling  SamplingConfig
+	Themes    ThemeConfig
+	Taxonomy  *ThemeTaxonomy
+	Embedder  Embedder
+	Clusters  ClusterConfig
+	Issues    IssueConfig
+	Overrides *OverrideStore
 }
 
 // NewAnalysisService creates a new analysis service
-func NewAnalysisService(llmClient LLMAnalyzer) *DefaultAnalysisService {
+func NewAnalysisService(llmClient LLMAnalyzer, deps AnalysisDependencies) *DefaultAnalysisService {
 	return &DefaultAnalysisService{
 		llmClient: llmClient,
+		cache:     deps.Cache,
+		usage:     deps.Usage,
+		budget:    deps.Budget,
+		sampling:  deps.Sampling,
+		themes:    deps.Themes,
+		taxonomy:  deps.Taxonomy,
+		embedder:  deps.Embedder,
+		clusters:  deps.Clusters,
+		issues:    deps.Issues,
+		overrides: deps.Overrides,
+	}
+}
+
+// Estimate predicts the tokens and cost of analyzing the reviews without calling the LLM
+func (s *DefaultAnalysisService) Estimate(preReviews, postReviews []Review, opts AnalysisOptions) (*CostEstimate, error) {
+	config := s.samplingConfig(opts)
+	preSample, postSample := maybeSample(preReviews, config), maybeSample(postReviews, config)
+
+	estimate, err := s.estimate(reviewsOf(preSample, preReviews), reviewsOf(postSample, postReviews), opts)
+	if err != nil {
+		return nil, err
+	}
+	estimate.DatasetID = datasetID(preReviews, postReviews)
+	return estimate, nil
+}
+
+// estimate prices the reviews that would actually be sent to the LLM and checks the budget
+func (s *DefaultAnalysisService) estimate(preReviews, postReviews []Review, opts AnalysisOptions) (*CostEstimate, error) {
+	estimate := &CostEstimate{
+		Calls: []LLMCall{},
+	}
+	if s.budget != nil {
+		if err := s.budget.Authorize(apiKeyID(opts.APIKey)); err != nil {
+			return nil, err
+		}
+	}
+
+	estimator, ok := s.llmClient.(CostEstimator)
+	if !ok || s.usage == nil {
+		if s.budget != nil && s.budget.enabled() {
+			return nil, ErrBudgetUnpriced
+		}
+		return estimate, nil
+	}
+
+	// Reviews already in the sentiment cache will not be sent for sentiment again
+	version := analyzerVersion(s.llmClient)
+	config := s.themeConfig(opts)
+	plan := UsagePlan{
+		PreReviews:   preReviews,
+		PostReviews:  postReviews,
+		UncachedPre:  s.uncachedReviews(version, preReviews),
+		UncachedPost: s.uncachedReviews(version, postReviews),
+		TopN:         config.TopN,
+	}
+	if s.clustersEnabled(s.llmClient, config) {
+		plan.Clusters = clusterCount(len(preReviews)+len(postReviews), s.clusters)
+		plan.ClusterSamples = s.clusters.Samples
+	}
+	calls, err := estimator.EstimateUsage(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate usage: %w", err)
+	}
+	estimate.Calls = calls
+	estimate.Summary = s.usage.Summarize(calls)
+
+	if s.budget != nil {
+		decision := s.budget.Check(apiKeyID(opts.APIKey), estimate.Summary.CostUSD)
+		estimate.Budget = &decision
 	}
+
+	return estimate, nil
 }
 
 // Analyze performs the complete analysis of pre and post launch reviews
-func (s *DefaultAnalysisService) Analyze(preReviews, postReviews []Review) (*AnalysisResult, error) {
-	// Create review collections
+func (s *DefaultAnalysisService) Analyze(preReviews, postReviews []Review, opts AnalysisOptions) (*AnalysisResult, error) {
+	// Large phases are analyzed from a stratified sample and extrapolated back
+	config := s.samplingConfig(opts)
+	preSample, postSample := maybeSample(preReviews, config), maybeSample(postReviews, config)
+
+	estimate, err := s.estimate(reviewsOf(preSample, preReviews), reviewsOf(postSample, postReviews), opts)
+	if err != nil {
+		return nil, err
+	}
+	if decision := estimate.Budget; decision != nil {
+		switch decision.Action {
+		case budgetRefused:
+			return nil, &BudgetExceededError{Decision: *decision}
+		case budgetSampled:
+			preSample, postSample, estimate, err = s.shrinkToBudget(preReviews, postReviews, preSample, postSample, estimate, config, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Hold the estimated cost until the run is recorded, so concurrent runs and
+	// scheduled jobs see it as spent; a run that lost the race is refused
+	var reservation *Reservation
+	if estimate.Budget != nil {
+		var decision BudgetDecision
+		decision, reservation = s.budget.Reserve(apiKeyID(opts.APIKey), estimate.Summary.CostUSD)
+		if reservation == nil {
+			return nil, &BudgetExceededError{Decision: decision}
+		}
+		defer reservation.Release()
+	}
+	allPre, allPost := preReviews, postReviews
+	dataset := datasetID(allPre, allPost)
+	preReviews, postReviews = reviewsOf(preSample, preReviews), reviewsOf(postSample, postReviews)
+
+	// Create review collections; when sampled, Reviews holds the sample and Count the population
 	preCollection := ReviewCollection{
 		Reviews: preReviews,
 		Type:    "pre_launch",
-		Count:   len(preReviews),
+		Count:   len(allPre),
 	}
 	postCollection := ReviewCollection{
 		Reviews: postReviews,
 		Type:    "post_launch",
-		Count:   len(postReviews),
+		Count:   len(allPost),
+	}
+
+	// Use a per-analysis session so provider calls can be attributed to this run
+	llm := s.llmClient
+	if sa, ok := llm.(SessionAnalyzer); ok {
+		llm = sa.NewSession()
 	}
 
 	// Analyze sentiments for both collections
-	preSentiments, err := s.llmClient.AnalyzeSentiments(preReviews)
+	var cacheStats CacheStats
+	preSentiments, err := s.analyzeSentiments(llm, preReviews, &cacheStats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze pre-launch sentiments: %w", err)
 	}
 
-	postSentiments, err := s.llmClient.AnalyzeSentiments(postReviews)
+	postSentiments, err := s.analyzeSentiments(llm, postReviews, &cacheStats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze post-launch sentiments: %w", err)
 	}
 
+	// Reviewer overrides take precedence over the model's labels
+	overridesApplied := 0
+	if s.overrides != nil {
+		overridesApplied += s.overrides.applySentimentOverrides("pre_launch", preReviews, preSentiments)
+		overridesApplied += s.overrides.applySentimentOverrides("post_launch", postReviews, postSentiments)
+	}
+	preCollection.Sentiments, postCollection.Sentiments = preSentiments, postSentiments
+
 	// Calculate sentiment summaries
 	preSummary := calculateSentimentSummary(preSentiments, preReviews)
 	postSummary := calculateSentimentSummary(postSentiments, postReviews)
+	if preSample != nil {
+		preSample.extrapolate(preSentiments)
+		preSummary = preSample.summary(allPre)
+	}
+	if postSample != nil {
+		postSample.extrapolate(postSentiments)
+		postSummary = postSample.summary(allPost)
+	}
 
 	// Extract themes
-	themes, err := s.llmClient.ExtractThemes(preReviews, postReviews)
+	themeConfig := s.themeConfig(opts)
+	themes, clustering, err := s.extractThemes(llm, preReviews, postReviews, themeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract themes: %w", err)
 	}
+	if s.taxonomy != nil {
+		themes = s.taxonomy.Resolve(themes, dataset)
+	}
+
+	// Recount themes from per-review aspect sentiment when the analyzer supports it,
+	// keeping only evidence quotes that really occur in the cited review. Cluster
+	// membership already counts clustered themes, so only the representatives the
+	// LLM named them from are read for sentiment and evidence
+	reviews := newReviewIndex(preReviews, postReviews)
+	droppedQuotes := 0
+	aspects := []AspectSentiment{}
+	if aa, ok := llm.(AspectAnalyzer); ok {
+		aspectPre, aspectPost := preReviews, postReviews
+		if clustering != nil {
+			aspectPre, aspectPost = clustering.representatives()
+		}
+		aspects, err = extractAspects(aa, aspectPre, aspectPost, themes)
+		if err != nil {
+			return nil, err
+		}
+		droppedQuotes += verifyAspectEvidence(reviews, aspects)
+		if clustering != nil {
+			tallyThemeSentiment(themes, aspects, overallSentiments(preSentiments, postSentiments))
+		} else {
+			applyAspects(themes, aspects)
+		}
+	}
+	if s.overrides != nil {
+		themes = s.overrides.applyThemeOverrides(themes, preReviews, postReviews)
+		if len(aspects) > 0 {
+			tallyThemeSentiment(themes, aspects, overallSentiments(preSentiments, postSentiments))
+		}
+	}
+	attachThemeEvidence(themes, aspects)
+	themes = nestThemes(themes)
+	scaleThemeCounts(themes, preSample, postSample)
+	themes, longTail := applyThemeThresholds(themes, themeConfig)
 
 	// Calculate sentiment shift
 	sentimentShift := calculateSentimentShift(preSummary, postSummary)
@@ -142,25 +373,248 @@ func (s *DefaultAnalysisService) Analyze(preReviews, postReviews []Review) (*Ana
 		PostLaunchSentiment: postSummary,
 		SentimentShift:      sentimentShift,
 		Themes:              themes,
+		LongTail:            longTail,
 	}
 
 	// Generate impact summary
-	impact, err := s.llmClient.GenerateImpactSummary(preCollection, postCollection, comparison)
+	impact, err := llm.GenerateImpactSummary(preCollection, postCollection, comparison)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate impact summary: %w", err)
 	}
+	droppedQuotes += verifyImpactEvidence(reviews, impact)
+
+	// The score is computed here, never taken from the model, and any figure the
+	// narrative quotes must be traceable to the comparison
+	applySuccessScore(impact, comparison)
+	impact.UnverifiedNumbers = checkNarrativeNumbers(impact, comparison)
+	if len(impact.UnverifiedNumbers) > 0 {
+		log.Printf("⚠️  Impact narrative quotes %d number(s) not found in the data", len(impact.UnverifiedNumbers))
+	}
 
 	result := &AnalysisResult{
 		PreLaunchReviews:  preCollection,
 		PostLaunchReviews: postCollection,
 		Comparison:        comparison,
 		Impact:            *impact,
+		Aspects:           aspects,
+		NewIssues:         detectEmergingIssues(comparison, s.issues),
 		AnalyzedAt:        time.Now().Format(time.RFC3339),
+		Metadata:          buildMetadata(llm, cacheStats),
+	}
+
+	result.Metadata.DatasetID = dataset
+	result.Metadata.DroppedQuotes = droppedQuotes
+	result.Metadata.Overrides = overridesApplied
+	result.Metadata.Budget = estimate.Budget
+	result.Metadata.Clustering = clustering
+	if preSample != nil || postSample != nil {
+		result.Metadata.Sampling = &SamplingReport{
+			Seed:                 config.Seed,
+			DateBucket:           config.DateBucket,
+			Confidence:           0.95,
+			PreLaunch:            preSample,
+			PostLaunch:           postSample,
+			SentimentShiftMargin: rateChangeMargin(preSample, postSample, "positive"),
+			NegativeRateMargin:   rateChangeMargin(preSample, postSample, "negative"),
+		}
+	}
+	if s.usage != nil {
+		result.Metadata.Usage = s.usage.Summarize(result.Metadata.ProviderCalls)
+		s.usage.Record(result.Metadata.DatasetID, apiKeyID(opts.APIKey), result.Metadata.Usage, reservation)
 	}
 
 	return result, nil
 }
 
+// buildMetadata collects reproducibility details from the analyzer used for a run
+func buildMetadata(llm LLMAnalyzer, cacheStats CacheStats) AnalysisMetadata {
+	metadata := AnalysisMetadata{
+		SentimentCache: cacheStats,
+		ProviderCalls:  []LLMCall{},
+	}
+	if ra, ok := llm.(ReproducibleAnalyzer); ok {
+		info := ra.Describe()
+		metadata.Generation = &info
+	}
+	if cr, ok := llm.(CallRecorder); ok {
+		metadata.ProviderCalls = cr.Calls()
+	}
+	return metadata
+}
+
+// analyzeSentiments returns sentiments for reviews, sending only uncached reviews to the LLM
+func (s *DefaultAnalysisService) analyzeSentiments(llm LLMAnalyzer, reviews []Review, stats *CacheStats) ([]SentimentResult, error) {
+	if s.cache == nil {
+		stats.Misses += len(reviews)
+		stats.HitRate = hitRate(stats.Hits, stats.Misses)
+		return llm.AnalyzeSentiments(reviews)
+	}
+
+	version := analyzerVersion(llm)
+	results := make([]SentimentResult, 0, len(reviews))
+	var uncached []Review
+	for _, r := range reviews {
+		if cached, ok := s.cache.Get(sentimentCacheKey(version, r)); ok {
+			cached.ReviewID = r.ID
+			results = append(results, cached)
+			stats.Hits++
+			continue
+		}
+		uncached = append(uncached, r)
+	}
+	stats.Misses += len(uncached)
+	stats.HitRate = hitRate(stats.Hits, stats.Misses)
+
+	if len(uncached) == 0 {
+		return results, nil
+	}
+
+	fresh, err := llm.AnalyzeSentiments(uncached)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Review, len(uncached))
+	for _, r := range uncached {
+		byID[r.ID] = r
+	}
+	for _, f := range fresh {
+		if r, ok := byID[f.ReviewID]; ok {
+			s.cache.Set(sentimentCacheKey(version, r), f)
+		}
+		results = append(results, f)
+	}
+
+	return results, nil
+}
+
+// samplingConfig returns the request's sampling settings or the service default
+func (s *DefaultAnalysisService) samplingConfig(opts AnalysisOptions) SamplingConfig {
+	if opts.Sampling != nil {
+		return *opts.Sampling
+	}
+	return s.sampling
+}
+
+// themeConfig returns the per-request theme thresholds, falling back to the service defaults
+func (s *DefaultAnalysisService) themeConfig(opts AnalysisOptions) ThemeConfig {
+	if opts.Themes != nil {
+		return *opts.Themes
+	}
+	return s.themes
+}
+
+// extractThemes discovers themes by clustering when requested and supported,
+// otherwise asks the LLM for the configured number of themes, mapped onto the
+// approved taxonomy when the analyzer supports per-analysis settings
+func (s *DefaultAnalysisService) extractThemes(llm LLMAnalyzer, preReviews, postReviews []Review, config ThemeConfig) ([]ThemeResult, *ClusteringInfo, error) {
+	var taxonomy []TaxonomyTheme
+	if s.taxonomy != nil {
+		taxonomy = s.taxonomy.Approved()
+	}
+
+	if s.clustersEnabled(llm, config) {
+		info, err := clusterReviews(s.embedder, preReviews, postReviews, s.clusters)
+		if err != nil {
+			return nil, nil, err
+		}
+		names, err := llm.(ClusterNamer).NameClusters(info.Clusters, taxonomy)
+		if err != nil {
+			return nil, nil, err
+		}
+		return themesFromClusters(info, names), info, nil
+	}
+
+	ta, ok := llm.(ThemeRequestAnalyzer)
+	if !ok {
+		themes, err := llm.ExtractThemes(preReviews, postReviews)
+		return themes, nil, err
+	}
+	request := ThemeRequest{TopN: config.TopN, Taxonomy: taxonomy}
+	themes, err := ta.ExtractThemesFor(preReviews, postReviews, request)
+	return themes, nil, err
+}
+
+// clustersEnabled reports whether themes are discovered by clustering: it
+// must be requested, and needs an embedder and an analyzer that names clusters
+func (s *DefaultAnalysisService) clustersEnabled(llm LLMAnalyzer, config ThemeConfig) bool {
+	_, ok := llm.(ClusterNamer)
+	return ok && config.Discovery == discoveryClusters && s.embedder != nil
+}
+
+// shrinkToBudget redraws smaller samples until the run fits the budget and
+// returns them with their estimate. Theme, summary and impact calls cost about
+// the same however few reviews remain, so the proportional cut the budget
+// suggests can still miss; the two estimates then give the cost per review,
+// and the samples are redrawn once at the size it predicts. A run that still
+// does not fit is refused
+func (s *DefaultAnalysisService) shrinkToBudget(allPre, allPost []Review, preSample, postSample *PhaseSample, estimate *CostEstimate, config SamplingConfig, opts AnalysisOptions) (*PhaseSample, *PhaseSample, *CostEstimate, error) {
+	decision := *estimate.Budget
+	size := len(reviewsOf(preSample, allPre)) + len(reviewsOf(postSample, allPost))
+	fraction := decision.SampleFraction
+	for attempt := 0; attempt < 2; attempt++ {
+		pre := shrinkSample(allPre, preSample, fraction, config)
+		post := shrinkSample(allPost, postSample, fraction, config)
+		shrunk, err := s.estimate(reviewsOf(pre, allPre), reviewsOf(post, allPost), opts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		decision.EstimatedUSD = shrunk.Summary.CostUSD
+		if shrunk.Budget == nil || shrunk.Budget.Action == budgetAllowed {
+			decision.SampleFraction = fraction
+			shrunk.Budget = &decision
+			return pre, post, shrunk, nil
+		}
+
+		shrunkSize := len(reviewsOf(pre, allPre)) + len(reviewsOf(post, allPost))
+		if shrunkSize >= size {
+			break
+		}
+		perReview := (estimate.Summary.CostUSD - shrunk.Summary.CostUSD) / float64(size-shrunkSize)
+		fixed := shrunk.Summary.CostUSD - perReview*float64(shrunkSize)
+		if perReview <= 0 {
+			break
+		}
+		fraction = math.Floor((decision.RemainingUSD-fixed)/perReview/float64(size)*100) / 100
+		if fraction <= 0 {
+			break
+		}
+	}
+	decision.Action, decision.SampleFraction = budgetRefused, 0
+	return nil, nil, nil, &BudgetExceededError{Decision: decision}
+}
+
+// shrinkSample redraws a smaller stratified sample so a run fits the budget
+func shrinkSample(all []Review, current *PhaseSample, fraction float64, config SamplingConfig) *PhaseSample {
+	size := int(math.Floor(float64(len(reviewsOf(current, all))) * fraction))
+	if size < 1 {
+		size = 1
+	}
+	return drawStratifiedSample(all, size, config)
+}
+
+// uncachedReviews returns the reviews with no cached sentiment for the analyzer version
+func (s *DefaultAnalysisService) uncachedReviews(version string, reviews []Review) []Review {
+	if s.cache == nil {
+		return reviews
+	}
+	var uncached []Review
+	for _, r := range reviews {
+		if _, ok := s.cache.Get(sentimentCacheKey(version, r)); !ok {
+			uncached = append(uncached, r)
+		}
+	}
+	return uncached
+}
+
+// hitRate returns the fraction of lookups served from cache
+func hitRate(hits, misses int) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
 // calculateSentimentSummary calculates aggregated sentiment stats
 func calculateSentimentSummary(sentiments []SentimentResult, reviews []Review) SentimentSummary {
 	summary := SentimentSummary{}
@@ -176,13 +630,16 @@ func calculateSentimentSummary(sentiments []SentimentResult, reviews []Review) S
 		}
 	}
 
-	// Calculate average rating
-	if len(reviews) > 0 {
-		total := 0
-		for _, r := range reviews {
+	// Calculate average rating; reviews without stars (e.g. support tickets) are left out
+	total, rated := 0, 0
+	for _, r := range reviews {
+		if r.Rating >= 1 && r.Rating <= 5 {
 			total += r.Rating
+			rated++
 		}
-		summary.Average = float64(total) / float64(len(reviews))
+	}
+	if rated > 0 {
+		summary.Average = float64(total) / float64(rated)
 	}
 
 	return summary
@@ -207,15 +664,49 @@ func calculateSentimentShift(pre, post SentimentSummary) float64 {
 type APIHandler struct {
 	parser          ReviewParser
 	analysisService AnalysisService
+	prompts         *PromptRegistry
+	usage           *UsageLedger
+	taxonomy        *ThemeTaxonomy
+	search          *ReviewSearchIndex
+	overrides       *OverrideStore
+	reports         *ReportTemplates
+	policy          *Policy
+	webhooks        *WebhookNotifier
+	jobs            *JobScheduler
+	connectors      *ConnectorStore
 	preReviews      []Review
 	postReviews     []Review
+	lastResult      *AnalysisResult // most recent analysis, for exports
+}
+
+// HandlerDependencies holds the stores and services behind the API's optional endpoints
+type HandlerDependencies struct {
+	Prompts    *PromptRegistry
+	Usage      *UsageLedger
+	Taxonomy   *ThemeTaxonomy
+	Overrides  *OverrideStore
+	Reports    *ReportTemplates
+	Policy     *Policy
+	Webhooks   *WebhookNotifier
+	Jobs       *JobScheduler
+	Connectors *ConnectorStore
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(parser ReviewParser, analysisService AnalysisService) *APIHandler {
+func NewAPIHandler(parser ReviewParser, analysisService AnalysisService, deps HandlerDependencies) *APIHandler {
 	return &APIHandler{
 		parser:          parser,
 		analysisService: analysisService,
+		prompts:         deps.Prompts,
+		usage:           deps.Usage,
+		taxonomy:        deps.Taxonomy,
+		search:          NewReviewSearchIndex(),
+		overrides:       deps.Overrides,
+		reports:         deps.Reports,
+		policy:          deps.Policy,
+		webhooks:        deps.Webhooks,
+		jobs:            deps.Jobs,
+		connectors:      deps.Connectors,
 	}
 }
 
@@ -269,6 +760,8 @@ func (h *APIHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.search.Load(h.preReviews, h.postReviews)
+
 	response := UploadResponse{
 		Success:         true,
 		PreLaunchCount:  len(h.preReviews),
@@ -290,15 +783,395 @@ func (h *APIHandler) HandleAnalyze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.analysisService.Analyze(h.preReviews, h.postReviews)
+	result, err := h.analysisService.Analyze(h.preReviews, h.postReviews, analysisOptions(r))
+	if err != nil {
+		h.webhooks.NotifyFailure(err, len(h.preReviews), len(h.postReviews), "api")
+	}
+	var budgetErr *BudgetExceededError
+	if errors.As(err, &budgetErr) {
+		respondError(w, http.StatusTooManyRequests, "Analysis exceeds budget", err.Error())
+		return
+	}
+	if errors.Is(err, ErrUnknownAPIKey) {
+		respondError(w, http.StatusUnauthorized, "Unknown API key", err.Error())
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Analysis failed", err.Error())
 		return
 	}
+	policyResult := h.policy.Evaluate(result)
+	result.Policy = &policyResult
+	h.search.Annotate(result)
+	h.lastResult = result
+	h.webhooks.NotifyResult(result, "api")
 
 	respondJSON(w, http.StatusOK, result)
 }
 
+// HandleEstimate performs a dry run, estimating tokens and cost for the uploaded reviews
+func (h *APIHandler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+
+	if len(h.preReviews) == 0 || len(h.postReviews) == 0 {
+		respondError(w, http.StatusBadRequest, "Please upload CSV files first", "")
+		return
+	}
+
+	estimate, err := h.analysisService.Estimate(h.preReviews, h.postReviews, analysisOptions(r))
+	if errors.Is(err, ErrUnknownAPIKey) {
+		respondError(w, http.StatusUnauthorized, "Unknown API key", err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Estimate failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, estimate)
+}
+
+// HandlePrompts lists the active prompt templates with their versions and variables
+func (h *APIHandler) HandlePrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.prompts.List())
+}
+
+// HandleReloadPrompts re-reads prompt templates from disk
+func (h *APIHandler) HandleReloadPrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	if err := h.prompts.Reload(); err != nil {
+		respondError(w, http.StatusUnprocessableEntity, "Prompt templates are invalid", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, h.prompts.List())
+}
+
+// HandleUsage reports token usage and estimated cost per dataset and per day
+func (h *APIHandler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.usage.Report())
+}
+
+// HandleTaxonomy lists (GET), creates or replaces (POST) and deletes (DELETE ?name=) taxonomy themes
+func (h *APIHandler) HandleTaxonomy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, h.taxonomy.List())
+	case http.MethodPost:
+		var theme TaxonomyTheme
+		if err := json.NewDecoder(r.Body).Decode(&theme); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid theme", err.Error())
+			return
+		}
+		saved, err := h.taxonomy.Upsert(theme)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Failed to save theme", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.taxonomy.Delete(r.URL.Query().Get("name")); err != nil {
+			respondError(w, http.StatusNotFound, "Failed to delete theme", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, h.taxonomy.List())
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// HandleApproveTheme approves a proposed theme (?name=), optionally merging it
+// into an existing theme as a synonym (?merge_into=)
+func (h *APIHandler) HandleApproveTheme(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	query := r.URL.Query()
+	theme, err := h.taxonomy.Approve(query.Get("name"), query.Get("merge_into"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Failed to approve theme", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, theme)
+}
+
+// HandleReviews searches the uploaded reviews. Query parameters: phase,
+// sentiment, theme, source, min_rating, max_rating, from, to (YYYY-MM-DD), q
+// (keywords, all must match), sort (date, rating or id, - for descending),
+// page and page_size
+func (h *APIHandler) HandleReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+
+	query := r.URL.Query()
+	q := ReviewQuery{
+		Phase:     query.Get("phase"),
+		Sentiment: query.Get("sentiment"),
+		Theme:     query.Get("theme"),
+		Source:    query.Get("source"),
+		From:      query.Get("from"),
+		To:        query.Get("to"),
+		Keyword:   query.Get("q"),
+		Sort:      query.Get("sort"),
+	}
+	for name, target := range map[string]*int{"min_rating": &q.MinRating, "max_rating": &q.MaxRating, "page": &q.Page, "page_size": &q.PageSize} {
+		if value := query.Get(name); value != "" {
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid "+name, err.Error())
+				return
+			}
+			*target = v
+		}
+	}
+
+	respondJSON(w, http.StatusOK, h.search.Search(q))
+}
+
+// HandleOverrides lists (GET), records (POST) and deletes (DELETE ?id=) manual
+// label overrides. POSTed overrides must reference an uploaded review
+func (h *APIHandler) HandleOverrides(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, h.overrides.List())
+	case http.MethodPost:
+		var o Override
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid override", err.Error())
+			return
+		}
+		reviews := h.postReviews
+		if o.Phase == "pre_launch" {
+			reviews = h.preReviews
+		} else if o.Phase != "post_launch" {
+			respondError(w, http.StatusBadRequest, "Invalid override", "phase must be pre_launch or post_launch")
+			return
+		}
+		var review *Review
+		for i := range reviews {
+			if reviews[i].ID == o.ReviewID {
+				review = &reviews[i]
+				break
+			}
+		}
+		if review == nil {
+			respondError(w, http.StatusNotFound, "Review not found", "upload the dataset containing the review first")
+			return
+		}
+		saved, err := h.overrides.Add(o, *review)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Failed to save override", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.overrides.Delete(r.URL.Query().Get("id")); err != nil {
+			respondError(w, http.StatusNotFound, "Failed to delete override", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, h.overrides.List())
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// HandleExportOverrides downloads overrides as a labeled CSV (default) or JSONL (?format=jsonl)
+func (h *APIHandler) HandleExportOverrides(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+
+	var err error
+	if r.URL.Query().Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="overrides.jsonl"`)
+		err = h.overrides.ExportJSONL(w)
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="overrides.csv"`)
+		err = h.overrides.ExportCSV(w)
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to export overrides: %v", err)
+	}
+}
+
+// HandleExport downloads a table from the last analysis. Query parameters:
+// table (reviews or themes) and format (csv, xlsx or parquet)
+func (h *APIHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	if h.lastResult == nil {
+		respondError(w, http.StatusBadRequest, "Please run an analysis first", "")
+		return
+	}
+
+	query := r.URL.Query()
+	table, format := query.Get("table"), query.Get("format")
+	if table == "" {
+		table = tableReviews
+	}
+	if format == "" {
+		format = exportCSV
+	}
+	if table != tableReviews && table != tableThemes {
+		respondError(w, http.StatusBadRequest, "Invalid table", "table must be reviews or themes")
+		return
+	}
+	if format != exportCSV && format != exportXLSX && format != exportParquet {
+		respondError(w, http.StatusBadRequest, "Invalid format", "format must be csv, xlsx or parquet")
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(table, format)))
+	if err := ExportTable(h.lastResult, table, format, w); err != nil {
+		log.Printf("⚠️ Failed to export %s as %s: %v", table, format, err)
+	}
+}
+
+// HandleReport downloads the last analysis as a report: format html (default),
+// pdf or markdown. Markdown reports use the report template named by ?template=
+func (h *APIHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	if h.lastResult == nil {
+		respondError(w, http.StatusBadRequest, "Please run an analysis first", "")
+		return
+	}
+
+	var err error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", reportHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = RenderHTMLReport(h.lastResult, w)
+	case reportPDF:
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="launch-report.pdf"`)
+		err = RenderPDFReport(h.lastResult, w)
+	case reportMarkdown, "md":
+		tmpl, tmplErr := h.reports.Markdown(r.URL.Query().Get("template"))
+		if tmplErr != nil {
+			respondError(w, http.StatusBadRequest, "Invalid report template", tmplErr.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="launch-report.md"`)
+		err = RenderMarkdownReport(h.lastResult, tmpl, w)
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid format", "format must be html, pdf or markdown")
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to render report: %v", err)
+	}
+}
+
+// HandlePolicy returns the active regression policy
+func (h *APIHandler) HandlePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.policy)
+}
+
+// HandleEvaluatePolicy evaluates the last analysis against the active policy,
+// or against a policy in the request body (JSON, or YAML with a yaml Content-Type)
+func (h *APIHandler) HandleEvaluatePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	if h.lastResult == nil {
+		respondError(w, http.StatusBadRequest, "Please run an analysis first", "")
+		return
+	}
+
+	policy := h.policy
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to read policy", err.Error())
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		format := "json"
+		if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+			format = "yaml"
+		}
+		if policy, err = ParsePolicy(body, format); err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Invalid policy", err.Error())
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, policy.Evaluate(h.lastResult))
+}
+
+// analysisOptions reads per-request analysis settings from the HTTP request;
+// sample_threshold and sample_size query parameters override sampling, and
+// top_n (0 for no limit), min_support, min_change_rate and discovery override the theme settings
+func analysisOptions(r *http.Request) AnalysisOptions {
+	opts := AnalysisOptions{
+		APIKey: r.Header.Get("X-API-Key"),
+	}
+
+	query := r.URL.Query()
+	if query.Get("sample_threshold") != "" || query.Get("sample_size") != "" {
+		config := LoadSamplingConfig()
+		if v, err := strconv.Atoi(query.Get("sample_threshold")); err == nil {
+			config.Threshold = v
+		}
+		if v, err := strconv.Atoi(query.Get("sample_size")); err == nil {
+			config.SampleSize = v
+			if config.Threshold == 0 {
+				config.Threshold = v
+			}
+		}
+		opts.Sampling = &config
+	}
+
+	if query.Get("top_n") != "" || query.Get("min_support") != "" || query.Get("min_change_rate") != "" || query.Get("discovery") != "" {
+		config := LoadThemeConfig()
+		if v, err := strconv.Atoi(query.Get("top_n")); err == nil {
+			config.TopN = v
+		}
+		if v, err := strconv.Atoi(query.Get("min_support")); err == nil {
+			config.MinSupport = v
+		}
+		if v, err := strconv.ParseFloat(query.Get("min_change_rate"), 64); err == nil {
+			config.MinChangeRate = v
+		}
+		if v := query.Get("discovery"); v != "" {
+			config.Discovery = v
+		}
+		opts.Themes = &config
+	}
+
+	return opts
+}
+
 // Helper functions for HTTP responses
 
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -315,3 +1188,202 @@ func respondError(w http.ResponseWriter, status int, message, details string) {
 	}
 	respondJSON(w, status, response)
 }
+
+// HandleWebhooks lists (GET), adds (POST) and removes (DELETE ?id=) webhook
+// subscriptions. A POST returns the signing secret; it is not shown again
+func (h *APIHandler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, h.webhooks.List())
+	case http.MethodPost:
+		var sub WebhookSubscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid webhook", err.Error())
+			return
+		}
+		saved, err := h.webhooks.Subscribe(sub)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Failed to save webhook", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.webhooks.Unsubscribe(r.URL.Query().Get("id")); err != nil {
+			respondError(w, http.StatusNotFound, "Failed to delete webhook", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, h.webhooks.List())
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// HandleWebhookDeliveries returns the delivery log, newest first (?subscription= filters)
+func (h *APIHandler) HandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.webhooks.Deliveries(r.URL.Query().Get("subscription")))
+}
+
+// HandleTestWebhook sends a webhook.test event to a subscription (?id=) and
+// returns its deliveries, the newest being the pending test
+func (h *APIHandler) HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	if err := h.webhooks.Test(r.URL.Query().Get("id")); err != nil {
+		respondError(w, http.StatusNotFound, "Failed to send test event", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusAccepted, h.webhooks.Deliveries(r.URL.Query().Get("id")))
+}
+
+// HandleJobs lists (GET), creates or replaces (POST) and deletes (DELETE ?id=)
+// scheduled analysis jobs. POST a job with an existing id to update it
+func (h *APIHandler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, h.jobs.List())
+	case http.MethodPost:
+		var job ScheduledJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid job", err.Error())
+			return
+		}
+		saved, err := h.jobs.Upsert(job)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Failed to save job", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.jobs.Delete(r.URL.Query().Get("id")); err != nil {
+			respondError(w, http.StatusNotFound, "Failed to delete job", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, h.jobs.List())
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// HandleRunJob starts a scheduled job now (?id=) and returns the running run
+func (h *APIHandler) HandleRunJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	run, err := h.jobs.RunNow(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusConflict, "Failed to start job", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusAccepted, run)
+}
+
+// HandleJobRuns returns the run history, newest first (?job= and ?status= filter)
+func (h *APIHandler) HandleJobRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	query := r.URL.Query()
+	respondJSON(w, http.StatusOK, h.jobs.Runs(query.Get("job"), query.Get("status")))
+}
+
+// HandleJobResult returns the analysis stored by a successful run (?run=)
+func (h *APIHandler) HandleJobResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	result, err := h.jobs.Result(r.URL.Query().Get("run"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Result not found", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// HandleConnectors lists (GET), creates or replaces (POST) and deletes
+// (DELETE ?name=) review connectors. Listed configs omit literal credentials
+func (h *APIHandler) HandleConnectors(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, h.connectors.List())
+	case http.MethodPost:
+		var config ConnectorConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid connector", err.Error())
+			return
+		}
+		saved, err := h.connectors.Upsert(config)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "Failed to save connector", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.connectors.Delete(r.URL.Query().Get("name")); err != nil {
+			respondError(w, http.StatusNotFound, "Failed to delete connector", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, h.connectors.List())
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// HandleSyncConnector pulls new reviews for a connector (?name=); ?full=true
+// ignores the sync cursor and refetches everything
+func (h *APIHandler) HandleSyncConnector(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	query := r.URL.Query()
+	report, err := h.connectors.Sync(query.Get("name"), query.Get("full") == "true")
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Sync failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// HandleLoadConnector uses a connector's synced reviews (?name=) as the
+// dataset, split at ?launch_date=YYYY-MM-DD, in place of an upload
+func (h *APIHandler) HandleLoadConnector(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	query := r.URL.Query()
+	launch, err := time.Parse(launchDateLayout, query.Get("launch_date"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid launch date", "launch_date must be YYYY-MM-DD")
+		return
+	}
+	reviews, err := h.connectors.Reviews(query.Get("name"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Connector not found", err.Error())
+		return
+	}
+	pre, post, skipped := splitAtLaunch(reviews, launch, 0, 0)
+	if len(pre) == 0 || len(post) == 0 {
+		respondError(w, http.StatusUnprocessableEntity, "Not enough reviews",
+			fmt.Sprintf("%d pre-launch and %d post-launch reviews around %s; both phases need reviews", len(pre), len(post), query.Get("launch_date")))
+		return
+	}
+
+	h.preReviews, h.postReviews = pre, post
+	h.search.Load(h.preReviews, h.postReviews)
+	respondJSON(w, http.StatusOK, UploadResponse{
+		Success:         true,
+		PreLaunchCount:  len(pre),
+		PostLaunchCount: len(post),
+		Message:         fmt.Sprintf("Loaded %d synced reviews (%d without a date skipped). Ready for analysis.", len(pre)+len(post), skipped),
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+// nestThemes arranges flat themes into parent themes with their sub-themes.
+// Any theme named as a parent is top level, so the hierarchy is at most two
+// levels deep. Parent counts are the distinct reviews mentioning the parent or
+// any sub-theme, sentiment is summed, and each level keeps its own change rate
+func nestThemes(flat []ThemeResult) []ThemeResult {
+	isParent := make(map[string]bool)
+	for _, t := range flat {
+		if t.Parent != "" && themeKey(t.Parent) != themeKey(t.Theme) {
+			isParent[themeKey(t.Parent)] = true
+		}
+	}
+
+	var roots []ThemeResult
+	position := make(map[string]int)
+	for _, t := range flat {
+		if t.Parent == "" || isParent[themeKey(t.Theme)] || themeKey(t.Parent) == themeKey(t.Theme) {
+			t.Parent = ""
+			position[themeKey(t.Theme)] = len(roots)
+			roots = append(roots, t)
+		}
+	}
+	for _, t := range flat {
+		if t.Parent == "" || isParent[themeKey(t.Theme)] || themeKey(t.Parent) == themeKey(t.Theme) {
+			continue
+		}
+		i, ok := position[themeKey(t.Parent)]
+		if !ok {
+			// The parent was named but not extracted on its own
+			i = len(roots)
+			position[themeKey(t.Parent)] = i
+			roots = append(roots, ThemeResult{Theme: t.Parent, Evidence: []Evidence{}, Proposed: t.Proposed})
+		}
+		t.Parent = roots[i].Theme
+		roots[i].SubThemes = append(roots[i].SubThemes, t)
+	}
+
+	for i := range roots {
+		rollUpTheme(&roots[i])
+	}
+	return roots
+}
+
+// rollUpTheme folds sub-theme mentions, sentiment and evidence into the parent
+func rollUpTheme(parent *ThemeResult) {
+	if len(parent.SubThemes) == 0 {
+		return
+	}
+
+	preIDs, postIDs := newIDSet(parent.PreReviewIDs), newIDSet(parent.PostReviewIDs)
+	summedPre, summedPost := parent.PreCount, parent.PostCount
+	for _, child := range parent.SubThemes {
+		preIDs.add(child.PreReviewIDs)
+		postIDs.add(child.PostReviewIDs)
+		summedPre += child.PreCount
+		summedPost += child.PostCount
+
+		parent.PreSentiment.merge(child.PreSentiment)
+		parent.PostSentiment.merge(child.PostSentiment)
+		for _, e := range child.Evidence {
+			if len(parent.Evidence) < maxEvidencePerTheme {
+				parent.Evidence = append(parent.Evidence, e)
+			}
+		}
+	}
+
+	// Without per-review attribution the best available rollup is the sum
+	if len(preIDs.ids)+len(postIDs.ids) > 0 {
+		parent.PreReviewIDs, parent.PostReviewIDs = preIDs.ids, postIDs.ids
+		parent.PreCount, parent.PostCount = len(preIDs.ids), len(postIDs.ids)
+	} else {
+		parent.PreCount, parent.PostCount = summedPre, summedPost
+	}
+	parent.ChangeRate = changeRate(parent.PreCount, parent.PostCount)
+}
+
+// flattenThemes lists parents followed by their sub-themes
+func flattenThemes(themes []ThemeResult) []ThemeResult {
+	var flat []ThemeResult
+	for _, t := range themes {
+		flat = append(flat, t)
+		flat = append(flat, t.SubThemes...)
+	}
+	return flat
+}
+
+// idSet collects distinct review IDs in first-seen order
+type idSet struct {
+	seen map[string]bool
+	ids  []string
+}
+
+func newIDSet(ids []string) *idSet {
+	s := &idSet{seen: make(map[string]bool)}
+	s.add(ids)
+	return s
+}
+
+func (s *idSet) add(ids []string) {
+	for _, id := range ids {
+		if !s.seen[id] {
+			s.seen[id] = true
+			s.ids = append(s.ids, id)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// intercomAPIVersion is the Intercom REST API version requested
+const intercomAPIVersion = "2.11"
+
+// IntercomConfig reads conversations through the conversation search API
+type IntercomConfig struct {
+	AccessToken string `json:"access_token"` // e.g. "${JOB_SECRET_INTERCOM_TOKEN}"
+}
+
+// intercomConnector maps conversations to reviews: the customer's opening
+// message is the text and a conversation rating its stars. The cursor is
+// the latest updated_at seen, in Unix seconds
+type intercomConnector struct {
+	config  ConnectorConfig
+	baseURL string
+	client  *http.Client
+}
+
+func newIntercomConnector(config ConnectorConfig, client *http.Client) (*intercomConnector, error) {
+	if config.Intercom.AccessToken == "" {
+		return nil, fmt.Errorf("intercom needs an access_token")
+	}
+	return &intercomConnector{
+		config:  config,
+		baseURL: connectorBaseURL(config, "https://api.intercom.io"),
+		client:  client,
+	}, nil
+}
+
+// intercomSearchPage is one page of conversation search results
+type intercomSearchPage struct {
+	Conversations []struct {
+		ID        string `json:"id"`
+		CreatedAt int64  `json:"created_at"`
+		UpdatedAt int64  `json:"updated_at"`
+		Source    struct {
+			Subject string `json:"subject"`
+			Body    string `json:"body"`
+			Author  struct {
+				ID string `json:"id"`
+			} `json:"author"`
+		} `json:"source"`
+		ConversationRating *struct {
+			Rating int    `json:"rating"`
+			Remark string `json:"remark"`
+		} `json:"conversation_rating"`
+	} `json:"conversations"`
+	Pages struct {
+		Next *struct {
+			StartingAfter string `json:"starting_after"`
+		} `json:"next"`
+	} `json:"pages"`
+}
+
+// Sync searches for conversations updated after the cursor
+func (c *intercomConnector) Sync(cursor string) ([]Review, string, error) {
+	since := c.config.sinceUnix()
+	if cursor != "" {
+		n, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return nil, cursor, fmt.Errorf("invalid intercom cursor %q", cursor)
+		}
+		since = n
+	}
+	latest := since
+
+	var reviews []Review
+	startingAfter := ""
+	for page := 0; page < connectorMaxPages; page++ {
+		pagination := map[string]interface{}{"per_page": 150}
+		if startingAfter != "" {
+			pagination["starting_after"] = startingAfter
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"query":      map[string]interface{}{"field": "updated_at", "operator": ">", "value": since},
+			"pagination": pagination,
+		})
+		if err != nil {
+			return nil, cursor, err
+		}
+		req, err := http.NewRequest(http.MethodPost, c.baseURL+"/conversations/search", bytes.NewReader(payload))
+		if err != nil {
+			return nil, cursor, err
+		}
+		req.Header.Set("Authorization", "Bearer "+expandEnv(c.config.Intercom.AccessToken))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Intercom-Version", intercomAPIVersion)
+
+		var body intercomSearchPage
+		if _, err := doConnectorRequest(c.client, req, &body); err != nil {
+			return nil, cursor, err
+		}
+		for _, conv := range body.Conversations {
+			review := Review{
+				ID:         "intercom-" + conv.ID,
+				Date:       normalizeDate(strconv.FormatInt(conv.CreatedAt, 10)),
+				UserID:     conv.Source.Author.ID,
+				ReviewText: joinText(plainText(conv.Source.Subject), plainText(conv.Source.Body)),
+				Source:     c.config.source(),
+			}
+			if r := conv.ConversationRating; r != nil {
+				review.Rating = r.Rating
+				review.ReviewText = joinText(review.ReviewText, r.Remark)
+			}
+			reviews = append(reviews, review)
+			if conv.UpdatedAt > latest {
+				latest = conv.UpdatedAt
+			}
+		}
+		if body.Pages.Next == nil || body.Pages.Next.StartingAfter == "" {
+			break
+		}
+		startingAfter = body.Pages.Next.StartingAfter
+	}
+	return reviews, strconv.FormatInt(latest, 10), nil
+}
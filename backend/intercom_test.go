@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// intercomSearch is the part of a search request the stand-in checks
+type intercomSearch struct {
+	Query struct {
+		Field    string `json:"field"`
+		Operator string `json:"operator"`
+		Value    int64  `json:"value"`
+	} `json:"query"`
+	Pagination struct {
+		StartingAfter string `json:"starting_after"`
+	} `json:"pagination"`
+}
+
+func TestIntercomConnectorSync(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/conversations/search" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ic-token" || r.Header.Get("Intercom-Version") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// The first search is rate limited, so the retry must resend the body
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var search intercomSearch
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			t.Errorf("decode search: %v", err)
+		}
+		if search.Query.Field != "updated_at" || search.Query.Operator != ">" {
+			t.Errorf("query = %+v", search.Query)
+		}
+		switch {
+		case search.Query.Value == 1706900000:
+			fmt.Fprint(w, `{"conversations":[],"pages":{}}`)
+		case search.Pagination.StartingAfter == "":
+			fmt.Fprint(w, `{"conversations":[
+				{"id":"a","created_at":1706700000,"updated_at":1706750000,
+				 "source":{"subject":"Billing","body":"<p>Charged <b>twice</b></p>","author":{"id":"u1"}},
+				 "conversation_rating":{"rating":2,"remark":"slow reply"}}
+			],"pages":{"next":{"starting_after":"page2"}}}`)
+		case search.Pagination.StartingAfter == "page2":
+			fmt.Fprint(w, `{"conversations":[
+				{"id":"b","created_at":1706800000,"updated_at":1706900000,
+				 "source":{"subject":"","body":"Love the new editor","author":{"id":"u2"}}}
+			],"pages":{}}`)
+		default:
+			t.Errorf("unexpected page %q", search.Pagination.StartingAfter)
+		}
+	}))
+	defer srv.Close()
+
+	connector, err := newConnector(ConnectorConfig{Name: "chat", Type: connectorIntercom, BaseURL: srv.URL,
+		Intercom: &IntercomConfig{AccessToken: "ic-token"}}, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+
+	reviews, cursor, err := connector.Sync("")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if cursor != "1706900000" {
+		t.Fatalf("cursor = %q, want the latest updated_at", cursor)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "intercom-a,intercom-b" {
+		t.Fatalf("reviews = %s", got)
+	}
+	want := Review{ID: "intercom-a", Date: "2024-01-31", UserID: "u1", ReviewText: "Billing\n\nCharged twice\n\nslow reply", Rating: 2, Source: "support_chat"}
+	if reviews[0] != want {
+		t.Fatalf("review = %+v, want %+v", reviews[0], want)
+	}
+	if reviews[1].Rating != 0 || reviews[1].ReviewText != "Love the new editor" {
+		t.Fatalf("unrated review = %+v", reviews[1])
+	}
+
+	// Resuming searches after the cursor and keeps it when nothing changed
+	reviews, cursor, err = connector.Sync(cursor)
+	if err != nil {
+		t.Fatalf("resumed Sync: %v", err)
+	}
+	if len(reviews) != 0 || cursor != "1706900000" {
+		t.Fatalf("resumed sync = %d reviews, cursor %q", len(reviews), cursor)
+	}
+
+	if _, _, err := connector.Sync("yesterday"); err == nil {
+		t.Fatal("an invalid cursor was accepted")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// IssueConfig controls which themes are reported as new post-launch issues
+type IssueConfig struct {
+	MaxPreRate   float64 `json:"max_pre_rate"`   // pre-launch mentions per 100 reviews still counted as "new"
+	MinPostCount int     `json:"min_post_count"` // post-launch mentions needed before an issue is reported
+	MinSeverity  float64 `json:"min_severity"`   // issues scoring below this are not reported
+}
+
+// LoadIssueConfig reads emerging-issue thresholds from the environment
+func LoadIssueConfig() IssueConfig {
+	return IssueConfig{
+		MaxPreRate:   getEnvFloat("ISSUE_MAX_PRE_RATE", 1),
+		MinPostCount: getEnvInt("ISSUE_MIN_POST_MENTIONS", 2),
+		MinSeverity:  getEnvFloat("ISSUE_MIN_SEVERITY", 20),
+	}
+}
+
+// EmergingIssue is a theme that barely existed before launch and is now growing
+type EmergingIssue struct {
+	Theme         string     `json:"theme"`
+	Parent        string     `json:"parent,omitempty"`
+	PreCount      int        `json:"pre_count"`
+	PostCount     int        `json:"post_count"`
+	PreRate       float64    `json:"pre_rate"`       // mentions per 100 pre-launch reviews
+	PostRate      float64    `json:"post_rate"`      // mentions per 100 post-launch reviews
+	NegativeShare float64    `json:"negative_share"` // share of post-launch mentions that are negative
+	Severity      float64    `json:"severity"`       // 0-100
+	Level         string     `json:"level"`          // low, medium, high or critical
+	Reasons       []string   `json:"reasons"`
+	Evidence      []Evidence `json:"evidence"`
+}
+
+// detectEmergingIssues scans every theme, including sub-themes and the long
+// tail, for near-zero pre-launch support combined with growing, partly
+// negative post-launch volume or mostly negative sentiment. Severity is scored out of 100:
+//
+//   - volume: 4 points per post-launch mention per 100 reviews, up to 40
+//   - negativity: the negative share of post-launch mentions times 40
+//   - novelty: up to 20 points, the full amount for a theme absent before launch
+func detectEmergingIssues(comparison ComparisonResult, config IssueConfig) []EmergingIssue {
+	preTotal := sentimentTotal(comparison.PreLaunchSentiment)
+	postTotal := sentimentTotal(comparison.PostLaunchSentiment)
+	if postTotal == 0 {
+		return []EmergingIssue{}
+	}
+
+	issues := []EmergingIssue{}
+	for _, t := range flattenThemes(allThemes(comparison)) {
+		if t.PostCount < config.MinPostCount {
+			continue
+		}
+		issue := EmergingIssue{
+			Theme:     t.Theme,
+			Parent:    t.Parent,
+			PreCount:  t.PreCount,
+			PostCount: t.PostCount,
+			PostRate:  round2(float64(t.PostCount) / float64(postTotal) * 100),
+			Reasons:   []string{},
+			Evidence:  postLaunchEvidence(t.Evidence),
+		}
+		if preTotal > 0 {
+			issue.PreRate = round2(float64(t.PreCount) / float64(preTotal) * 100)
+		}
+		if issue.PreRate > config.MaxPreRate {
+			continue
+		}
+
+		preNegative := negativeShare(t.PreSentiment)
+		issue.NegativeShare = round2(negativeShare(t.PostSentiment))
+		// Growth only signals an issue when mentions are at least partly negative;
+		// without aspect sentiment there is no way to tell, so growth alone counts
+		mixed := t.PostSentiment == (SentimentCounts{}) || issue.NegativeShare >= 0.25
+		growing := issue.PostRate > issue.PreRate && mixed
+		souring := issue.NegativeShare >= 0.5 && issue.NegativeShare > preNegative
+		if !growing && !souring {
+			continue
+		}
+
+		if t.PreCount == 0 {
+			issue.Reasons = append(issue.Reasons, "not mentioned before launch")
+		} else {
+			issue.Reasons = append(issue.Reasons, fmt.Sprintf("mentioned in only %.1f%% of pre-launch reviews", issue.PreRate))
+		}
+		if growing {
+			issue.Reasons = append(issue.Reasons, fmt.Sprintf("now in %.1f%% of post-launch reviews", issue.PostRate))
+		}
+		if souring {
+			issue.Reasons = append(issue.Reasons, fmt.Sprintf("%.0f%% of post-launch mentions are negative", issue.NegativeShare*100))
+		}
+
+		volume := math.Min(issue.PostRate*4, 40)
+		negativity := issue.NegativeShare * 40
+		novelty := 20.0
+		if issue.PostRate > 0 {
+			novelty = 20 * math.Max(0, 1-issue.PreRate/issue.PostRate)
+		}
+		issue.Severity = round2(volume + negativity + novelty)
+		issue.Level = severityLevel(issue.Severity)
+		if issue.Severity < config.MinSeverity {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Severity > issues[j].Severity })
+	return issues
+}
+
+// severityLevel buckets a 0-100 severity score
+func severityLevel(severity float64) string {
+	switch {
+	case severity >= 70:
+		return "critical"
+	case severity >= 50:
+		return "high"
+	case severity >= 30:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// negativeShare is the negative fraction of a theme's sentiment mentions
+func negativeShare(c SentimentCounts) float64 {
+	total := c.Positive + c.Negative + c.Neutral
+	if total == 0 {
+		return 0
+	}
+	return float64(c.Negative) / float64(total)
+}
+
+// sentimentTotal is the number of reviews summarised
+func sentimentTotal(s SentimentSummary) int {
+	return s.Positive + s.Negative + s.Neutral
+}
+
+// postLaunchEvidence keeps the quotes from post-launch reviews
+func postLaunchEvidence(evidence []Evidence) []Evidence {
+	post := []Evidence{}
+	for _, e := range evidence {
+		if e.Phase == "post_launch" {
+			post = append(post, e)
+		}
+	}
+	return post
+}
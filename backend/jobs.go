@@ -0,0 +1,535 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job run states
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+)
+
+// Job run triggers
+const (
+	triggerSchedule = "schedule"
+	triggerManual   = "manual"
+)
+
+// launchDateLayout is the format of ScheduledJob.LaunchDate and review dates
+const launchDateLayout = "2006-01-02"
+
+// JobConfig controls the scheduler
+type JobConfig struct {
+	CheckInterval time.Duration `json:"check_interval"` // how often due jobs are looked for
+	HistoryLimit  int           `json:"history_limit"`  // runs kept across all jobs
+	FetchTimeout  time.Duration `json:"fetch_timeout"`  // per source request
+}
+
+// LoadJobConfig reads scheduler settings from the environment; the check
+// interval is at least a second, since the ticker cannot run without one
+func LoadJobConfig() JobConfig {
+	return JobConfig{
+		CheckInterval: time.Duration(max(getEnvInt("JOB_CHECK_INTERVAL_SECONDS", 30), 1)) * time.Second,
+		HistoryLimit:  getEnvInt("JOB_HISTORY_LIMIT", 500),
+		FetchTimeout:  time.Duration(getEnvInt("JOB_FETCH_TIMEOUT_SECONDS", 120)) * time.Second,
+	}
+}
+
+// ScheduledJob analyzes reviews from a source on a cron schedule. Reviews
+// dated before LaunchDate are pre-launch, the rest post-launch
+type ScheduledJob struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Schedule   string    `json:"schedule"`           // cron expression, e.g. "0 6 * * 1"
+	Timezone   string    `json:"timezone,omitempty"` // IANA name the schedule runs in; default UTC
+	Source     JobSource `json:"source"`
+	LaunchDate string    `json:"launch_date"`         // YYYY-MM-DD
+	PreDays    int       `json:"pre_days,omitempty"`  // only reviews this many days before launch; 0 keeps all
+	PostDays   int       `json:"post_days,omitempty"` // only reviews this many days after launch; 0 keeps all
+	Discovery  string    `json:"discovery,omitempty"` // overrides THEME_DISCOVERY
+	TopN       int       `json:"top_n,omitempty"`     // overrides THEME_TOP_N
+	Paused     bool      `json:"paused"`
+	CreatedAt  string    `json:"created_at"`
+
+	NextRunAt           string `json:"next_run_at,omitempty"`
+	LastRunAt           string `json:"last_run_at,omitempty"`
+	LastStatus          string `json:"last_status,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// JobRun is one execution of a scheduled job
+type JobRun struct {
+	ID           string   `json:"id"`
+	JobID        string   `json:"job_id"`
+	JobName      string   `json:"job_name"`
+	Trigger      string   `json:"trigger"` // schedule or manual
+	Status       string   `json:"status"`  // running, succeeded or failed
+	StartedAt    string   `json:"started_at"`
+	FinishedAt   string   `json:"finished_at,omitempty"`
+	DurationMS   int64    `json:"duration_ms"`
+	Files        []string `json:"files"`
+	PreCount     int      `json:"pre_count"`
+	PostCount    int      `json:"post_count"`
+	Skipped      int      `json:"skipped"` // reviews without a date or outside the window
+	SuccessScore float64  `json:"success_score,omitempty"`
+	PolicyStatus string   `json:"policy_status,omitempty"`
+	ResultFile   string   `json:"result_file,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// JobScheduler stores scheduled jobs and runs them when they are due. Failed
+// runs are logged, kept in the history and sent to analysis.failed webhooks
+type JobScheduler struct {
+	mu         sync.Mutex
+	path       string
+	runsPath   string
+	resultsDir string
+	config     JobConfig
+	jobs       []ScheduledJob
+	runs       []JobRun                   // oldest first, capped at config.HistoryLimit
+	results    map[string]*AnalysisResult // by run ID, when there is no results directory
+	running    map[string]bool            // job IDs with a run in progress
+	service    AnalysisService
+	parser     ReviewParser
+	policy     *Policy
+	webhooks   *WebhookNotifier
+	connectors *ConnectorStore
+	client     *http.Client
+}
+
+// NewJobScheduler loads jobs from path and run history from runsPath, and
+// stores results under resultsDir ("" keeps everything in memory)
+func NewJobScheduler(path, runsPath, resultsDir string, config JobConfig, service AnalysisService, parser ReviewParser, policy *Policy, webhooks *WebhookNotifier, connectors *ConnectorStore) (*JobScheduler, error) {
+	s := &JobScheduler{
+		path:       path,
+		runsPath:   runsPath,
+		resultsDir: resultsDir,
+		config:     config,
+		results:    make(map[string]*AnalysisResult),
+		running:    make(map[string]bool),
+		service:    service,
+		parser:     parser,
+		policy:     policy,
+		webhooks:   webhooks,
+		connectors: connectors,
+		client:     &http.Client{Timeout: config.FetchTimeout},
+	}
+	if err := loadJSONFile(path, &s.jobs); err != nil {
+		return nil, err
+	}
+	if err := loadJSONFile(runsPath, &s.runs); err != nil {
+		return nil, err
+	}
+	// Runs interrupted by a restart never finished
+	for i := range s.runs {
+		if s.runs[i].Status == runRunning {
+			s.runs[i].Status = runFailed
+			s.runs[i].Error = "interrupted by a server restart"
+		}
+	}
+	return s, nil
+}
+
+// Start checks for due jobs in the background. Jobs that came due while the
+// server was down run once on start
+func (s *JobScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.CheckInterval)
+		defer ticker.Stop()
+		for {
+			s.runDue(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// List returns every job
+func (s *JobScheduler) List() []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ScheduledJob{}, s.jobs...)
+}
+
+// Upsert validates and stores a job, replacing the job with the same ID
+func (s *JobScheduler) Upsert(job ScheduledJob) (ScheduledJob, error) {
+	job.Name = strings.TrimSpace(job.Name)
+	if job.Name == "" {
+		return job, fmt.Errorf("name is required")
+	}
+	schedule, err := job.schedule()
+	if err != nil {
+		return job, err
+	}
+	if _, err := time.Parse(launchDateLayout, job.LaunchDate); err != nil {
+		return job, fmt.Errorf("launch_date must be YYYY-MM-DD")
+	}
+	if job.PreDays < 0 || job.PostDays < 0 {
+		return job, fmt.Errorf("pre_days and post_days cannot be negative")
+	}
+	if job.Discovery != "" && job.Discovery != discoveryLLM && job.Discovery != discoveryClusters {
+		return job, fmt.Errorf("discovery must be %s or %s", discoveryLLM, discoveryClusters)
+	}
+	if err := job.Source.validate(); err != nil {
+		return job, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := -1
+	for i, existing := range s.jobs {
+		if job.ID != "" && existing.ID == job.ID {
+			index = i
+		}
+	}
+	if index >= 0 {
+		previous := s.jobs[index]
+		job.CreatedAt, job.LastRunAt, job.LastStatus, job.ConsecutiveFailures = previous.CreatedAt, previous.LastRunAt, previous.LastStatus, previous.ConsecutiveFailures
+	} else {
+		if job.ID != "" {
+			return job, fmt.Errorf("job %q not found", job.ID)
+		}
+		if job.ID, err = randomHex(8); err != nil {
+			return job, fmt.Errorf("failed to generate job id: %w", err)
+		}
+		job.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	job.NextRunAt = ""
+	if next := schedule.Next(time.Now()); !next.IsZero() && !job.Paused {
+		job.NextRunAt = next.UTC().Format(time.RFC3339)
+	}
+
+	if index >= 0 {
+		s.jobs[index] = job
+	} else {
+		s.jobs = append(s.jobs, job)
+	}
+	return job, saveJSONFile(s.path, s.jobs)
+}
+
+// Delete removes a job; its run history is kept
+func (s *JobScheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, job := range s.jobs {
+		if job.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return saveJSONFile(s.path, s.jobs)
+		}
+	}
+	return fmt.Errorf("job %q not found", id)
+}
+
+// Runs returns the run history, newest first, optionally for one job or status
+func (s *JobScheduler) Runs(jobID, status string) []JobRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := []JobRun{}
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		run := s.runs[i]
+		if (jobID == "" || run.JobID == jobID) && (status == "" || run.Status == status) {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// Result returns the stored analysis of a successful run
+func (s *JobScheduler) Result(runID string) (*AnalysisResult, error) {
+	s.mu.Lock()
+	var run *JobRun
+	for i := range s.runs {
+		if s.runs[i].ID == runID {
+			r := s.runs[i]
+			run = &r
+		}
+	}
+	result := s.results[runID]
+	s.mu.Unlock()
+
+	if run == nil {
+		return nil, fmt.Errorf("run %q not found", runID)
+	}
+	if result != nil {
+		return result, nil
+	}
+	if run.ResultFile == "" {
+		return nil, fmt.Errorf("run %q has no stored result", runID)
+	}
+	var stored AnalysisResult
+	if err := loadJSONFile(run.ResultFile, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// RunNow starts a job immediately, outside its schedule
+func (s *JobScheduler) RunNow(id string) (JobRun, error) {
+	s.mu.Lock()
+	var job *ScheduledJob
+	for i := range s.jobs {
+		if s.jobs[i].ID == id {
+			j := s.jobs[i]
+			job = &j
+		}
+	}
+	if job == nil {
+		s.mu.Unlock()
+		return JobRun{}, fmt.Errorf("job %q not found", id)
+	}
+	if s.running[id] {
+		s.mu.Unlock()
+		return JobRun{}, fmt.Errorf("job %q is already running", job.Name)
+	}
+	run, err := s.begin(*job, triggerManual)
+	s.mu.Unlock()
+	if err != nil {
+		return JobRun{}, err
+	}
+
+	go s.execute(*job, run)
+	return run, nil
+}
+
+// runDue starts every unpaused job whose next run time has passed
+func (s *JobScheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	type due struct {
+		job ScheduledJob
+		run JobRun
+	}
+	var started []due
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.Paused || job.NextRunAt == "" || s.running[job.ID] {
+			continue
+		}
+		next, err := time.Parse(time.RFC3339, job.NextRunAt)
+		if err != nil || next.After(now) {
+			continue
+		}
+		if schedule, err := job.schedule(); err == nil {
+			if following := schedule.Next(now); !following.IsZero() {
+				job.NextRunAt = following.UTC().Format(time.RFC3339)
+			} else {
+				job.NextRunAt = ""
+			}
+		}
+		run, err := s.begin(*job, triggerSchedule)
+		if err != nil {
+			log.Printf("⚠️  Job %s not started: %v", job.Name, err)
+			continue
+		}
+		started = append(started, due{*job, run})
+	}
+	if len(started) > 0 {
+		if err := saveJSONFile(s.path, s.jobs); err != nil {
+			log.Printf("⚠️  Failed to save jobs: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, d := range started {
+		go s.execute(d.job, d.run)
+	}
+}
+
+// begin records a running run; the caller holds s.mu
+func (s *JobScheduler) begin(job ScheduledJob, trigger string) (JobRun, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return JobRun{}, fmt.Errorf("failed to generate run id: %w", err)
+	}
+	run := JobRun{
+		ID:        id,
+		JobID:     job.ID,
+		JobName:   job.Name,
+		Trigger:   trigger,
+		Status:    runRunning,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:     []string{},
+	}
+	s.running[job.ID] = true
+	s.runs = append(s.runs, run)
+	if limit := s.config.HistoryLimit; limit > 0 && len(s.runs) > limit {
+		for _, dropped := range s.runs[:len(s.runs)-limit] {
+			delete(s.results, dropped.ID)
+		}
+		s.runs = append([]JobRun{}, s.runs[len(s.runs)-limit:]...)
+	}
+	if err := saveJSONFile(s.runsPath, s.runs); err != nil {
+		log.Printf("⚠️  Failed to save job history: %v", err)
+	}
+	return run, nil
+}
+
+// execute fetches, splits and analyzes the job's reviews, then records the outcome
+func (s *JobScheduler) execute(job ScheduledJob, run JobRun) {
+	start := time.Now()
+	log.Printf("⏱️  Job %s started (%s)", job.Name, run.Trigger)
+	result, err := s.analyze(job, &run)
+	if err == nil {
+		run.SuccessScore = result.Impact.SuccessScore
+		if result.Policy != nil {
+			run.PolicyStatus = result.Policy.Status
+		}
+		if s.resultsDir != "" {
+			run.ResultFile = filepath.Join(s.resultsDir, job.ID, run.ID+".json")
+			err = saveJSONFile(run.ResultFile, result)
+		}
+	}
+
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	run.DurationMS = time.Since(start).Milliseconds()
+	source := "job:" + job.Name
+	if err != nil {
+		run.Status = runFailed
+		run.Error = err.Error()
+		run.ResultFile = ""
+		log.Printf("⚠️  Job %s failed: %v", job.Name, err)
+		s.webhooks.NotifyFailure(err, run.PreCount, run.PostCount, source)
+	} else {
+		run.Status = runSucceeded
+		log.Printf("✅ Job %s finished: score %.1f", job.Name, run.SuccessScore)
+		s.webhooks.NotifyResult(result, source)
+	}
+	s.finish(run, result)
+}
+
+// analyze runs one job up to the analysis result
+func (s *JobScheduler) analyze(job ScheduledJob, run *JobRun) (*AnalysisResult, error) {
+	reviews, files, err := s.fetch(job.Source)
+	if files != nil {
+		run.Files = files
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reviews: %w", err)
+	}
+
+	launch, _ := time.Parse(launchDateLayout, job.LaunchDate)
+	pre, post, skipped := splitAtLaunch(reviews, launch, job.PreDays, job.PostDays)
+	run.PreCount, run.PostCount, run.Skipped = len(pre), len(post), skipped
+	if len(pre) == 0 || len(post) == 0 {
+		return nil, fmt.Errorf("%d pre-launch and %d post-launch reviews around %s; both phases need reviews", len(pre), len(post), job.LaunchDate)
+	}
+
+	opts := AnalysisOptions{}
+	if job.Discovery != "" || job.TopN > 0 {
+		config := LoadThemeConfig()
+		if job.Discovery != "" {
+			config.Discovery = job.Discovery
+		}
+		if job.TopN > 0 {
+			config.TopN = job.TopN
+		}
+		opts.Themes = &config
+	}
+	result, err := s.service.Analyze(pre, post, opts)
+	if err != nil {
+		return nil, fmt.Errorf("analysis failed: %w", err)
+	}
+	if s.policy != nil {
+		policyResult := s.policy.Evaluate(result)
+		result.Policy = &policyResult
+	}
+	return result, nil
+}
+
+// finish stores a completed run and updates its job
+func (s *JobScheduler) finish(run JobRun, result *AnalysisResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, run.JobID)
+
+	for i := range s.runs {
+		if s.runs[i].ID == run.ID {
+			s.runs[i] = run
+		}
+	}
+	if s.resultsDir == "" && result != nil && run.Status == runSucceeded {
+		s.results[run.ID] = result
+	}
+	if err := saveJSONFile(s.runsPath, s.runs); err != nil {
+		log.Printf("⚠️  Failed to save job history: %v", err)
+	}
+
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.ID != run.JobID {
+			continue
+		}
+		job.LastRunAt, job.LastStatus = run.StartedAt, run.Status
+		if run.Status == runFailed {
+			job.ConsecutiveFailures++
+		} else {
+			job.ConsecutiveFailures = 0
+		}
+	}
+	if err := saveJSONFile(s.path, s.jobs); err != nil {
+		log.Printf("⚠️  Failed to save jobs: %v", err)
+	}
+}
+
+// fetch reads a job's reviews; connector sources sync first, so every run
+// sees the reviews added since the previous one
+func (s *JobScheduler) fetch(source JobSource) ([]Review, []string, error) {
+	if source.Type != sourceConnector {
+		return source.Fetch(s.client, s.parser)
+	}
+	files := []string{"connector:" + source.Connector}
+	if _, err := s.connectors.Sync(source.Connector, false); err != nil {
+		return nil, files, err
+	}
+	reviews, err := s.connectors.Reviews(source.Connector)
+	return reviews, files, err
+}
+
+// schedule parses the job's cron expression in its timezone
+func (j ScheduledJob) schedule() (*CronSchedule, error) {
+	location := time.UTC
+	if j.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(j.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", j.Timezone)
+		}
+	}
+	return ParseCron(j.Schedule, location)
+}
+
+// splitAtLaunch divides reviews into those dated before the launch day and
+// those on or after it, optionally limited to a window around the launch
+func splitAtLaunch(reviews []Review, launch time.Time, preDays, postDays int) (pre, post []Review, skipped int) {
+	for _, r := range reviews {
+		if len(r.Date) < 10 {
+			skipped++
+			continue
+		}
+		date, err := time.Parse(launchDateLayout, r.Date[:10])
+		if err != nil {
+			skipped++
+			continue
+		}
+		switch {
+		case date.Before(launch):
+			if preDays > 0 && date.Before(launch.AddDate(0, 0, -preDays)) {
+				skipped++
+				continue
+			}
+			pre = append(pre, r)
+		default:
+			if postDays > 0 && !date.Before(launch.AddDate(0, 0, postDays)) {
+				skipped++
+				continue
+			}
+			post = append(post, r)
+		}
+	}
+	return pre, post, skipped
+}
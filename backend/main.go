@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 )
 
 // getEnv returns the value of an environment variable or a default value
@@ -75,6 +76,29 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/health", s.handler.HandleHealth)
 	mux.HandleFunc("/api/upload", s.handler.HandleUpload)
 	mux.HandleFunc("/api/analyze", s.handler.HandleAnalyze)
+	mux.HandleFunc("/api/estimate", s.handler.HandleEstimate)
+	mux.HandleFunc("/api/prompts", s.handler.HandlePrompts)
+	mux.HandleFunc("/api/prompts/reload", s.handler.HandleReloadPrompts)
+	mux.HandleFunc("/api/usage", s.handler.HandleUsage)
+	mux.HandleFunc("/api/reviews", s.handler.HandleReviews)
+	mux.HandleFunc("/api/export", s.handler.HandleExport)
+	mux.HandleFunc("/api/report", s.handler.HandleReport)
+	mux.HandleFunc("/api/policy", s.handler.HandlePolicy)
+	mux.HandleFunc("/api/policy/evaluate", s.handler.HandleEvaluatePolicy)
+	mux.HandleFunc("/api/webhooks", s.handler.HandleWebhooks)
+	mux.HandleFunc("/api/webhooks/deliveries", s.handler.HandleWebhookDeliveries)
+	mux.HandleFunc("/api/webhooks/test", s.handler.HandleTestWebhook)
+	mux.HandleFunc("/api/jobs", s.handler.HandleJobs)
+	mux.HandleFunc("/api/jobs/run", s.handler.HandleRunJob)
+	mux.HandleFunc("/api/jobs/runs", s.handler.HandleJobRuns)
+	mux.HandleFunc("/api/jobs/result", s.handler.HandleJobResult)
+	mux.HandleFunc("/api/connectors", s.handler.HandleConnectors)
+	mux.HandleFunc("/api/connectors/sync", s.handler.HandleSyncConnector)
+	mux.HandleFunc("/api/connectors/load", s.handler.HandleLoadConnector)
+	mux.HandleFunc("/api/overrides", s.handler.HandleOverrides)
+	mux.HandleFunc("/api/overrides/export", s.handler.HandleExportOverrides)
+	mux.HandleFunc("/api/taxonomy", s.handler.HandleTaxonomy)
+	mux.HandleFunc("/api/taxonomy/approve", s.handler.HandleApproveTheme)
 
 	// Wrap with CORS middleware
 	handler := CORSMiddleware(mux)
@@ -86,27 +110,110 @@ func (s *Server) Start() error {
 	log.Printf("   GET  /api/health  - Health check")
 	log.Printf("   POST /api/upload  - Upload CSV files")
 	log.Printf("   POST /api/analyze - Run analysis")
+	log.Printf("   POST /api/estimate - Estimate tokens and cost (dry run)")
+	log.Printf("   GET  /api/prompts - List prompt templates")
+	log.Printf("   POST /api/prompts/reload - Reload prompt templates")
+	log.Printf("   GET  /api/usage   - Token usage and cost")
+	log.Printf("   GET  /api/reviews - Search and filter reviews")
+	log.Printf("   GET  /api/export  - Export review or theme table (CSV, XLSX, Parquet)")
+	log.Printf("   GET  /api/report  - Launch report (HTML, PDF or Markdown)")
+	log.Printf("   GET  /api/policy  - Active launch regression policy")
+	log.Printf("   POST /api/policy/evaluate - Evaluate the last analysis against a policy")
+	log.Printf("   GET  /api/webhooks - List, add (POST) or remove (DELETE) webhook subscriptions")
+	log.Printf("   GET  /api/webhooks/deliveries - Webhook delivery log")
+	log.Printf("   POST /api/webhooks/test - Send a test event to a subscription")
+	log.Printf("   GET  /api/jobs    - List, save (POST) or delete (DELETE) scheduled jobs")
+	log.Printf("   POST /api/jobs/run - Run a scheduled job now")
+	log.Printf("   GET  /api/jobs/runs - Scheduled job run history")
+	log.Printf("   GET  /api/jobs/result - Analysis stored by a job run")
+	log.Printf("   GET  /api/connectors - List, save (POST) or delete (DELETE) review connectors")
+	log.Printf("   POST /api/connectors/sync - Pull new reviews for a connector")
+	log.Printf("   POST /api/connectors/load - Use a connector's reviews as the dataset")
+	log.Printf("   GET/POST/DELETE /api/overrides - Manage manual label overrides")
+	log.Printf("   GET  /api/overrides/export - Export overrides as training/eval data")
+	log.Printf("   GET/POST/DELETE /api/taxonomy - Manage canonical themes")
+	log.Printf("   POST /api/taxonomy/approve - Approve or merge a proposed theme")
 
 	return http.ListenAndServe(addr, handler)
 }
 
 func main() {
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// analysisStack holds the long-lived components shared by the server and the CLI
+type analysisStack struct {
+	prompts    *PromptRegistry
+	usage      *UsageLedger
+	taxonomy   *ThemeTaxonomy
+	overrides  *OverrideStore
+	webhooks   *WebhookNotifier
+	connectors *ConnectorStore
+	service    *DefaultAnalysisService
+}
+
+// newAnalysisStack wires the analysis dependencies from the environment
+func newAnalysisStack() (*analysisStack, error) {
 	// Get API key from environment variable
 	apiKey := getEnv("GROQ_API_KEY", "")
 	if apiKey == "" {
-		log.Fatal("GROQ_API_KEY environment variable is required")
+		return nil, fmt.Errorf("GROQ_API_KEY environment variable is required")
 	}
 
 	// Initialize dependencies using dependency injection
-	groqClient := NewGroqClient(apiKey)
-	csvParser := NewCSVReviewParser()
-	analysisService := NewAnalysisService(groqClient)
-	apiHandler := NewAPIHandler(csvParser, analysisService)
-
-	// Create and start server
-	port := getPort()
-	server := NewServer(apiHandler, port)
-	if err := server.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	prompts, err := NewPromptRegistry(getEnv("PROMPT_DIR", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
 	}
+
+	prices, err := LoadPriceTable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load price table: %w", err)
+	}
+	usageLedger, err := NewUsageLedger(statePath("usage.json"), prices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage ledger: %w", err)
+	}
+	taxonomy, err := NewThemeTaxonomy(statePath("taxonomy.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load theme taxonomy: %w", err)
+	}
+	overrides, err := NewOverrideStore(statePath("overrides.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load overrides: %w", err)
+	}
+	webhooks, err := NewWebhookNotifier(statePath("webhooks.json"), statePath("webhook_deliveries.json"), LoadWebhookConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	connectors, err := NewConnectorStore(statePath("connectors.json"), statePath("connector_reviews"), time.Duration(getEnvInt("CONNECTOR_TIMEOUT_SECONDS", 60))*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connectors: %w", err)
+	}
+
+	groqClient := NewGroqClient(apiKey, LoadGenerationConfig(), prompts)
+	sentimentCache := NewMemorySentimentCache()
+	budgetGuard := NewBudgetGuard(LoadBudgetConfig(), usageLedger)
+	analysisService := NewAnalysisService(groqClient, AnalysisDependencies{
+		Cache:     sentimentCache,
+		Usage:     usageLedger,
+		Budget:    budgetGuard,
+		Sampling:  LoadSamplingConfig(),
+		Themes:    LoadThemeConfig(),
+		Taxonomy:  taxonomy,
+		Embedder:  LoadEmbedder(),
+		Clusters:  LoadClusterConfig(),
+		Issues:    LoadIssueConfig(),
+		Overrides: overrides,
+	})
+
+	return &analysisStack{
+		prompts:    prompts,
+		usage:      usageLedger,
+		taxonomy:   taxonomy,
+		overrides:  overrides,
+		webhooks:   webhooks,
+		connectors: connectors,
+		service:    analysisService,
+	}, nil
 }
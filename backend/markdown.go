@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Markdown report settings
+const (
+	reportMarkdown        = "markdown"
+	defaultReportTemplate = "report"
+	markdownTemplateExt   = ".md.tmpl"
+)
+
+// reportTemplateName restricts template names to plain file names
+var reportTemplateName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ReportTemplates finds Markdown report templates. A template named "prd" is
+// read from <dir>/prd.md.tmpl, falling back to the built-in reports/prd.md.tmpl.
+// Files are read on every render, so edits apply without a restart
+type ReportTemplates struct {
+	dir string
+}
+
+// NewReportTemplates creates a template source; an empty dir uses only the built-ins
+func NewReportTemplates(dir string) *ReportTemplates {
+	return &ReportTemplates{dir: dir}
+}
+
+// Markdown parses the named template ("" selects the default)
+func (t *ReportTemplates) Markdown(name string) (*template.Template, error) {
+	if name == "" {
+		name = defaultReportTemplate
+	}
+	if !reportTemplateName.MatchString(name) {
+		return nil, fmt.Errorf("invalid report template name %q", name)
+	}
+
+	file := name + markdownTemplateExt
+	var source []byte
+	var err error
+	if t.dir != "" {
+		source, err = os.ReadFile(filepath.Join(t.dir, file))
+	}
+	if t.dir == "" || errors.Is(err, fs.ErrNotExist) {
+		source, err = reportFS.ReadFile("reports/" + file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unknown report template %q", name)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report template %s: %w", name, err)
+	}
+
+	tmpl, err := template.New(file).Funcs(template.FuncMap(reportFuncs)).Funcs(template.FuncMap{
+		"cell":  markdownCell,
+		"quote": markdownQuote,
+	}).Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// RenderMarkdownReport writes the report for pasting into PRDs and wikis
+func RenderMarkdownReport(result *AnalysisResult, tmpl *template.Template, w io.Writer) error {
+	return tmpl.Execute(w, buildReportView(result))
+}
+
+// markdownCell makes text safe inside a table cell
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// markdownQuote formats text as a block quote, one > per line
+func markdownQuote(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -12,25 +12,35 @@ type Review struct {
 
 // ReviewCollection holds a list of reviews with metadata
 type ReviewCollection struct {
-	Reviews []Review `json:"reviews"`
-	Type    string   `json:"type"` // "pre_launch" or "post_launch"
-	Count   int      `json:"count"`
+	Reviews    []Review          `json:"reviews"`
+	Type       string            `json:"type"` // "pre_launch" or "post_launch"
+	Count      int               `json:"count"`
+	Sentiments []SentimentResult `json:"sentiments"` // per-review labels for the analyzed reviews
 }
 
 // SentimentResult represents sentiment analysis for a review
 type SentimentResult struct {
-	ReviewID  string  `json:"review_id"`
-	Sentiment string  `json:"sentiment"` // positive, negative, neutral
-	Score     float64 `json:"score"`     // confidence score
+	ReviewID   string  `json:"review_id"`
+	Sentiment  string  `json:"sentiment"`            // positive, negative, neutral
+	Score      float64 `json:"score"`                // confidence score
+	Overridden bool    `json:"overridden,omitempty"` // label set by a reviewer, not the model
 }
 
 // ThemeResult represents an extracted theme
 type ThemeResult struct {
-	Theme       string `json:"theme"`
-	PreCount    int    `json:"pre_count"`
-	PostCount   int    `json:"post_count"`
-	ChangeRate  float64 `json:"change_rate"` // percentage change
-	Sentiment   string  `json:"sentiment"`   // overall sentiment for this theme
+	Theme         string          `json:"theme"`
+	Parent        string          `json:"parent,omitempty"`
+	PreCount      int             `json:"pre_count"`
+	PostCount     int             `json:"post_count"`
+	ChangeRate    float64         `json:"change_rate"`              // percentage change
+	PreSentiment  SentimentCounts `json:"pre_sentiment"`            // aspect sentiment in pre-launch reviews
+	PostSentiment SentimentCounts `json:"post_sentiment"`           // aspect sentiment in post-launch reviews
+	Evidence      []Evidence      `json:"evidence"`                 // verified quotes mentioning the theme
+	Proposed      bool            `json:"proposed,omitempty"`       // not yet an approved taxonomy theme
+	Highlighted   bool            `json:"highlighted,omitempty"`    // change rate above the requested minimum
+	PreReviewIDs  []string        `json:"pre_review_ids,omitempty"` // reviews mentioning the theme, for drill-down
+	PostReviewIDs []string        `json:"post_review_ids,omitempty"`
+	SubThemes     []ThemeResult   `json:"sub_themes,omitempty"` // counts above include these
 }
 
 // SentimentSummary aggregates sentiment data
@@ -47,16 +57,20 @@ type ComparisonResult struct {
 	PostLaunchSentiment SentimentSummary `json:"post_launch_sentiment"`
 	SentimentShift      float64          `json:"sentiment_shift"` // positive = improvement
 	Themes              []ThemeResult    `json:"themes"`
+	LongTail            *LongTailBucket  `json:"long_tail,omitempty"` // themes below the reporting thresholds
 }
 
 // ImpactSummary provides the overall launch impact analysis
 type ImpactSummary struct {
-	OverallSuccess    bool     `json:"overall_success"`
-	SuccessScore      float64  `json:"success_score"` // 0-100
-	KeyImprovements   []string `json:"key_improvements"`
-	CriticalIssues    []string `json:"critical_issues"`
-	Recommendations   []string `json:"recommendations"`
-	ExecutiveSummary  string   `json:"executive_summary"`
+	OverallSuccess   bool          `json:"overall_success"`
+	SuccessScore     float64       `json:"success_score"` // 0-100
+	KeyImprovements  []ImpactClaim `json:"key_improvements"`
+	CriticalIssues   []ImpactClaim `json:"critical_issues"`
+	Recommendations  []string      `json:"recommendations"`
+	ExecutiveSummary string        `json:"executive_summary"`
+
+	ScoreBreakdown    *ScoreBreakdown `json:"score_breakdown,omitempty"`
+	UnverifiedNumbers []NumberCheck   `json:"unverified_numbers"` // narrative numbers not found in the data
 }
 
 // AnalysisResult is the complete analysis response
@@ -65,15 +79,40 @@ type AnalysisResult struct {
 	PostLaunchReviews ReviewCollection  `json:"post_launch_reviews"`
 	Comparison        ComparisonResult  `json:"comparison"`
 	Impact            ImpactSummary     `json:"impact"`
+	Aspects           []AspectSentiment `json:"aspects"`
+	NewIssues         []EmergingIssue   `json:"new_issues"`       // themes that barely existed before launch
+	Policy            *PolicyResult     `json:"policy,omitempty"` // regression gate outcome, when a policy is configured
 	AnalyzedAt        string            `json:"analyzed_at"`
+	Metadata          AnalysisMetadata  `json:"metadata"`
+}
+
+// CacheStats reports sentiment cache usage for a single analysis
+type CacheStats struct {
+	Hits    int     `json:"hits"`
+	Misses  int     `json:"misses"`
+	HitRate float64 `json:"hit_rate"` // hits / (hits + misses)
+}
+
+// AnalysisMetadata records how an analysis was produced
+type AnalysisMetadata struct {
+	DatasetID      string          `json:"dataset_id"`
+	SentimentCache CacheStats      `json:"sentiment_cache"`
+	Usage          UsageSummary    `json:"usage"`
+	Budget         *BudgetDecision `json:"budget,omitempty"`
+	Sampling       *SamplingReport `json:"sampling,omitempty"`
+	Clustering     *ClusteringInfo `json:"clustering,omitempty"` // set when themes were discovered by clustering
+	DroppedQuotes  int             `json:"dropped_quotes"`       // evidence rejected as not found in its review
+	Overrides      int             `json:"overrides_applied"`    // reviewer sentiment overrides used in this run
+	Generation     *GenerationInfo `json:"generation,omitempty"`
+	ProviderCalls  []LLMCall       `json:"provider_calls"`
 }
 
 // UploadResponse is returned after successful file upload
 type UploadResponse struct {
-	Success          bool   `json:"success"`
-	PreLaunchCount   int    `json:"pre_launch_count"`
-	PostLaunchCount  int    `json:"post_launch_count"`
-	Message          string `json:"message"`
+	Success         bool   `json:"success"`
+	PreLaunchCount  int    `json:"pre_launch_count"`
+	PostLaunchCount int    `json:"post_launch_count"`
+	Message         string `json:"message"`
 }
 
 // ErrorResponse represents an error response
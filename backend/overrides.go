@@ -0,0 +1,285 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Override is a reviewer's correction of the model's labels for one review
+type Override struct {
+	ID         string    `json:"id"`
+	Phase      string    `json:"phase"` // "pre_launch" or "post_launch"
+	ReviewID   string    `json:"review_id"`
+	ReviewHash string    `json:"review_hash"`         // guards against a different review reusing the ID
+	Sentiment  string    `json:"sentiment,omitempty"` // replaces the model's sentiment when set
+	Themes     *[]string `json:"themes,omitempty"`    // replaces the review's theme assignment when set
+	Author     string    `json:"author"`
+	Reason     string    `json:"reason"`
+	CreatedAt  string    `json:"created_at"`
+	Review     Review    `json:"review"` // snapshot used for export
+}
+
+// OverrideStore persists overrides; the latest override for a review wins
+type OverrideStore struct {
+	mu        sync.RWMutex
+	path      string
+	overrides []Override
+}
+
+// NewOverrideStore creates a store persisted at path ("" keeps it in memory)
+func NewOverrideStore(path string) (*OverrideStore, error) {
+	s := &OverrideStore{path: path}
+	if err := loadJSONFile(path, &s.overrides); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns every override, oldest first
+func (s *OverrideStore) List() []Override {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Override{}, s.overrides...)
+}
+
+// Add validates and records an override for the given review
+func (s *OverrideStore) Add(o Override, review Review) (Override, error) {
+	o.Sentiment = strings.ToLower(strings.TrimSpace(o.Sentiment))
+	if o.Sentiment != "" && !containsString(sentimentClasses, o.Sentiment) {
+		return o, fmt.Errorf("sentiment must be one of %s", strings.Join(sentimentClasses, ", "))
+	}
+	if o.Sentiment == "" && o.Themes == nil {
+		return o, fmt.Errorf("an override must set a sentiment, themes or both")
+	}
+	if strings.TrimSpace(o.Author) == "" || strings.TrimSpace(o.Reason) == "" {
+		return o, fmt.Errorf("author and reason are required")
+	}
+	if o.Themes != nil {
+		themes := []string{}
+		for _, t := range *o.Themes {
+			if t = strings.TrimSpace(t); t != "" {
+				themes = append(themes, t)
+			}
+		}
+		o.Themes = &themes
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return o, fmt.Errorf("failed to generate override id: %w", err)
+	}
+	o.ID = hex.EncodeToString(id)
+	o.ReviewID = review.ID
+	o.ReviewHash = reviewHash(review)
+	o.Review = review
+	o.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = append(s.overrides, o)
+	return o, saveJSONFile(s.path, s.overrides)
+}
+
+// Delete removes an override by ID
+func (s *OverrideStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.overrides {
+		if o.ID == id {
+			s.overrides = append(s.overrides[:i], s.overrides[i+1:]...)
+			return saveJSONFile(s.path, s.overrides)
+		}
+	}
+	return fmt.Errorf("override %q not found", id)
+}
+
+// resolved merges the overrides per review, later ones replacing earlier fields
+func (s *OverrideStore) resolved() map[string]Override {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]Override)
+	for _, o := range s.overrides {
+		key := overrideKey(o.Phase, o.ReviewID, o.ReviewHash)
+		merged, ok := latest[key]
+		if !ok {
+			latest[key] = o
+			continue
+		}
+		if o.Sentiment != "" {
+			merged.Sentiment = o.Sentiment
+		}
+		if o.Themes != nil {
+			merged.Themes = o.Themes
+		}
+		merged.Author, merged.Reason, merged.CreatedAt = o.Author, o.Reason, o.CreatedAt
+		latest[key] = merged
+	}
+	return latest
+}
+
+// applySentimentOverrides replaces model sentiment with reviewer labels, so
+// overrides take precedence in calculateSentimentSummary and extrapolation
+func (s *OverrideStore) applySentimentOverrides(phase string, reviews []Review, sentiments []SentimentResult) int {
+	overrides := s.resolved()
+	byID := make(map[string]Review, len(reviews))
+	for _, r := range reviews {
+		byID[r.ID] = r
+	}
+
+	applied := 0
+	for i, result := range sentiments {
+		review, ok := byID[result.ReviewID]
+		if !ok {
+			continue
+		}
+		o, ok := overrides[overrideKey(phase, review.ID, reviewHash(review))]
+		if !ok || o.Sentiment == "" {
+			continue
+		}
+		sentiments[i].Sentiment = o.Sentiment
+		sentiments[i].Score = 1
+		sentiments[i].Overridden = true
+		applied++
+	}
+	return applied
+}
+
+// applyThemeOverrides reassigns reviews between themes. It works on the flat
+// theme list, before sub-themes are rolled up into their parents
+func (s *OverrideStore) applyThemeOverrides(themes []ThemeResult, preReviews, postReviews []Review) []ThemeResult {
+	overrides := s.resolved()
+	for _, phase := range []struct {
+		name    string
+		reviews []Review
+	}{{"pre_launch", preReviews}, {"post_launch", postReviews}} {
+		for _, review := range phase.reviews {
+			o, ok := overrides[overrideKey(phase.name, review.ID, reviewHash(review))]
+			if !ok || o.Themes == nil {
+				continue
+			}
+			assigned := make(map[string]bool)
+			for _, t := range *o.Themes {
+				assigned[themeKey(t)] = true
+			}
+
+			for i := range themes {
+				key := themeKey(themes[i].Theme)
+				themes[i].assignReview(phase.name, review.ID, assigned[key])
+				delete(assigned, key)
+			}
+			// Themes the reviewer named that the model did not produce
+			for _, t := range *o.Themes {
+				if !assigned[themeKey(t)] {
+					continue
+				}
+				delete(assigned, themeKey(t))
+				theme := ThemeResult{Theme: t, Evidence: []Evidence{}}
+				theme.assignReview(phase.name, review.ID, true)
+				themes = append(themes, theme)
+			}
+		}
+	}
+	return themes
+}
+
+// assignReview adds or removes a review from a theme and recomputes its change rate
+func (t *ThemeResult) assignReview(phase, reviewID string, assigned bool) {
+	ids, count := &t.PostReviewIDs, &t.PostCount
+	if phase == "pre_launch" {
+		ids, count = &t.PreReviewIDs, &t.PreCount
+	}
+
+	present := -1
+	for i, id := range *ids {
+		if id == reviewID {
+			present = i
+			break
+		}
+	}
+	switch {
+	case assigned && present < 0:
+		*ids = append(*ids, reviewID)
+		*count++
+	case !assigned && present >= 0:
+		*ids = append((*ids)[:present], (*ids)[present+1:]...)
+		*count--
+	default:
+		return
+	}
+	t.ChangeRate = changeRate(t.PreCount, t.PostCount)
+}
+
+// ExportCSV writes the resolved labels in the labeled-dataset format read by
+// ParseLabeledCSV, so corrections can be replayed with the evaluate command
+func (s *OverrideStore) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "date", "user_id", "review_text", "rating", "source", "phase", "gold_sentiment", "gold_themes", "author", "reason"}); err != nil {
+		return err
+	}
+	for _, o := range s.sortedResolved() {
+		themes := ""
+		if o.Themes != nil {
+			themes = strings.Join(*o.Themes, ";")
+		}
+		r := o.Review
+		if err := writer.Write([]string{r.ID, r.Date, r.UserID, r.ReviewText, strconv.Itoa(r.Rating), r.Source, o.Phase, o.Sentiment, themes, o.Author, o.Reason}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportJSONL writes one training example per line
+func (s *OverrideStore) ExportJSONL(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, o := range s.sortedResolved() {
+		example := map[string]interface{}{
+			"text":      o.Review.ReviewText,
+			"phase":     o.Phase,
+			"review_id": o.ReviewID,
+			"sentiment": o.Sentiment,
+			"themes":    o.Themes,
+		}
+		if err := encoder.Encode(example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedResolved returns the resolved overrides in creation order
+func (s *OverrideStore) sortedResolved() []Override {
+	resolved := s.resolved()
+	var ordered []Override
+	seen := make(map[string]bool)
+	for _, o := range s.List() {
+		key := overrideKey(o.Phase, o.ReviewID, o.ReviewHash)
+		if !seen[key] {
+			seen[key] = true
+			ordered = append(ordered, resolved[key])
+		}
+	}
+	return ordered
+}
+
+// overrideKey identifies the review an override applies to
+func overrideKey(phase, reviewID, hash string) string {
+	return phase + "\x00" + reviewID + "\x00" + hash
+}
+
+// reviewHash fingerprints the review text so overrides only apply to the review they were made on
+func reviewHash(r Review) string {
+	sum := sha256.Sum256([]byte(r.ReviewText))
+	return hex.EncodeToString(sum[:])[:16]
+}
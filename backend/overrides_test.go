@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestThemeOverridesRetallySentiment(t *testing.T) {
+	post := []Review{{ID: "r1", ReviewText: "Charged twice"}, {ID: "r2", ReviewText: "Crashes on launch"}}
+	aspects := []AspectSentiment{
+		{ReviewID: "r1", Phase: "post_launch", Aspect: "Billing", Sentiment: "negative"},
+		{ReviewID: "r2", Phase: "post_launch", Aspect: "Crashes", Sentiment: "negative"},
+		{ReviewID: "r2", Phase: "post_launch", Aspect: "crashes", Sentiment: "neutral"},
+	}
+	themes := []ThemeResult{{Theme: "Billing"}, {Theme: "Crashes"}}
+	applyAspects(themes, aspects)
+	if want := (SentimentCounts{Negative: 1, Neutral: 1}); themes[1].PostSentiment != want {
+		t.Fatalf("Crashes tally = %+v, want %+v", themes[1].PostSentiment, want)
+	}
+
+	store, err := NewOverrideStore("")
+	if err != nil {
+		t.Fatalf("NewOverrideStore: %v", err)
+	}
+	moved := []string{"Crashes", "Onboarding"}
+	if _, err := store.Add(Override{Phase: "post_launch", Sentiment: "positive", Themes: &moved, Author: "qa", Reason: "misread"}, post[0]); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	sentiments := []SentimentResult{{ReviewID: "r1", Sentiment: "negative"}, {ReviewID: "r2", Sentiment: "negative"}}
+	store.applySentimentOverrides("post_launch", post, sentiments)
+	themes = store.applyThemeOverrides(themes, nil, post)
+	tallyThemeSentiment(themes, aspects, overallSentiments(nil, sentiments))
+
+	want := map[string]SentimentCounts{
+		"Billing":    {},
+		"Crashes":    {Positive: 1, Negative: 1, Neutral: 1},
+		"Onboarding": {Positive: 1},
+	}
+	if len(themes) != len(want) {
+		t.Fatalf("got %d themes, want %d", len(themes), len(want))
+	}
+	for _, theme := range themes {
+		if theme.PostSentiment != want[theme.Theme] {
+			t.Errorf("%s tally = %+v, want %+v", theme.Theme, theme.PostSentiment, want[theme.Theme])
+		}
+		total := theme.PostSentiment.Positive + theme.PostSentiment.Negative + theme.PostSentiment.Neutral
+		if theme.PostCount > 0 && total < theme.PostCount {
+			t.Errorf("%s tallies %d mentions for %d reviews", theme.Theme, total, theme.PostCount)
+		}
+	}
+}
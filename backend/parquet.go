@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a row group is
+// written, bounding memory use for large exports
+const parquetRowGroupSize = 10000
+
+// Parquet format constants (see parquet.thrift)
+const (
+	parquetMagic = "PAR1"
+
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetUTF8     = 0 // ConvertedType for strings
+	parquetPlain    = 0 // Encoding
+	parquetDataPage = 0 // PageType
+	parquetRLE      = 3 // Encoding, declared for the (absent) levels
+)
+
+// parquetTableWriter writes an uncompressed, PLAIN-encoded Parquet file with
+// only required columns, so pages need no repetition or definition levels
+type parquetTableWriter struct {
+	w         *countingWriter
+	columns   []ExportColumn
+	buffers   []bytes.Buffer // PLAIN-encoded values of the current row group
+	bools     [][]bool       // boolean columns are bit-packed when the group is written
+	rows      int            // rows in the current row group
+	totalRows int64
+	groups    []parquetRowGroup
+	err       error
+}
+
+// parquetRowGroup records where a written row group's column chunks are
+type parquetRowGroup struct {
+	rows    int64
+	offsets []int64 // file offset of each column's data page
+	sizes   []int64 // bytes of each column chunk, page header included
+}
+
+// countingWriter tracks the number of bytes written, for file offsets
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newParquetTableWriter(w io.Writer, columns []ExportColumn) *parquetTableWriter {
+	t := &parquetTableWriter{
+		w:       &countingWriter{w: w},
+		columns: columns,
+		buffers: make([]bytes.Buffer, len(columns)),
+		bools:   make([][]bool, len(columns)),
+	}
+	_, t.err = io.WriteString(t.w, parquetMagic)
+	return t
+}
+
+func (t *parquetTableWriter) WriteRow(values []interface{}) error {
+	if t.err != nil {
+		return t.err
+	}
+	var scratch [8]byte
+	for i, v := range values {
+		buf := &t.buffers[i]
+		switch t.columns[i].Kind {
+		case intColumn:
+			n, _ := v.(int)
+			binary.LittleEndian.PutUint64(scratch[:], uint64(n))
+			buf.Write(scratch[:])
+		case floatColumn:
+			f, _ := v.(float64)
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+			buf.Write(scratch[:])
+		case boolColumn:
+			b, _ := v.(bool)
+			t.bools[i] = append(t.bools[i], b)
+		default:
+			s := formatCell(v)
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+			buf.Write(scratch[:4])
+			buf.WriteString(s)
+		}
+	}
+	t.rows++
+	if t.rows >= parquetRowGroupSize {
+		t.err = t.flushRowGroup()
+	}
+	return t.err
+}
+
+// flushRowGroup writes the buffered rows as one row group, one data page per column
+func (t *parquetTableWriter) flushRowGroup() error {
+	if t.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: int64(t.rows)}
+	for i := range t.columns {
+		if t.columns[i].Kind == boolColumn {
+			t.buffers[i].Write(packBools(t.bools[i]))
+			t.bools[i] = t.bools[i][:0]
+		}
+		values := t.buffers[i].Bytes()
+
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(values))) // uncompressed_page_size
+		header.i32(3, int32(len(values))) // compressed_page_size
+		header.beginStructField(5)        // data_page_header
+		header.i32(1, int32(t.rows))      // num_values
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		page := header.finish()
+
+		group.offsets = append(group.offsets, t.w.n)
+		group.sizes = append(group.sizes, int64(len(page)+len(values)))
+		if _, err := t.w.Write(page); err != nil {
+			return err
+		}
+		if _, err := t.w.Write(values); err != nil {
+			return err
+		}
+		t.buffers[i].Reset()
+	}
+	t.groups = append(t.groups, group)
+	t.totalRows += int64(t.rows)
+	t.rows = 0
+	return nil
+}
+
+// Close writes any buffered rows and the file footer
+func (t *parquetTableWriter) Close() error {
+	if t.err != nil {
+		return t.err
+	}
+	if err := t.flushRowGroup(); err != nil {
+		return err
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1) // version
+	meta.listField(2, thriftStruct, len(t.columns)+1)
+	meta.beginStruct() // root of the schema tree
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(t.columns)))
+	meta.endStruct()
+	for _, c := range t.columns {
+		meta.beginStruct()
+		meta.i32(1, parquetType(c.Kind))
+		meta.i32(3, parquetRequired)
+		meta.binary(4, c.Name)
+		if c.Kind == textColumn {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, t.totalRows)
+	meta.listField(4, thriftStruct, len(t.groups))
+	for _, g := range t.groups {
+		meta.beginStruct()
+		meta.listField(1, thriftStruct, len(t.columns))
+		var total int64
+		for i, c := range t.columns {
+			total += g.sizes[i]
+			meta.beginStruct()
+			meta.i64(2, g.offsets[i]) // file_offset
+			meta.beginStructField(3)  // meta_data
+			meta.i32(1, parquetType(c.Kind))
+			meta.listField(2, thriftI32, 1)
+			meta.listI32(parquetPlain)
+			meta.listField(3, thriftBinary, 1)
+			meta.listBinary(c.Name)
+			meta.i32(4, 0) // codec: uncompressed
+			meta.i64(5, g.rows)
+			meta.i64(6, g.sizes[i])
+			meta.i64(7, g.sizes[i])
+			meta.i64(9, g.offsets[i])
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, total)
+		meta.i64(3, g.rows)
+		meta.endStruct()
+	}
+	meta.binary(6, "enterpret launch analyzer")
+	footer := meta.finish()
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, part := range [][]byte{footer, length[:], []byte(parquetMagic)} {
+		if _, err := t.w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parquetType maps a column kind to its Parquet physical type
+func parquetType(kind columnKind) int32 {
+	switch kind {
+	case intColumn:
+		return parquetInt64
+	case floatColumn:
+		return parquetDouble
+	case boolColumn:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// packBools bit-packs booleans least significant bit first, as PLAIN requires
+func packBools(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+// Thrift compact protocol type codes used by the Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Parquet metadata structs with the Thrift compact protocol
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField []int16 // last field ID written, per open struct
+}
+
+// newThriftWriter starts encoding a top-level struct
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastField: []int16{0}}
+}
+
+// finish closes the top-level struct and returns the encoding
+func (t *thriftWriter) finish() []byte {
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	top := len(t.lastField) - 1
+	if delta := id - t.lastField[top]; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.lastField[top] = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	t.buf.Write(scratch[:binary.PutUvarint(scratch[:], v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.listBinary(s)
+}
+
+// beginStructField opens a struct-typed field; close it with endStruct
+func (t *thriftWriter) beginStructField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginStruct()
+}
+
+// beginStruct opens a struct that is a list element
+func (t *thriftWriter) beginStruct() {
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0) // field stop
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+// listField writes the header of a list field; the elements follow
+func (t *thriftWriter) listField(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) listBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
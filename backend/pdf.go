@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 page size in points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+)
+
+// PDF fonts; all are standard Type 1 fonts every reader provides, so nothing is embedded
+const (
+	fontRegular = "F1" // Helvetica
+	fontBold    = "F2" // Helvetica-Bold
+	fontItalic  = "F3" // Helvetica-Oblique, same metrics as Helvetica
+)
+
+// helveticaWidths and helveticaBoldWidths are the AFM advance widths (1/1000
+// em) of the printable ASCII characters, starting at the space
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// winAnsiPunctuation maps the typographic characters WinAnsiEncoding places in 0x80-0x9f
+var winAnsiPunctuation = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// toWinAnsi encodes text for the standard fonts; characters they cannot show become '?'
+func toWinAnsi(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\t':
+			encoded = append(encoded, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			encoded = append(encoded, byte(r))
+		default:
+			if b, ok := winAnsiPunctuation[r]; ok {
+				encoded = append(encoded, b)
+			} else if r >= 0x20 {
+				encoded = append(encoded, '?')
+			}
+		}
+	}
+	return encoded
+}
+
+// textWidth measures text in points as it will be set in Helvetica
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range toWinAnsi(s) {
+		if b >= 0x20 && b < 0x7f {
+			total += widths[b-0x20]
+		} else {
+			total += 556 // accented letters are close to the average lower-case width
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrapText breaks text into lines no wider than maxWidth points
+func wrapText(s string, size, maxWidth float64, bold bool) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && textWidth(candidate, size, bold) > maxWidth {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfDocument builds a PDF of vector text and shapes with the standard fonts
+type pdfDocument struct {
+	title string
+	pages []*pdfPage
+}
+
+// pdfPage is one page's content stream. Coordinates have the origin at the
+// bottom left, as in PDF
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func newPDFDocument(title string) *pdfDocument {
+	return &pdfDocument{title: title}
+}
+
+// addPage starts a new A4 page
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// text draws a single line of text with its baseline at y
+func (p *pdfPage) text(x, y float64, font string, size float64, color, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n",
+		font, pdfNumber(size), pdfColor(color), pdfNumber(x), pdfNumber(y), pdfEscape(toWinAnsi(s)))
+}
+
+// rect fills a rectangle whose bottom-left corner is at x, y
+func (p *pdfPage) rect(x, y, w, h float64, color string) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		pdfColor(color), pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+// line strokes a straight line
+func (p *pdfPage) line(x1, y1, x2, y2, width float64, color string) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		pdfColor(color), pdfNumber(width), pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+// chart draws chart shapes laid out in a box whose top-left corner is at x, top
+func (p *pdfPage) chart(shapes []chartShape, x, top float64) {
+	for _, s := range shapes {
+		switch s.Kind {
+		case "rect":
+			p.rect(x+s.X, top-s.Y-s.H, s.W, s.H, s.Color)
+		case "line":
+			p.line(x+s.X, top-s.Y, x+s.X+s.W, top-s.Y-s.H, 0.5, s.Color)
+		case "text":
+			tx := x + s.X
+			switch s.Anchor {
+			case "middle":
+				tx -= textWidth(s.Text, s.Size, false) / 2
+			case "end":
+				tx -= textWidth(s.Text, s.Size, false)
+			}
+			p.text(tx, top-s.Y, fontRegular, s.Size, s.Color, s.Text)
+		}
+	}
+}
+
+// WriteTo serialises the document with a cross-reference table
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: w}
+	var offsets []int64
+	object := func(body string) error {
+		offsets = append(offsets, out.n)
+		_, err := fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return err
+	}
+
+	// Objects 1-5 are fixed; each page then takes a page object and a content stream
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	if _, err := io.WriteString(out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return out.n, err
+	}
+	fixed := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Oblique /Encoding /WinAnsiEncoding >>",
+	}
+	for _, body := range fixed {
+		if err := object(body); err != nil {
+			return out.n, err
+		}
+	}
+
+	for i, page := range d.pages {
+		if err := object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(pdfPageWidth), pdfNumber(pdfPageHeight), firstPage+2*i+1)); err != nil {
+			return out.n, err
+		}
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(page.content.Bytes())
+		if err := zw.Close(); err != nil {
+			return out.n, err
+		}
+		if err := object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes())); err != nil {
+			return out.n, err
+		}
+	}
+
+	info := fmt.Sprintf("<< /Title (%s) /Producer (enterpret launch analyzer) /CreationDate (D:%s) >>",
+		pdfEscape(toWinAnsi(d.title)), time.Now().UTC().Format("20060102150405Z"))
+	if err := object(info); err != nil {
+		return out.n, err
+	}
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	_, err := fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, len(offsets), xref)
+	return out.n, err
+}
+
+// pdfEscape escapes a string literal's delimiters
+func pdfEscape(b []byte) string {
+	var s strings.Builder
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			s.WriteByte('\\')
+		}
+		s.WriteByte(c)
+	}
+	return s.String()
+}
+
+// pdfColor converts a #rrggbb colour to PDF's 0-1 RGB operands
+func pdfColor(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return "0 0 0"
+	}
+	parts := make([]string, 3)
+	for i := range parts {
+		v, _ := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		parts[i] = pdfNumber(float64(v) / 255)
+	}
+	return strings.Join(parts, " ")
+}
+
+// pdfNumber prints a number compactly with at most two decimals
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(round2(v), 'f', -1, 64)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed policies/*.yaml
+var defaultPolicyFS embed.FS
+
+// Policy statuses, in increasing order of severity
+const (
+	policyPass = "pass"
+	policyWarn = "warn"
+	policyFail = "fail"
+)
+
+// zScore95 is the two-sided normal critical value at 95% confidence
+const zScore95 = 1.96
+
+// Policy is a named set of launch regression rules
+type Policy struct {
+	Name  string       `json:"name" yaml:"name"`
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// PolicyRule flags the launch when a metric crosses a threshold. The
+// condition is either spelled out in Metric, Op and Value or written as a
+// When expression such as "sentiment_shift < -5"
+type PolicyRule struct {
+	Name        string  `json:"name" yaml:"name"`
+	When        string  `json:"when,omitempty" yaml:"when,omitempty"`
+	Metric      string  `json:"metric" yaml:"metric"`
+	Op          string  `json:"op" yaml:"op"` // <, <=, > or >=
+	Value       float64 `json:"value" yaml:"value"`
+	Significant bool    `json:"significant,omitempty" yaml:"significant,omitempty"` // only trigger on statistically significant changes
+	Severity    string  `json:"severity" yaml:"severity"`                           // warn or fail (default)
+}
+
+// PolicyResult is the outcome of evaluating a policy against an analysis
+type PolicyResult struct {
+	Policy      string        `json:"policy"`
+	Status      string        `json:"status"` // pass, warn or fail
+	Rules       []RuleOutcome `json:"rules"`
+	EvaluatedAt string        `json:"evaluated_at"`
+}
+
+// RuleOutcome explains one rule's verdict
+type RuleOutcome struct {
+	Rule     string   `json:"rule"`
+	Status   string   `json:"status"`
+	Reason   string   `json:"reason"`
+	Value    *float64 `json:"value,omitempty"`   // the metric, for scalar metrics
+	Matches  []string `json:"matches,omitempty"` // the offending themes, for per-theme metrics
+	Severity string   `json:"severity"`
+}
+
+// scalarMetrics are computed once per analysis
+var scalarMetrics = map[string]func(*AnalysisResult) float64{
+	"success_score":   func(r *AnalysisResult) float64 { return r.Impact.SuccessScore },
+	"sentiment_shift": func(r *AnalysisResult) float64 { return r.Comparison.SentimentShift },
+	"rating_delta": func(r *AnalysisResult) float64 {
+		return r.Comparison.PostLaunchSentiment.Average - r.Comparison.PreLaunchSentiment.Average
+	},
+	"post_rating":        func(r *AnalysisResult) float64 { return r.Comparison.PostLaunchSentiment.Average },
+	"post_negative_rate": func(r *AnalysisResult) float64 { return negativeRate(r.Comparison.PostLaunchSentiment) },
+	"negative_rate_change": func(r *AnalysisResult) float64 {
+		return negativeRate(r.Comparison.PostLaunchSentiment) - negativeRate(r.Comparison.PreLaunchSentiment)
+	},
+	"new_issues": func(r *AnalysisResult) float64 { return float64(len(r.NewIssues)) },
+}
+
+// themeMetric is a per-theme value; a rule on it triggers if any theme crosses the threshold
+type themeMetric struct {
+	Name  string
+	Value float64
+}
+
+// themeMetrics compute a value per theme, as a percentage of post-launch reviews
+var themeMetrics = map[string]func(*AnalysisResult) []themeMetric{
+	"theme_post_share": func(r *AnalysisResult) []themeMetric {
+		total := float64(sentimentTotal(r.Comparison.PostLaunchSentiment))
+		if total == 0 {
+			return nil
+		}
+		var values []themeMetric
+		for _, t := range flattenThemes(allThemes(r.Comparison)) {
+			values = append(values, themeMetric{t.Theme, float64(t.PostCount) / total * 100})
+		}
+		return values
+	},
+	"theme_change_rate": func(r *AnalysisResult) []themeMetric {
+		var values []themeMetric
+		for _, t := range flattenThemes(allThemes(r.Comparison)) {
+			values = append(values, themeMetric{t.Theme, t.ChangeRate})
+		}
+		return values
+	},
+	// new themes are the emerging issues detected by detectEmergingIssues
+	"new_theme_share": func(r *AnalysisResult) []themeMetric {
+		var values []themeMetric
+		for _, issue := range r.NewIssues {
+			values = append(values, themeMetric{issue.Theme, issue.PostRate})
+		}
+		return values
+	},
+	// a new theme is negative when at least half its post-launch mentions are
+	"new_negative_theme_share": func(r *AnalysisResult) []themeMetric {
+		var values []themeMetric
+		for _, issue := range r.NewIssues {
+			if issue.NegativeShare >= 0.5 {
+				values = append(values, themeMetric{issue.Theme, issue.PostRate})
+			}
+		}
+		return values
+	},
+}
+
+// significanceMargins are 95% margins for the metrics that support significance testing
+var significanceMargins = map[string]func(*AnalysisResult) (float64, bool){
+	"sentiment_shift": func(r *AnalysisResult) (float64, bool) {
+		margin, ok := proportionMargin(r.Comparison.PreLaunchSentiment, r.Comparison.PostLaunchSentiment, func(s SentimentSummary) int { return s.Positive })
+		// A sampled analysis carries its own, usually wider, sampling margin
+		if r.Metadata.Sampling != nil {
+			margin = math.Max(margin, r.Metadata.Sampling.SentimentShiftMargin)
+		}
+		return margin, ok
+	},
+	"negative_rate_change": func(r *AnalysisResult) (float64, bool) {
+		margin, ok := proportionMargin(r.Comparison.PreLaunchSentiment, r.Comparison.PostLaunchSentiment, func(s SentimentSummary) int { return s.Negative })
+		if r.Metadata.Sampling != nil {
+			margin = math.Max(margin, r.Metadata.Sampling.NegativeRateMargin)
+		}
+		return margin, ok
+	},
+	"rating_delta": func(r *AnalysisResult) (float64, bool) {
+		return meanDifferenceMargin(r.PreLaunchReviews.Reviews, r.PostLaunchReviews.Reviews)
+	},
+}
+
+// defaultPolicyFile is the built-in policy used when POLICY_FILE is unset
+const defaultPolicyFile = "policies/default.yaml"
+
+// LoadPolicy reads a YAML or JSON policy; an empty path loads the built-in default
+func LoadPolicy(path string) (*Policy, error) {
+	var data []byte
+	var err error
+	if path == "" {
+		data, err = defaultPolicyFS.ReadFile(defaultPolicyFile)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	return ParsePolicy(data, format)
+}
+
+// ParsePolicy decodes and validates a policy in "yaml" or "json"
+func ParsePolicy(data []byte, format string) (*Policy, error) {
+	var policy Policy
+	var err error
+	if format == "json" {
+		err = json.Unmarshal(data, &policy)
+	} else {
+		err = yaml.Unmarshal(data, &policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// validate normalises the rules, expanding When expressions
+func (p *Policy) validate() error {
+	if p.Name == "" {
+		p.Name = "policy"
+	}
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("policy %s has no rules", p.Name)
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.When != "" {
+			fields := strings.Fields(rule.When)
+			if len(fields) != 3 {
+				return fmt.Errorf("rule %d: when must be \"<metric> <op> <number>\", got %q", i+1, rule.When)
+			}
+			value, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return fmt.Errorf("rule %d: invalid number in %q", i+1, rule.When)
+			}
+			rule.Metric, rule.Op, rule.Value = fields[0], fields[1], value
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%s %s %g", rule.Metric, rule.Op, rule.Value)
+		}
+		if _, scalar := scalarMetrics[rule.Metric]; !scalar {
+			if _, perTheme := themeMetrics[rule.Metric]; !perTheme {
+				return fmt.Errorf("rule %q: unknown metric %q (known: %s)", rule.Name, rule.Metric, strings.Join(policyMetricNames(), ", "))
+			}
+		}
+		if compare(0, rule.Op, 0) == nil {
+			return fmt.Errorf("rule %q: op must be <, <=, > or >=", rule.Name)
+		}
+		if _, ok := significanceMargins[rule.Metric]; rule.Significant && !ok {
+			return fmt.Errorf("rule %q: significance is only supported for sentiment_shift, negative_rate_change and rating_delta", rule.Name)
+		}
+		switch rule.Severity {
+		case "":
+			rule.Severity = policyFail
+		case policyWarn, policyFail:
+		default:
+			return fmt.Errorf("rule %q: severity must be warn or fail", rule.Name)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every rule; the result is the most severe triggered status
+func (p *Policy) Evaluate(result *AnalysisResult) PolicyResult {
+	outcome := PolicyResult{
+		Policy:      p.Name,
+		Status:      policyPass,
+		Rules:       []RuleOutcome{},
+		EvaluatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, rule := range p.Rules {
+		ruleOutcome := rule.evaluate(result)
+		if ruleOutcome.Status == policyFail || (ruleOutcome.Status == policyWarn && outcome.Status == policyPass) {
+			outcome.Status = ruleOutcome.Status
+		}
+		outcome.Rules = append(outcome.Rules, ruleOutcome)
+	}
+	return outcome
+}
+
+func (rule PolicyRule) evaluate(result *AnalysisResult) RuleOutcome {
+	outcome := RuleOutcome{Rule: rule.Name, Status: policyPass, Severity: rule.Severity}
+	threshold := fmt.Sprintf("%s %g", rule.Op, rule.Value)
+
+	if metric, ok := scalarMetrics[rule.Metric]; ok {
+		value := round2(metric(result))
+		outcome.Value = &value
+		if !*compare(value, rule.Op, rule.Value) {
+			outcome.Reason = fmt.Sprintf("%s is %g, not %s", rule.Metric, value, threshold)
+			return outcome
+		}
+		if rule.Significant {
+			margin, ok := significanceMargins[rule.Metric](result)
+			if !ok || math.Abs(value) <= margin {
+				outcome.Reason = fmt.Sprintf("%s is %g (%s) but within the ±%.2f margin of error, so not significant", rule.Metric, value, threshold, margin)
+				if !ok {
+					outcome.Reason = fmt.Sprintf("%s is %g (%s) but there is too little data to test significance", rule.Metric, value, threshold)
+				}
+				return outcome
+			}
+			outcome.Status = rule.Severity
+			outcome.Reason = fmt.Sprintf("%s is %g (%s), beyond the ±%.2f margin of error", rule.Metric, value, threshold, margin)
+			return outcome
+		}
+		outcome.Status = rule.Severity
+		outcome.Reason = fmt.Sprintf("%s is %g (%s)", rule.Metric, value, threshold)
+		return outcome
+	}
+
+	for _, m := range themeMetrics[rule.Metric](result) {
+		if *compare(round2(m.Value), rule.Op, rule.Value) {
+			outcome.Matches = append(outcome.Matches, fmt.Sprintf("%s (%g)", m.Name, round2(m.Value)))
+		}
+	}
+	if len(outcome.Matches) == 0 {
+		outcome.Reason = fmt.Sprintf("no theme has %s %s", rule.Metric, threshold)
+		return outcome
+	}
+	outcome.Status = rule.Severity
+	outcome.Reason = fmt.Sprintf("%d themes have %s %s: %s", len(outcome.Matches), rule.Metric, threshold, strings.Join(outcome.Matches, ", "))
+	return outcome
+}
+
+// compare applies a comparison operator, returning nil for an unknown operator
+func compare(a float64, op string, b float64) *bool {
+	var result bool
+	switch op {
+	case "<":
+		result = a < b
+	case "<=":
+		result = a <= b
+	case ">":
+		result = a > b
+	case ">=":
+		result = a >= b
+	default:
+		return nil
+	}
+	return &result
+}
+
+// policyMetricNames lists every metric a rule can use
+func policyMetricNames() []string {
+	var names []string
+	for name := range scalarMetrics {
+		names = append(names, name)
+	}
+	for name := range themeMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// negativeRate is the percentage of reviews classified negative
+func negativeRate(s SentimentSummary) float64 {
+	total := sentimentTotal(s)
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Negative) / float64(total) * 100
+}
+
+// proportionMargin is the 95% margin, in percentage points, of the difference
+// between two proportions (an unpooled two-proportion z-test)
+func proportionMargin(pre, post SentimentSummary, count func(SentimentSummary) int) (float64, bool) {
+	n1, n2 := float64(sentimentTotal(pre)), float64(sentimentTotal(post))
+	if n1 == 0 || n2 == 0 {
+		return 0, false
+	}
+	p1, p2 := float64(count(pre))/n1, float64(count(post))/n2
+	return zScore95 * math.Sqrt(p1*(1-p1)/n1+p2*(1-p2)/n2) * 100, true
+}
+
+// meanDifferenceMargin is the 95% margin of the difference in mean rating
+// (Welch's approximation), skipping reviews without a 1-5 rating
+func meanDifferenceMargin(pre, post []Review) (float64, bool) {
+	variance := func(reviews []Review) (float64, float64, bool) {
+		var values []float64
+		for _, r := range reviews {
+			if r.Rating >= 1 && r.Rating <= 5 {
+				values = append(values, float64(r.Rating))
+			}
+		}
+		n := float64(len(values))
+		if n < 2 {
+			return 0, 0, false
+		}
+		mean := 0.0
+		for _, v := range values {
+			mean += v / n
+		}
+		sumSquares := 0.0
+		for _, v := range values {
+			sumSquares += (v - mean) * (v - mean)
+		}
+		return sumSquares / (n - 1), n, true
+	}
+	v1, n1, ok1 := variance(pre)
+	v2, n2, ok2 := variance(post)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return zScore95 * math.Sqrt(v1/n1+v2/n2), true
+}
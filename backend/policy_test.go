@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		doc    string
+		format string
+		err    string // "" when the policy is valid
+	}{
+		{"when expression", "rules:\n  - when: sentiment_shift < -5\n", "yaml", ""},
+		{"spelled out", "rules:\n  - metric: post_rating\n    op: '>='\n    value: 3.5\n", "yaml", ""},
+		{"json", `{"name":"gate","rules":[{"when":"new_issues > 2","severity":"warn"}]}`, "json", ""},
+		{"theme metric", "rules:\n  - when: theme_post_share >= 20\n", "yaml", ""},
+		{"no rules", "name: empty\n", "yaml", "has no rules"},
+		{"too few fields", "rules:\n  - when: sentiment_shift<-5\n", "yaml", "when must be"},
+		{"not a number", "rules:\n  - when: sentiment_shift < five\n", "yaml", "invalid number"},
+		{"unknown metric", "rules:\n  - when: happiness < 5\n", "yaml", "unknown metric"},
+		{"unknown op", "rules:\n  - when: sentiment_shift == 5\n", "yaml", "op must be"},
+		{"significance unsupported", "rules:\n  - when: post_rating < 3\n    significant: true\n", "yaml", "significance is only supported"},
+		{"bad severity", "rules:\n  - when: post_rating < 3\n    severity: info\n", "yaml", "severity must be"},
+		{"bad yaml", "rules: [", "yaml", "failed to parse"},
+	}
+	for _, c := range cases {
+		_, err := ParsePolicy([]byte(c.doc), c.format)
+		if c.err == "" && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: error = %v, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestParsePolicyExpandsWhen(t *testing.T) {
+	policy, err := ParsePolicy([]byte("rules:\n  - when: \" rating_delta   <=  -0.3 \"\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := policy.Rules[0]
+	if policy.Name != "policy" || rule.Metric != "rating_delta" || rule.Op != "<=" || rule.Value != -0.3 {
+		t.Fatalf("policy %q rule = %+v, want rating_delta <= -0.3", policy.Name, rule)
+	}
+	if rule.Name != "rating_delta <= -0.3" || rule.Severity != policyFail {
+		t.Fatalf("rule named %q with severity %q, want the expression and fail", rule.Name, rule.Severity)
+	}
+}
+
+func TestDefaultPolicyLoads(t *testing.T) {
+	policy, err := LoadPolicy("")
+	if err != nil || len(policy.Rules) == 0 {
+		t.Fatalf("LoadPolicy(\"\") = %+v, %v", policy, err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a    float64
+		op   string
+		b    float64
+		want string // "true", "false" or "nil"
+	}{
+		{1, "<", 2, "true"},
+		{2, "<", 2, "false"},
+		{2, "<=", 2, "true"},
+		{3, "<=", 2, "false"},
+		{3, ">", 2, "true"},
+		{2, ">", 2, "false"},
+		{2, ">=", 2, "true"},
+		{1, ">=", 2, "false"},
+		{1, "==", 1, "nil"},
+		{1, "", 1, "nil"},
+	}
+	for _, c := range cases {
+		got := "nil"
+		if result := compare(c.a, c.op, c.b); result != nil {
+			got = map[bool]string{true: "true", false: "false"}[*result]
+		}
+		if got != c.want {
+			t.Errorf("compare(%g %s %g) = %s, want %s", c.a, c.op, c.b, got, c.want)
+		}
+	}
+}
+
+// policyTestResult has a 10 point drop in positive reviews and a 10 point
+// rise in negative ones, with n reviews per phase
+func policyTestResult(n int) *AnalysisResult {
+	scale := func(pct int) int { return pct * n / 100 }
+	return &AnalysisResult{
+		Comparison: ComparisonResult{
+			PreLaunchSentiment:  SentimentSummary{Positive: scale(60), Negative: scale(20), Neutral: scale(20), Average: 4},
+			PostLaunchSentiment: SentimentSummary{Positive: scale(50), Negative: scale(30), Neutral: scale(20), Average: 3.5},
+			SentimentShift:      -10,
+		},
+		NewIssues: []EmergingIssue{
+			{Theme: "Login loop", PostRate: 12, NegativeShare: 0.9},
+			{Theme: "Dark mode", PostRate: 15, NegativeShare: 0.1},
+		},
+	}
+}
+
+func TestPolicyEvaluateAggregatesSeverity(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"nothing triggers", "  - when: sentiment_shift < -20\n  - when: new_issues > 5\n    severity: warn\n", policyPass},
+		{"a warning", "  - when: sentiment_shift < -20\n  - when: new_issues > 1\n    severity: warn\n", policyWarn},
+		{"a failure", "  - when: rating_delta < -0.3\n", policyFail},
+		{"fail outranks an earlier warn", "  - when: new_issues > 1\n    severity: warn\n  - when: rating_delta < -0.3\n", policyFail},
+		{"a later warn keeps the fail", "  - when: rating_delta < -0.3\n  - when: new_issues > 1\n    severity: warn\n", policyFail},
+	}
+	for _, c := range cases {
+		policy, err := ParsePolicy([]byte("rules:\n"+c.rules), "yaml")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := policy.Evaluate(policyTestResult(100)); got.Status != c.want || len(got.Rules) != len(policy.Rules) {
+			t.Errorf("%s: status = %s with %d outcomes, want %s", c.name, got.Status, len(got.Rules), c.want)
+		}
+	}
+}
+
+func TestPolicyThemeRuleListsMatches(t *testing.T) {
+	policy, err := ParsePolicy([]byte("rules:\n  - when: new_negative_theme_share > 10\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcome := policy.Evaluate(policyTestResult(100)).Rules[0]
+	if outcome.Status != policyFail || strings.Join(outcome.Matches, ";") != "Login loop (12)" {
+		t.Fatalf("outcome = %+v, want only the negative new theme", outcome)
+	}
+}
+
+func TestProportionMargin(t *testing.T) {
+	pre := SentimentSummary{Negative: 20, Positive: 80}
+	post := SentimentSummary{Negative: 30, Positive: 70}
+	margin, ok := proportionMargin(pre, post, func(s SentimentSummary) int { return s.Negative })
+	want := 1.96 * math.Sqrt(0.2*0.8/100+0.3*0.7/100) * 100
+	if !ok || math.Abs(margin-want) > 1e-9 {
+		t.Fatalf("margin = %g (%v), want %g", margin, ok, want)
+	}
+	if _, ok := proportionMargin(SentimentSummary{}, post, func(s SentimentSummary) int { return s.Negative }); ok {
+		t.Fatal("an empty phase has a margin")
+	}
+}
+
+func TestMeanDifferenceMargin(t *testing.T) {
+	rated := func(ratings ...int) []Review {
+		var reviews []Review
+		for _, r := range ratings {
+			reviews = append(reviews, Review{Rating: r})
+		}
+		return reviews
+	}
+	// Both phases have variance 1 over 3 ratings; the unrated review is skipped
+	margin, ok := meanDifferenceMargin(rated(3, 4, 5, 0), rated(1, 2, 3))
+	if want := 1.96 * math.Sqrt(1.0/3+1.0/3); !ok || math.Abs(margin-want) > 1e-9 {
+		t.Fatalf("margin = %g (%v), want %g", margin, ok, want)
+	}
+	if _, ok := meanDifferenceMargin(rated(5), rated(1, 2)); ok {
+		t.Fatal("a single rating has a margin")
+	}
+}
+
+func TestSignificantRules(t *testing.T) {
+	sampled := func(r *AnalysisResult) *AnalysisResult {
+		r.Metadata.Sampling = &SamplingReport{SentimentShiftMargin: 15, NegativeRateMargin: 15}
+		return r
+	}
+	cases := []struct {
+		name   string
+		when   string
+		result *AnalysisResult
+		want   string
+	}{
+		{"shift within the margin of 100 reviews", "sentiment_shift < -5", policyTestResult(100), policyPass},
+		{"shift beyond the margin of 2000 reviews", "sentiment_shift < -5", policyTestResult(2000), policyFail},
+		{"shift within a sampled run's margin", "sentiment_shift < -5", sampled(policyTestResult(2000)), policyPass},
+		{"negative rate within the margin of 100 reviews", "negative_rate_change > 5", policyTestResult(100), policyPass},
+		{"negative rate beyond the margin of 2000 reviews", "negative_rate_change > 5", policyTestResult(2000), policyFail},
+		{"negative rate within a sampled run's margin", "negative_rate_change > 5", sampled(policyTestResult(2000)), policyPass},
+		{"no data to test", "negative_rate_change > -5", &AnalysisResult{}, policyPass},
+	}
+	for _, c := range cases {
+		policy, err := ParsePolicy([]byte("rules:\n  - when: "+c.when+"\n    significant: true\n"), "yaml")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if outcome := policy.Evaluate(c.result).Rules[0]; outcome.Status != c.want {
+			t.Errorf("%s: %s (%s), want %s", c.name, outcome.Status, outcome.Reason, c.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go:embed prompts/*.tmpl
+var defaultPromptFS embed.FS
+
+// Prompt template names used by GroqClient
+const (
+	promptSentiment = "sentiment"
+	promptThemes    = "themes"
+	promptImpact    = "impact"
+	promptAspects   = "aspects"
+	promptClusters  = "clusters"
+)
+
+// requiredPromptVariables lists the variables each prompt must declare, so an
+// edited template cannot silently drop the data the analysis depends on
+var requiredPromptVariables = map[string][]string{
+	promptSentiment: {"Reviews"},
+	promptThemes:    {"PreReviews", "PostReviews", "Taxonomy", "TopN"},
+	promptAspects:   {"Reviews", "Themes"},
+	promptClusters:  {"Clusters", "Taxonomy"},
+	promptImpact: {
+		"PreCount", "PrePositive", "PreNegative", "PreNeutral", "PreAverage",
+		"PostCount", "PostPositive", "PostNegative", "PostNeutral", "PostAverage",
+		"SentimentShift", "Themes", "Quotes", "SuccessScore", "Verdict",
+	},
+}
+
+// PromptTemplate is a parsed, versioned prompt
+type PromptTemplate struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Variables []string `json:"variables"`
+	Source    string   `json:"source"` // file the template was loaded from
+	Body      string   `json:"body"`
+	tmpl      *template.Template
+}
+
+// Render executes the template, failing if any declared variable is missing
+func (p *PromptTemplate) Render(data map[string]interface{}) (string, error) {
+	for _, v := range p.Variables {
+		if _, ok := data[v]; !ok {
+			return "", fmt.Errorf("prompt %s (%s): missing variable %s", p.Name, p.Version, v)
+		}
+	}
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("prompt %s (%s): %w", p.Name, p.Version, err)
+	}
+	return buf.String(), nil
+}
+
+// PromptRegistry holds the active prompt templates and can reload them from disk
+type PromptRegistry struct {
+	mu        sync.RWMutex
+	dir       string
+	templates map[string]*PromptTemplate
+	loadedAt  time.Time
+}
+
+// NewPromptRegistry loads templates from dir, falling back to the built-in
+// templates for any that are absent; an empty dir uses only the built-ins
+func NewPromptRegistry(dir string) (*PromptRegistry, error) {
+	r := &PromptRegistry{dir: dir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads and validates all templates; on failure the current set is kept
+func (r *PromptRegistry) Reload() error {
+	templates, err := loadPromptTemplates(r.dir)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates = templates
+	r.loadedAt = time.Now()
+	return nil
+}
+
+// Get returns the named template
+func (r *PromptRegistry) Get(name string) (*PromptTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template: %s", name)
+	}
+	return t, nil
+}
+
+// List returns all templates sorted by name
+func (r *PromptRegistry) List() []*PromptTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]*PromptTemplate, 0, len(r.templates))
+	for _, t := range r.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Snapshot returns a registry frozen at the current templates, so a single
+// analysis is not affected by a reload happening halfway through
+func (r *PromptRegistry) Snapshot() *PromptRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &PromptRegistry{
+		dir:       r.dir,
+		templates: r.templates,
+		loadedAt:  r.loadedAt,
+	}
+}
+
+// Versions maps each template name to its declared version
+func (r *PromptRegistry) Versions() map[string]string {
+	versions := make(map[string]string)
+	for _, t := range r.List() {
+		versions[t.Name] = t.Version
+	}
+	return versions
+}
+
+// Fingerprint hashes the given templates' versions and bodies; with no names it covers all templates
+func (r *PromptRegistry) Fingerprint(names ...string) string {
+	var parts []string
+	for _, t := range r.List() {
+		if len(names) > 0 && !containsString(names, t.Name) {
+			continue
+		}
+		parts = append(parts, t.Name, t.Version, t.Body)
+	}
+	return hashPrompts(parts...)
+}
+
+// Watch polls the template directory and reloads when any file changes
+func (r *PromptRegistry) Watch(interval time.Duration) {
+	if r.dir == "" {
+		return
+	}
+	go func() {
+		last := promptDirModTime(r.dir)
+		for range time.Tick(interval) {
+			current := promptDirModTime(r.dir)
+			if !current.After(last) {
+				continue
+			}
+			last = current
+			if err := r.Reload(); err != nil {
+				log.Printf("⚠️ Prompt reload failed, keeping previous templates: %v", err)
+				continue
+			}
+			log.Printf("🔄 Reloaded prompt templates from %s", r.dir)
+		}
+	}()
+}
+
+// loadPromptTemplates reads the built-in templates, overlays any from dir and validates the result
+func loadPromptTemplates(dir string) (map[string]*PromptTemplate, error) {
+	templates := make(map[string]*PromptTemplate)
+
+	builtin, _ := fs.Glob(defaultPromptFS, "prompts/*.tmpl")
+	for _, path := range builtin {
+		data, err := defaultPromptFS.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read built-in prompt %s: %w", path, err)
+		}
+		t, err := parsePromptTemplate(path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		templates[t.Name] = t
+	}
+
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list prompt directory: %w", err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read prompt %s: %w", path, err)
+			}
+			t, err := parsePromptTemplate(path, string(data))
+			if err != nil {
+				return nil, err
+			}
+			templates[t.Name] = t
+		}
+	}
+
+	for name, required := range requiredPromptVariables {
+		t, ok := templates[name]
+		if !ok {
+			return nil, fmt.Errorf("prompt template %s is missing", name)
+		}
+		for _, v := range required {
+			if !containsString(t.Variables, v) {
+				return nil, fmt.Errorf("prompt %s (%s) must declare variable %s", name, t.Source, v)
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// parsePromptTemplate parses a template file of the form:
+//
+//	---
+//	version: sentiment-v2
+//	variables: Reviews
+//	---
+//	template body
+func parsePromptTemplate(path, content string) (*PromptTemplate, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		return nil, fmt.Errorf("prompt %s: missing front matter", path)
+	}
+	end := strings.Index(content[4:], "\n---\n")
+	if end < 0 {
+		return nil, fmt.Errorf("prompt %s: unterminated front matter", path)
+	}
+	header := content[4 : 4+end]
+	body := strings.TrimSpace(content[4+end+5:])
+
+	t := &PromptTemplate{Name: name, Source: path, Body: body}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "version":
+			t.Version = strings.TrimSpace(value)
+		case "variables":
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					t.Variables = append(t.Variables, v)
+				}
+			}
+		}
+	}
+	if t.Version == "" {
+		return nil, fmt.Errorf("prompt %s: version is required", path)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", path, err)
+	}
+	t.tmpl = tmpl
+
+	// Render with placeholder values to catch references to undeclared variables
+	sample := make(map[string]interface{}, len(t.Variables))
+	for _, v := range t.Variables {
+		sample[v] = 0
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil && strings.Contains(err.Error(), "map has no entry for key") {
+		return nil, fmt.Errorf("prompt %s uses an undeclared variable: %w", path, err)
+	}
+
+	return t, nil
+}
+
+// promptDirModTime returns the newest modification time among the directory's templates
+func promptDirModTime(dir string) time.Time {
+	var latest time.Time
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if info, err := os.Stat(dir); err == nil && info.ModTime().After(latest) {
+		latest = info.ModTime()
+	}
+	return latest
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
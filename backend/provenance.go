@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// GenerationInfo describes how an analyzer produces its output, so a run can be replayed
+type GenerationInfo struct {
+	Provider      string            `json:"provider"`
+	Model         string            `json:"model"`
+	Parameters    GenerationConfig  `json:"parameters"`
+	PromptVersion string            `json:"prompt_version"` // hash of the prompt templates
+	Prompts       map[string]string `json:"prompts"`        // template name -> declared version
+}
+
+// LLMCall records a single provider call made during an analysis
+type LLMCall struct {
+	Operation         string     `json:"operation"` // sentiment, themes or impact
+	ResponseID        string     `json:"response_id"`
+	Model             string     `json:"model"`
+	SystemFingerprint string     `json:"system_fingerprint,omitempty"`
+	Usage             TokenUsage `json:"usage"`
+	CostUSD           float64    `json:"cost_usd"`
+}
+
+// ReproducibleAnalyzer is implemented by analyzers that can describe their generation settings
+type ReproducibleAnalyzer interface {
+	Describe() GenerationInfo
+}
+
+// SessionAnalyzer is implemented by analyzers that can isolate the calls of a single analysis
+type SessionAnalyzer interface {
+	NewSession() LLMAnalyzer
+}
+
+// CallRecorder is implemented by analyzers that record their provider calls
+type CallRecorder interface {
+	Calls() []LLMCall
+}
+
+// callLog is a concurrency-safe list of provider calls
+type callLog struct {
+	mu    sync.Mutex
+	calls []LLMCall
+}
+
+func (l *callLog) add(call LLMCall) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+func (l *callLog) list() []LLMCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LLMCall(nil), l.calls...)
+}
+
+// hashPrompts returns a short stable hash identifying a set of prompt templates
+func hashPrompts(templates ...string) string {
+	h := sha256.New()
+	for _, t := range templates {
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed reports/*.tmpl
+var reportFS embed.FS
+
+// Report formats
+const (
+	reportHTML = "html"
+	reportPDF  = "pdf"
+)
+
+// Report content limits
+const (
+	maxReportQuotes      = 8 // representative quotes, at most one per theme
+	maxChartThemes       = 8
+	reportChartWidth     = 495.0
+	reportChartHeight    = 220.0
+	reportTitle          = "Launch Impact Report"
+	reportHTMLTemplate   = "reports/report.html.tmpl"
+	reportQuoteMaxLength = 280
+)
+
+// ReportView is the presentation model shared by the report renderers
+type ReportView struct {
+	Title            string
+	GeneratedAt      string
+	AnalyzedAt       string
+	DatasetID        string
+	Score            float64
+	Success          bool
+	Verdict          string
+	ExecutiveSummary string
+
+	PreCount         int
+	PostCount        int
+	ReviewDelta      int
+	PrePositiveRate  float64
+	PostPositiveRate float64
+	SentimentShift   float64
+	PreRating        float64
+	PostRating       float64
+	RatingDelta      float64
+
+	Themes          []ReportTheme
+	LongTailCount   int
+	Improvements    []ImpactClaim
+	Issues          []ImpactClaim
+	Recommendations []string
+	NewIssues       []EmergingIssue
+	Quotes          []ReportQuote
+	Methodology     []string
+
+	SentimentChart barChart
+	ThemeChart     barChart
+}
+
+// ReportTheme is one row of the theme delta table
+type ReportTheme struct {
+	Name        string
+	Parent      string
+	PreCount    int
+	PostCount   int
+	Delta       int
+	ChangeRate  float64
+	Highlighted bool
+	Proposed    bool
+}
+
+// ReportQuote is a representative review quote
+type ReportQuote struct {
+	Theme    string
+	Phase    string
+	ReviewID string
+	Quote    string
+}
+
+// buildReportView derives everything a report shows from an analysis result
+func buildReportView(result *AnalysisResult) ReportView {
+	comparison := result.Comparison
+	view := ReportView{
+		Title:            reportTitle,
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		AnalyzedAt:       result.AnalyzedAt,
+		DatasetID:        result.Metadata.DatasetID,
+		Score:            result.Impact.SuccessScore,
+		Success:          result.Impact.OverallSuccess,
+		Verdict:          "Needs improvement",
+		ExecutiveSummary: result.Impact.ExecutiveSummary,
+		PreCount:         sentimentTotal(comparison.PreLaunchSentiment),
+		PostCount:        sentimentTotal(comparison.PostLaunchSentiment),
+		PrePositiveRate:  positiveRate(comparison.PreLaunchSentiment),
+		PostPositiveRate: positiveRate(comparison.PostLaunchSentiment),
+		SentimentShift:   round2(comparison.SentimentShift),
+		PreRating:        round2(comparison.PreLaunchSentiment.Average),
+		PostRating:       round2(comparison.PostLaunchSentiment.Average),
+		RatingDelta:      round2(comparison.PostLaunchSentiment.Average - comparison.PreLaunchSentiment.Average),
+		Improvements:     result.Impact.KeyImprovements,
+		Issues:           result.Impact.CriticalIssues,
+		Recommendations:  result.Impact.Recommendations,
+		NewIssues:        result.NewIssues,
+		Quotes:           reportQuotes(comparison.Themes),
+		Methodology:      methodologyNotes(result),
+		SentimentChart:   sentimentChart(comparison),
+		ThemeChart:       themeChart(comparison, maxChartThemes),
+	}
+	view.ReviewDelta = view.PostCount - view.PreCount
+	if view.Success {
+		view.Verdict = "Successful launch"
+	}
+	for _, t := range flattenThemes(comparison.Themes) {
+		view.Themes = append(view.Themes, ReportTheme{
+			Name:        t.Theme,
+			Parent:      t.Parent,
+			PreCount:    t.PreCount,
+			PostCount:   t.PostCount,
+			Delta:       t.PostCount - t.PreCount,
+			ChangeRate:  t.ChangeRate,
+			Highlighted: t.Highlighted,
+			Proposed:    t.Proposed,
+		})
+	}
+	if comparison.LongTail != nil {
+		view.LongTailCount = len(comparison.LongTail.Themes)
+	}
+	return view
+}
+
+// positiveRate is the percentage of reviews classified positive
+func positiveRate(s SentimentSummary) float64 {
+	total := sentimentTotal(s)
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(s.Positive) / float64(total) * 100)
+}
+
+// reportQuotes picks one verified quote per theme, most changed themes first,
+// preferring post-launch quotes since they describe the launched feature
+func reportQuotes(themes []ThemeResult) []ReportQuote {
+	ordered := append([]ThemeResult{}, themes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return math.Abs(float64(ordered[i].PostCount-ordered[i].PreCount)) > math.Abs(float64(ordered[j].PostCount-ordered[j].PreCount))
+	})
+
+	quotes := []ReportQuote{}
+	seen := make(map[string]bool)
+	for _, t := range ordered {
+		if len(quotes) >= maxReportQuotes {
+			break
+		}
+		evidence := append(postLaunchEvidence(t.Evidence), t.Evidence...)
+		for _, e := range evidence {
+			if e.Quote == "" || seen[e.Quote] {
+				continue
+			}
+			seen[e.Quote] = true
+			quotes = append(quotes, ReportQuote{Theme: t.Theme, Phase: e.Phase, ReviewID: e.ReviewID, Quote: shorten(e.Quote, reportQuoteMaxLength)})
+			break
+		}
+	}
+	return quotes
+}
+
+// shorten trims text to at most n characters at a word boundary
+func shorten(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > n/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// methodologyNotes explains how the numbers in the report were produced
+func methodologyNotes(result *AnalysisResult) []string {
+	meta := result.Metadata
+	var notes []string
+
+	if g := meta.Generation; g != nil {
+		notes = append(notes, fmt.Sprintf("Reviews were classified by %s (%s) using prompt set %s.", g.Model, g.Provider, g.PromptVersion))
+	}
+	if s := meta.Sampling; s != nil && (s.PreLaunch != nil || s.PostLaunch != nil) {
+		for _, phase := range []struct {
+			name   string
+			sample *PhaseSample
+		}{{"pre-launch", s.PreLaunch}, {"post-launch", s.PostLaunch}} {
+			if phase.sample != nil {
+				notes = append(notes, fmt.Sprintf("%d of %d %s reviews were analysed as a stratified sample (seed %d); counts are extrapolated to the full set.",
+					phase.sample.SampleSize, phase.sample.PopulationSize, phase.name, s.Seed))
+			}
+		}
+		notes = append(notes, fmt.Sprintf("The sentiment shift is accurate to ±%.1f percentage points at %.0f%% confidence.", s.SentimentShiftMargin, s.Confidence*100))
+	}
+	if c := meta.Clustering; c != nil {
+		notes = append(notes, fmt.Sprintf("Themes were discovered by clustering %s embeddings into %d groups; %d reviews did not fit any theme.", c.Embedder, c.K, c.Unclustered))
+	} else {
+		notes = append(notes, "Themes were extracted by the language model and mapped onto the approved theme taxonomy.")
+	}
+	notes = append(notes, fmt.Sprintf("The success score is computed from the data, not by the model: %s. Scores of %d or more count as a successful launch.", scoreFormula, successThreshold))
+	if result.Comparison.LongTail != nil {
+		notes = append(notes, fmt.Sprintf("%d smaller themes are grouped into a long tail and left out of the theme table.", len(result.Comparison.LongTail.Themes)))
+	}
+	if meta.Overrides > 0 {
+		notes = append(notes, fmt.Sprintf("%d review labels were corrected by reviewers and take precedence over the model.", meta.Overrides))
+	}
+	if meta.DroppedQuotes > 0 {
+		notes = append(notes, fmt.Sprintf("%d quotes were discarded because they did not appear in the cited review.", meta.DroppedQuotes))
+	}
+	if n := len(result.Impact.UnverifiedNumbers); n > 0 {
+		notes = append(notes, fmt.Sprintf("%d numbers in the executive summary could not be matched to the data; treat them with caution.", n))
+	}
+	return notes
+}
+
+// reportFuncs are the helpers available to report templates
+var reportFuncs = template.FuncMap{
+	"signed":     signedInt,
+	"phaseLabel": phaseLabel,
+}
+
+// signedInt prints a whole number with an explicit sign
+func signedInt(n int) string {
+	return fmt.Sprintf("%+d", n)
+}
+
+// phaseLabel names a review phase for readers
+func phaseLabel(phase string) string {
+	if phase == "pre_launch" {
+		return "Pre-launch"
+	}
+	return "Post-launch"
+}
+
+// RenderHTMLReport writes a self-contained HTML report: styles and charts are
+// inline, so the file can be emailed or archived as is
+func RenderHTMLReport(result *AnalysisResult, w io.Writer) error {
+	view := buildReportView(result)
+	tmpl, err := template.New("report.html.tmpl").Funcs(reportFuncs).Funcs(template.FuncMap{
+		"sentimentChart": func() template.HTML { return view.SentimentChart.SVG(reportChartWidth, reportChartHeight) },
+		"themeChart":     func() template.HTML { return view.ThemeChart.SVG(reportChartWidth, reportChartHeight) },
+	}).ParseFS(reportFS, reportHTMLTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse report template: %w", err)
+	}
+	return tmpl.Execute(w, view)
+}
+
+// RenderPDFReport lays out the same report as RenderHTMLReport as an A4 PDF
+func RenderPDFReport(result *AnalysisResult, w io.Writer) error {
+	view := buildReportView(result)
+	doc := newPDFDocument(view.Title)
+	l := newPDFLayout(doc)
+
+	l.textLine(view.Title, fontBold, 22, colorText)
+	l.textLine(fmt.Sprintf("Dataset %s · analysed %s", view.DatasetID, view.AnalyzedAt), fontRegular, 9, colorNeutral)
+	l.space(10)
+
+	verdictColor := colorNegative
+	if view.Success {
+		verdictColor = colorPositive
+	}
+	l.textLine(fmt.Sprintf("Success score %.0f / 100 - %s", view.Score, view.Verdict), fontBold, 14, verdictColor)
+	l.space(4)
+	l.paragraph(view.ExecutiveSummary, fontRegular, 10.5)
+
+	l.heading("Headline metrics")
+	l.table([]float64{200, 100, 100, 95}, []string{"Metric", "Pre-launch", "Post-launch", "Change"}, [][]string{
+		{"Reviews", fmt.Sprint(view.PreCount), fmt.Sprint(view.PostCount), signedInt(view.ReviewDelta)},
+		{"Positive reviews", fmt.Sprintf("%.1f%%", view.PrePositiveRate), fmt.Sprintf("%.1f%%", view.PostPositiveRate), fmt.Sprintf("%+.1f pts", view.SentimentShift)},
+		{"Average rating", fmt.Sprintf("%.2f", view.PreRating), fmt.Sprintf("%.2f", view.PostRating), fmt.Sprintf("%+.2f", view.RatingDelta)},
+	})
+
+	l.heading("Sentiment")
+	l.chart(view.SentimentChart)
+	if len(view.ThemeChart.Categories) > 0 {
+		l.chart(view.ThemeChart)
+	}
+
+	l.heading("Themes")
+	rows := make([][]string, 0, len(view.Themes))
+	for _, t := range view.Themes {
+		name := t.Name
+		if t.Parent != "" {
+			name = "    " + name
+		}
+		if t.Proposed {
+			name += " (proposed)"
+		}
+		rows = append(rows, []string{name, fmt.Sprint(t.PreCount), fmt.Sprint(t.PostCount), signedInt(t.Delta), fmt.Sprintf("%+.0f%%", t.ChangeRate)})
+	}
+	l.table([]float64{215, 70, 70, 70, 70}, []string{"Theme", "Pre", "Post", "Delta", "Change"}, rows)
+	if view.LongTailCount > 0 {
+		l.paragraph(fmt.Sprintf("%d further themes are in the long tail.", view.LongTailCount), fontItalic, 9)
+	}
+
+	l.claims("Key improvements", view.Improvements)
+	l.claims("Critical issues", view.Issues)
+	if len(view.NewIssues) > 0 {
+		l.heading("New issues since launch")
+		for _, issue := range view.NewIssues {
+			l.bullet(fmt.Sprintf("%s (%s, severity %.0f): %s", issue.Theme, issue.Level, issue.Severity, strings.Join(issue.Reasons, "; ")))
+		}
+	}
+	if len(view.Recommendations) > 0 {
+		l.heading("Recommendations")
+		for _, r := range view.Recommendations {
+			l.bullet(r)
+		}
+	}
+
+	if len(view.Quotes) > 0 {
+		l.heading("What customers said")
+		for _, q := range view.Quotes {
+			l.paragraph("“"+q.Quote+"”", fontItalic, 10)
+			l.textLine(fmt.Sprintf("%s · %s review %s", q.Theme, phaseLabel(q.Phase), q.ReviewID), fontRegular, 8.5, colorNeutral)
+			l.space(6)
+		}
+	}
+
+	l.heading("Methodology")
+	for _, note := range view.Methodology {
+		l.bullet(note)
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// pdfLayout flows report content down the pages of a document
+type pdfLayout struct {
+	doc  *pdfDocument
+	page *pdfPage
+	y    float64 // baseline of the next line
+}
+
+// PDF page margins and text leading
+const (
+	pdfMargin  = 50.0
+	pdfLeading = 1.35
+)
+
+func newPDFLayout(doc *pdfDocument) *pdfLayout {
+	l := &pdfLayout{doc: doc}
+	l.newPage()
+	return l
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = l.doc.addPage()
+	l.y = pdfPageHeight - pdfMargin
+}
+
+// ensure starts a new page unless height points fit above the bottom margin
+func (l *pdfLayout) ensure(height float64) {
+	if l.y-height < pdfMargin {
+		l.newPage()
+	}
+}
+
+func (l *pdfLayout) space(points float64) {
+	l.y -= points
+}
+
+func (l *pdfLayout) textLine(s, font string, size float64, color string) {
+	l.ensure(size * pdfLeading)
+	l.y -= size
+	l.page.text(pdfMargin, l.y, font, size, color, s)
+	l.y -= size * (pdfLeading - 1)
+}
+
+func (l *pdfLayout) heading(s string) {
+	l.ensure(60) // keep a heading with the start of its section
+	l.space(14)
+	l.textLine(s, fontBold, 14, colorText)
+	l.page.line(pdfMargin, l.y, pdfPageWidth-pdfMargin, l.y, 0.5, colorGrid)
+	l.space(8)
+}
+
+func (l *pdfLayout) paragraph(s, font string, size float64) {
+	for _, line := range wrapText(s, size, pdfPageWidth-2*pdfMargin, font == fontBold) {
+		l.textLine(line, font, size, colorText)
+	}
+}
+
+func (l *pdfLayout) bullet(s string) {
+	const indent = 12.0
+	size := 10.0
+	for i, line := range wrapText(s, size, pdfPageWidth-2*pdfMargin-indent, false) {
+		l.ensure(size * pdfLeading)
+		l.y -= size
+		if i == 0 {
+			l.page.text(pdfMargin, l.y, fontRegular, size, colorPost, "•")
+		}
+		l.page.text(pdfMargin+indent, l.y, fontRegular, size, colorText, line)
+		l.y -= size * (pdfLeading - 1)
+	}
+	l.space(3)
+}
+
+func (l *pdfLayout) claims(title string, claims []ImpactClaim) {
+	if len(claims) == 0 {
+		return
+	}
+	l.heading(title)
+	for _, c := range claims {
+		l.bullet(c.Text)
+	}
+}
+
+func (l *pdfLayout) chart(c barChart) {
+	l.ensure(reportChartHeight + 10)
+	l.page.chart(c.layout(reportChartWidth, reportChartHeight), pdfMargin, l.y)
+	l.y -= reportChartHeight + 10
+}
+
+// table draws a header row and body rows; columns after the first are right-aligned
+func (l *pdfLayout) table(widths []float64, header []string, rows [][]string) {
+	const size = 9.5
+	row := func(cells []string, font string) {
+		l.ensure(size * 1.8)
+		l.y -= size * 1.3
+		x := pdfMargin
+		for i, cell := range cells {
+			cell = truncateToWidth(cell, size, widths[i]-6)
+			tx := x
+			if i > 0 {
+				tx = x + widths[i] - textWidth(cell, size, font == fontBold)
+			}
+			l.page.text(tx, l.y, font, size, colorText, cell)
+			x += widths[i]
+		}
+		l.y -= size * 0.5
+		l.page.line(pdfMargin, l.y, pdfMargin+sum(widths), l.y, 0.3, colorGrid)
+	}
+	row(header, fontBold)
+	for _, cells := range rows {
+		row(cells, fontRegular)
+	}
+	l.space(6)
+}
+
+// sum adds up a slice of numbers
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// REST pagination styles
+const (
+	paginateNone   = "none"   // a single request
+	paginatePage   = "page"   // page_param=1, 2, ... until a short or empty page
+	paginateOffset = "offset" // page_param=0, n, 2n, ... until a short or empty page
+	paginateCursor = "cursor" // page_param=<value at next_path> until it is empty
+	paginateLink   = "link"   // follow the URL at next_path, or the Link header's rel="next"
+)
+
+// RESTConfig maps any JSON API that lists reviews. Paths are dot-separated
+// keys and array indexes into the response, e.g. "data.items" or "author.id"
+type RESTConfig struct {
+	URL         string            `json:"url"` // first page; may reference allowed ${ENV}
+	Headers     map[string]string `json:"headers,omitempty"`
+	ItemsPath   string            `json:"items_path,omitempty"` // the array of items; "" when the body is the array
+	Fields      map[string]string `json:"fields"`               // Review field (id, date, user_id, review_text, rating, source) -> item path
+	Pagination  string            `json:"pagination,omitempty"` // none (default), page, offset, cursor or link
+	PageParam   string            `json:"page_param,omitempty"` // query parameter carrying the page, offset or cursor
+	PageSize    int               `json:"page_size,omitempty"`  // sent as size_param; a shorter page ends page and offset pagination
+	SizeParam   string            `json:"size_param,omitempty"`
+	NextPath    string            `json:"next_path,omitempty"`    // response path of the next cursor or same-origin URL
+	SinceParam  string            `json:"since_param,omitempty"`  // query parameter given the sync cursor, for incremental syncs
+	CursorField string            `json:"cursor_field,omitempty"` // item path whose highest value becomes the cursor, e.g. "updated_at"
+}
+
+// restReviewFields are the Review fields a REST mapping may set
+var restReviewFields = []string{"id", "date", "user_id", "review_text", "rating", "source"}
+
+// restConnector pages through a JSON API and maps items with RESTConfig.Fields
+type restConnector struct {
+	config ConnectorConfig
+	rest   RESTConfig
+	client *http.Client
+}
+
+func newRESTConnector(config ConnectorConfig, client *http.Client) (*restConnector, error) {
+	rest := *config.REST
+	if rest.URL == "" {
+		return nil, fmt.Errorf("rest needs a url")
+	}
+	if rest.Pagination == "" {
+		rest.Pagination = paginateNone
+	}
+	for field := range rest.Fields {
+		if !containsString(restReviewFields, field) {
+			return nil, fmt.Errorf("unknown rest field %q; expected one of %s", field, strings.Join(restReviewFields, ", "))
+		}
+	}
+	if rest.Fields["id"] == "" || rest.Fields["review_text"] == "" {
+		return nil, fmt.Errorf("rest fields must map id and review_text")
+	}
+	switch rest.Pagination {
+	case paginateNone, paginateLink:
+	case paginatePage, paginateOffset:
+		if rest.PageParam == "" {
+			return nil, fmt.Errorf("%s pagination needs a page_param", rest.Pagination)
+		}
+		if rest.Pagination == paginateOffset && rest.PageSize < 1 {
+			return nil, fmt.Errorf("offset pagination needs a page_size")
+		}
+	case paginateCursor:
+		if rest.PageParam == "" || rest.NextPath == "" {
+			return nil, fmt.Errorf("cursor pagination needs a page_param and next_path")
+		}
+	default:
+		return nil, fmt.Errorf("pagination must be %s, %s, %s, %s or %s", paginateNone, paginatePage, paginateOffset, paginateCursor, paginateLink)
+	}
+	if rest.SinceParam != "" && rest.CursorField == "" {
+		return nil, fmt.Errorf("since_param needs a cursor_field to advance the cursor")
+	}
+	return &restConnector{config: config, rest: rest, client: client}, nil
+}
+
+// Sync reads every page, passing the cursor as since_param when configured
+func (c *restConnector) Sync(cursor string) ([]Review, string, error) {
+	first, err := url.Parse(expandEnv(c.rest.URL))
+	if err != nil {
+		return nil, cursor, fmt.Errorf("invalid rest url: %w", err)
+	}
+	query := first.Query()
+	if c.rest.SinceParam != "" {
+		since := cursor
+		if since == "" {
+			since = c.config.Since
+		}
+		if since != "" {
+			query.Set(c.rest.SinceParam, since)
+		}
+	}
+	if c.rest.PageSize > 0 && c.rest.SizeParam != "" {
+		query.Set(c.rest.SizeParam, strconv.Itoa(c.rest.PageSize))
+	}
+
+	latest := cursor
+	var reviews []Review
+	next := first
+	for page := 0; page < connectorMaxPages && next != nil; page++ {
+		switch c.rest.Pagination {
+		case paginatePage:
+			query.Set(c.rest.PageParam, strconv.Itoa(page+1))
+		case paginateOffset:
+			query.Set(c.rest.PageParam, strconv.Itoa(page*c.rest.PageSize))
+		}
+		if page == 0 || c.rest.Pagination != paginateLink {
+			next.RawQuery = query.Encode()
+		}
+
+		req, err := http.NewRequest(http.MethodGet, next.String(), nil)
+		if err != nil {
+			return nil, cursor, err
+		}
+		req.Header.Set("Accept", "application/json")
+		for key, value := range c.rest.Headers {
+			req.Header.Set(key, expandEnv(value))
+		}
+		var body interface{}
+		header, err := doConnectorRequest(c.client, req, &body)
+		if err != nil {
+			return nil, cursor, err
+		}
+
+		raw := lookupPath(body, c.rest.ItemsPath)
+		items, ok := raw.([]interface{})
+		if !ok && raw != nil {
+			return nil, cursor, fmt.Errorf("rest items_path %q is not an array", c.rest.ItemsPath)
+		}
+		for _, item := range items {
+			review, ok := c.review(item)
+			if !ok {
+				continue
+			}
+			reviews = append(reviews, review)
+			if c.rest.CursorField != "" {
+				if value := valueString(lookupPath(item, c.rest.CursorField)); value != "" && cursorAfter(value, latest) {
+					latest = value
+				}
+			}
+		}
+
+		current := next
+		next = nil
+		switch c.rest.Pagination {
+		case paginatePage, paginateOffset:
+			if len(items) > 0 && (c.rest.PageSize == 0 || len(items) >= c.rest.PageSize) {
+				next = current
+			}
+		case paginateCursor:
+			if token := valueString(lookupPath(body, c.rest.NextPath)); token != "" && len(items) > 0 {
+				query.Set(c.rest.PageParam, token)
+				next = current
+			}
+		case paginateLink:
+			link := nextLink(header.Get("Link"))
+			if c.rest.NextPath != "" {
+				link = valueString(lookupPath(body, c.rest.NextPath))
+			}
+			if link != "" {
+				if next, err = followLink(current, link); err != nil {
+					return nil, cursor, err
+				}
+			}
+		}
+	}
+	return reviews, latest, nil
+}
+
+// review maps one item; items without an ID or text are skipped
+func (c *restConnector) review(item interface{}) (Review, bool) {
+	field := func(name string) string {
+		if path := c.rest.Fields[name]; path != "" {
+			return valueString(lookupPath(item, path))
+		}
+		return ""
+	}
+	review := Review{
+		ID:         field("id"),
+		Date:       normalizeDate(field("date")),
+		UserID:     field("user_id"),
+		ReviewText: plainText(field("review_text")),
+		Source:     field("source"),
+	}
+	if review.ID == "" || review.ReviewText == "" {
+		return review, false
+	}
+	review.ID = c.config.Name + "-" + review.ID
+	if review.Source == "" {
+		review.Source = c.config.source()
+	}
+	if rating, err := strconv.ParseFloat(field("rating"), 64); err == nil {
+		review.Rating = int(math.Round(rating))
+	}
+	return review, true
+}
+
+// lookupPath follows a dot-separated path of keys and array indexes
+func lookupPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// valueString renders a JSON scalar as text
+func valueString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// cursorAfter compares cursor values numerically when both are numbers,
+// otherwise as text, which orders ISO 8601 timestamps correctly
+func cursorAfter(value, current string) bool {
+	if current == "" {
+		return true
+	}
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(current, 64)
+	if errA == nil && errB == nil {
+		return a > b
+	}
+	return value > current
+}
+
+// linkNext matches the rel="next" entry of a Link header
+var linkNext = regexp.MustCompile(`<([^>]+)>\s*;[^,]*rel="?next"?`)
+
+// nextLink returns the rel="next" URL of a Link header
+func nextLink(header string) string {
+	if m := linkNext.FindStringSubmatch(header); m != nil {
+		return m[1]
+	}
+	return ""
+}
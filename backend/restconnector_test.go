@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// restItems is a page of three reviews in the nested shape the tests map
+const restItems = `[
+	{"id":%d,"text":"first","stars":3.6,"updated":"2024-02-01T00:00:00Z","who":{"id":"u1"}},
+	{"id":%d,"text":"<p>second</p>","updated":"2024-02-04T00:00:00Z"}
+]`
+
+// restFields maps the items above
+var restFields = map[string]string{"id": "id", "review_text": "text", "rating": "stars", "date": "updated", "user_id": "who.id"}
+
+func newTestRESTConnector(t *testing.T, rest RESTConfig, srv *httptest.Server) Connector {
+	t.Helper()
+	if rest.Fields == nil {
+		rest.Fields = restFields
+	}
+	connector, err := newConnector(ConnectorConfig{Name: "feedback", Type: connectorREST, REST: &rest}, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+	return connector
+}
+
+func TestRESTConnectorPagePagination(t *testing.T) {
+	var limited int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "rest-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if atomic.AddInt32(&limited, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("limit") != "2" || query.Get("since") != "2024-01-15T00:00:00Z" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		switch query.Get("page") {
+		case "1":
+			fmt.Fprintf(w, `{"data":{"items":`+restItems+`}}`, 1, 2)
+		case "2":
+			fmt.Fprint(w, `{"data":{"items":[{"id":3,"text":"third","updated":"2024-02-02T00:00:00Z"}]}}`)
+		default:
+			t.Errorf("read past the short page: page %s", query.Get("page"))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("JOB_SECRET_REST_KEY", "rest-key")
+
+	connector := newTestRESTConnector(t, RESTConfig{
+		URL:         srv.URL + "/reviews",
+		Headers:     map[string]string{"X-Api-Key": "${JOB_SECRET_REST_KEY}"},
+		ItemsPath:   "data.items",
+		Pagination:  paginatePage,
+		PageParam:   "page",
+		PageSize:    2,
+		SizeParam:   "limit",
+		SinceParam:  "since",
+		CursorField: "updated",
+	}, srv)
+
+	reviews, cursor, err := connector.Sync("2024-01-15T00:00:00Z")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if cursor != "2024-02-04T00:00:00Z" {
+		t.Fatalf("cursor = %q, want the highest cursor_field", cursor)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "feedback-1,feedback-2,feedback-3" {
+		t.Fatalf("reviews = %s", got)
+	}
+	want := Review{ID: "feedback-1", Date: "2024-02-01", UserID: "u1", ReviewText: "first", Rating: 4, Source: "feedback"}
+	if reviews[0] != want {
+		t.Fatalf("review = %+v, want %+v", reviews[0], want)
+	}
+	if reviews[1].Rating != 0 || reviews[1].ReviewText != "second" {
+		t.Fatalf("unrated review = %+v", reviews[1])
+	}
+}
+
+func TestRESTConnectorCursorPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprintf(w, `{"items":`+restItems+`,"meta":{"next":"abc"}}`, 1, 2)
+		case "abc":
+			fmt.Fprintf(w, `{"items":`+restItems+`,"meta":{"next":null}}`, 3, 4)
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("after"))
+		}
+	}))
+	defer srv.Close()
+
+	connector := newTestRESTConnector(t, RESTConfig{URL: srv.URL, ItemsPath: "items", Pagination: paginateCursor, PageParam: "after", NextPath: "meta.next"}, srv)
+	reviews, _, err := connector.Sync("")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "feedback-1,feedback-2,feedback-3,feedback-4" {
+		t.Fatalf("reviews = %s", got)
+	}
+}
+
+func TestRESTConnectorLinkPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reviews":
+			w.Header().Set("Link", `</reviews/2?x=1>; rel="next", </reviews>; rel="first"`)
+			fmt.Fprintf(w, restItems, 1, 2)
+		case "/reviews/2":
+			fmt.Fprintf(w, restItems, 3, 4)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	connector := newTestRESTConnector(t, RESTConfig{URL: srv.URL + "/reviews", Pagination: paginateLink}, srv)
+	reviews, _, err := connector.Sync("")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(reviews) != 4 {
+		t.Fatalf("got %d reviews, want 4 across two pages", len(reviews))
+	}
+}
+
+func TestRESTConnectorRefusesCrossOriginLinks(t *testing.T) {
+	var leaked int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&leaked, 1)
+		fmt.Fprint(w, `[]`)
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"items":`+restItems+`,"next":"%s/collect"}`, 1, 2, other.URL)
+	}))
+	defer srv.Close()
+
+	connector := newTestRESTConnector(t, RESTConfig{URL: srv.URL, ItemsPath: "items", Pagination: paginateLink, NextPath: "next",
+		Headers: map[string]string{"Authorization": "Bearer secret"}}, srv)
+	if _, _, err := connector.Sync(""); err == nil || !strings.Contains(err.Error(), "leaves") {
+		t.Fatalf("got %v, want the cross-origin link refused", err)
+	}
+	if leaked != 0 {
+		t.Fatal("the credential headers were sent to another origin")
+	}
+}
+
+func TestRESTConnectorValidatesConfig(t *testing.T) {
+	cases := map[string]RESTConfig{
+		"no url":         {Fields: restFields},
+		"unknown field":  {URL: "https://example.com", Fields: map[string]string{"id": "id", "review_text": "t", "stars": "s"}},
+		"no id mapping":  {URL: "https://example.com", Fields: map[string]string{"review_text": "t"}},
+		"offset no size": {URL: "https://example.com", Fields: restFields, Pagination: paginateOffset, PageParam: "offset"},
+		"since no field": {URL: "https://example.com", Fields: restFields, SinceParam: "since"},
+		"bad pagination": {URL: "https://example.com", Fields: restFields, Pagination: "scroll"},
+	}
+	for name, rest := range cases {
+		rest := rest
+		if _, err := newConnector(ConnectorConfig{Name: "x", Type: connectorREST, REST: &rest}, nil); err == nil {
+			t.Errorf("%s: config accepted", name)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	body := map[string]interface{}{"data": []interface{}{map[string]interface{}{"id": "a"}}}
+	if got := lookupPath(body, "data.0.id"); got != "a" {
+		t.Fatalf("lookupPath = %v", got)
+	}
+	if got := lookupPath(body, "data.5.id"); got != nil {
+		t.Fatalf("lookupPath out of range = %v", got)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// z-score for the 95% confidence intervals reported with extrapolated counts
+const samplingZ95 = 1.96
+
+// SamplingConfig controls stratified sampling of large datasets
+type SamplingConfig struct {
+	Threshold  int    `json:"threshold"`   // phases with more reviews than this are sampled; 0 disables
+	SampleSize int    `json:"sample_size"` // reviews analyzed per sampled phase
+	DateBucket string `json:"date_bucket"` // "day", "week" or "month"
+	Seed       int64  `json:"seed"`
+}
+
+// LoadSamplingConfig reads sampling settings from the environment
+func LoadSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Threshold:  getEnvInt("SAMPLE_THRESHOLD", 0),
+		SampleSize: getEnvInt("SAMPLE_SIZE", 2000),
+		DateBucket: getEnv("SAMPLE_DATE_BUCKET", "week"),
+		Seed:       int64(getEnvInt("SAMPLE_SEED", 1)),
+	}
+}
+
+// Stratum describes one source/rating/date cell of a sampled population
+type Stratum struct {
+	Key        string `json:"key"`
+	Population int    `json:"population"`
+	Sampled    int    `json:"sampled"`
+}
+
+// ProportionEstimate is a population estimate with a 95% confidence interval
+type ProportionEstimate struct {
+	Proportion float64 `json:"proportion"`
+	Count      float64 `json:"count"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	variance   float64
+}
+
+// stratumLevels name the stratum keys from finest to coarsest. A sample too
+// small for every stratum to get two reviews uses a coarser level, since a
+// stratum with one sampled review has no variance estimate and one with none
+// would drop out of the estimate altogether
+var stratumLevels = []string{"source, rating, date", "source, rating", "source", "none"}
+
+// PhaseSample records the exact sample drawn from one phase and its extrapolated sentiment
+type PhaseSample struct {
+	PopulationSize int                           `json:"population_size"`
+	SampleSize     int                           `json:"sample_size"`
+	Stratification string                        `json:"stratification"` // the stratumLevels entry used
+	Strata         []Stratum                     `json:"strata"`
+	ReviewIDs      []string                      `json:"review_ids"`
+	Sentiment      map[string]ProportionEstimate `json:"sentiment"`
+
+	reviews   []Review
+	stratumOf map[string]int // review ID -> index into Strata
+}
+
+// SamplingReport is attached to results computed from a sample
+type SamplingReport struct {
+	Seed                 int64        `json:"seed"`
+	DateBucket           string       `json:"date_bucket"`
+	Confidence           float64      `json:"confidence"`
+	PreLaunch            *PhaseSample `json:"pre_launch,omitempty"`
+	PostLaunch           *PhaseSample `json:"post_launch,omitempty"`
+	SentimentShiftMargin float64      `json:"sentiment_shift_margin"` // ± percentage points
+	NegativeRateMargin   float64      `json:"negative_rate_margin"`   // ± percentage points
+}
+
+// maybeSample draws a stratified sample when the phase exceeds the threshold
+func maybeSample(reviews []Review, config SamplingConfig) *PhaseSample {
+	if config.Threshold <= 0 || len(reviews) <= config.Threshold || config.SampleSize <= 0 {
+		return nil
+	}
+	return drawStratifiedSample(reviews, config.SampleSize, config)
+}
+
+// drawStratifiedSample samples reviews proportionally across source, rating and
+// date strata, collapsing strata until each can get two reviews (or all of its
+// reviews, when it has fewer)
+func drawStratifiedSample(reviews []Review, size int, config SamplingConfig) *PhaseSample {
+	if size >= len(reviews) {
+		size = len(reviews)
+	}
+
+	var groups map[string][]int
+	var keys []string
+	level := 0
+	for ; level < len(stratumLevels); level++ {
+		groups, keys = groupStrata(reviews, config.DateBucket, level)
+		minimum := 0
+		for _, key := range keys {
+			minimum += min(2, len(groups[key]))
+		}
+		if minimum <= size || level == len(stratumLevels)-1 {
+			break
+		}
+	}
+
+	populations := make([]int, len(keys))
+	for i, key := range keys {
+		populations[i] = len(groups[key])
+	}
+	allocation := allocateSample(populations, size)
+
+	sample := &PhaseSample{
+		PopulationSize: len(reviews),
+		Stratification: stratumLevels[level],
+		stratumOf:      make(map[string]int),
+	}
+	var picked []int
+	for i, key := range keys {
+		members := groups[key]
+		n := allocation[i]
+		sample.Strata = append(sample.Strata, Stratum{Key: key, Population: len(members), Sampled: n})
+
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		rng := rand.New(rand.NewSource(config.Seed ^ int64(h.Sum64())))
+		for _, j := range rng.Perm(len(members))[:n] {
+			picked = append(picked, members[j])
+			sample.stratumOf[reviews[members[j]].ID] = i
+		}
+	}
+
+	// Keep the sample in the original review order
+	sort.Ints(picked)
+	for _, idx := range picked {
+		sample.reviews = append(sample.reviews, reviews[idx])
+		sample.ReviewIDs = append(sample.ReviewIDs, reviews[idx].ID)
+	}
+	sample.SampleSize = len(sample.reviews)
+
+	return sample
+}
+
+// groupStrata groups review indexes by their stratum key at a stratumLevels level
+func groupStrata(reviews []Review, bucket string, level int) (map[string][]int, []string) {
+	groups := make(map[string][]int)
+	var keys []string
+	for i, r := range reviews {
+		key := stratumKey(r, bucket, level)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	sort.Strings(keys)
+	return groups, keys
+}
+
+// allocateSample splits size across strata proportionally using largest
+// remainders, after giving each stratum two reviews (one when it has a single
+// review, or when the size only allows one per stratum)
+func allocateSample(populations []int, size int) []int {
+	allocation := make([]int, len(populations))
+	minimum := 0
+	for _, n := range populations {
+		minimum += min(2, n)
+	}
+	switch {
+	case minimum <= size:
+		for i, n := range populations {
+			allocation[i] = min(2, n)
+		}
+	case len(populations) <= size:
+		for i := range allocation {
+			allocation[i] = 1
+		}
+	}
+	remaining := size
+	for _, n := range allocation {
+		remaining -= n
+	}
+
+	capacity := 0
+	for i, n := range populations {
+		capacity += n - allocation[i]
+	}
+	if remaining > capacity {
+		remaining = capacity
+	}
+	if remaining <= 0 {
+		return allocation
+	}
+
+	type share struct {
+		index    int
+		fraction float64
+	}
+	shares := make([]share, len(populations))
+	given := 0
+	for i, n := range populations {
+		exact := float64(remaining) * float64(n-allocation[i]) / float64(capacity)
+		whole := int(exact)
+		allocation[i] += whole
+		given += whole
+		shares[i] = share{index: i, fraction: exact - float64(whole)}
+	}
+	sort.SliceStable(shares, func(a, b int) bool { return shares[a].fraction > shares[b].fraction })
+	for k := 0; given < remaining; k++ {
+		i := shares[k%len(shares)].index
+		if allocation[i] < populations[i] {
+			allocation[i]++
+			given++
+		}
+	}
+	return allocation
+}
+
+// extrapolate estimates population sentiment from the sample with the stratified estimator
+func (p *PhaseSample) extrapolate(sentiments []SentimentResult) {
+	labels := make(map[string]string, len(sentiments))
+	for _, s := range sentiments {
+		labels[s.ReviewID] = s.Sentiment
+	}
+
+	// counts[stratum][label]
+	counts := make([]map[string]int, len(p.Strata))
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for _, r := range p.reviews {
+		label := labels[r.ID]
+		if label != "positive" && label != "negative" {
+			label = "neutral"
+		}
+		counts[p.stratumOf[r.ID]][label]++
+	}
+
+	n := float64(p.PopulationSize)
+	p.Sentiment = make(map[string]ProportionEstimate)
+	for _, label := range sentimentClasses {
+		est := ProportionEstimate{}
+		for i, stratum := range p.Strata {
+			if stratum.Sampled == 0 {
+				continue
+			}
+			weight := float64(stratum.Population) / n
+			ph := float64(counts[i][label]) / float64(stratum.Sampled)
+			est.Proportion += weight * ph
+			if stratum.Sampled > 1 {
+				fpc := 1 - float64(stratum.Sampled)/float64(stratum.Population)
+				est.variance += weight * weight * fpc * ph * (1 - ph) / float64(stratum.Sampled-1)
+			}
+		}
+		margin := samplingZ95 * math.Sqrt(est.variance)
+		est.Count = est.Proportion * n
+		est.Low = math.Max(0, est.Proportion-margin) * n
+		est.High = math.Min(1, est.Proportion+margin) * n
+		p.Sentiment[label] = est
+	}
+}
+
+// summary converts the extrapolated estimates into a population-scale SentimentSummary
+func (p *PhaseSample) summary(population []Review) SentimentSummary {
+	summary := calculateSentimentSummary(nil, population)
+	summary.Positive = int(math.Round(p.Sentiment["positive"].Count))
+	summary.Negative = int(math.Round(p.Sentiment["negative"].Count))
+	summary.Neutral = p.PopulationSize - summary.Positive - summary.Negative
+	return summary
+}
+
+// scaleFactor is the ratio of population to sample size
+func (p *PhaseSample) scaleFactor() float64 {
+	if p == nil || p.SampleSize == 0 {
+		return 1
+	}
+	return float64(p.PopulationSize) / float64(p.SampleSize)
+}
+
+// scaleThemeCounts extrapolates theme mention and sentiment counts from the samples to the populations
+func scaleThemeCounts(themes []ThemeResult, pre, post *PhaseSample) {
+	preFactor, postFactor := pre.scaleFactor(), post.scaleFactor()
+	for i := range themes {
+		themes[i].PreCount = int(math.Round(float64(themes[i].PreCount) * preFactor))
+		themes[i].PostCount = int(math.Round(float64(themes[i].PostCount) * postFactor))
+		themes[i].PreSentiment.scale(preFactor)
+		themes[i].PostSentiment.scale(postFactor)
+		scaleThemeCounts(themes[i].SubThemes, pre, post)
+	}
+}
+
+// rateChangeMargin is the 95% margin, in percentage points, of the change in
+// the share of reviews with a sentiment between the sampled phases
+func rateChangeMargin(pre, post *PhaseSample, sentiment string) float64 {
+	variance := 0.0
+	for _, p := range []*PhaseSample{pre, post} {
+		if p != nil {
+			variance += p.Sentiment[sentiment].variance
+		}
+	}
+	return samplingZ95 * math.Sqrt(variance) * 100
+}
+
+// stratumKey buckets a review by source, rating and review date, dropping
+// the trailing parts for coarser stratumLevels
+func stratumKey(r Review, bucket string, level int) string {
+	switch level {
+	case 0:
+		return fmt.Sprintf("%s|%d|%s", r.Source, r.Rating, dateBucket(r.Date, bucket))
+	case 1:
+		return fmt.Sprintf("%s|%d", r.Source, r.Rating)
+	case 2:
+		return r.Source
+	}
+	return "all"
+}
+
+// dateBucket truncates a YYYY-MM-DD (or RFC3339) date to the configured granularity
+func dateBucket(date, bucket string) string {
+	if len(date) < 10 {
+		return "unknown"
+	}
+	t, err := time.Parse("2006-01-02", date[:10])
+	if err != nil {
+		return "unknown"
+	}
+	switch bucket {
+	case "day":
+		return t.Format("2006-01-02")
+	case "month":
+		return t.Format("2006-01")
+	default:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+}
+
+// reviewsOf returns the sampled reviews, or all reviews when the phase was not sampled
+func reviewsOf(sample *PhaseSample, all []Review) []Review {
+	if sample == nil {
+		return all
+	}
+	return sample.reviews
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// sampleReviews spreads n reviews over sources, ratings and days so most
+// fine-grained strata hold only a few reviews
+func sampleReviews(n int) []Review {
+	sources := []string{"app_store", "google_play", "support_ticket"}
+	reviews := make([]Review, n)
+	for i := range reviews {
+		reviews[i] = Review{
+			ID:     fmt.Sprintf("r%d", i),
+			Date:   fmt.Sprintf("2024-03-%02d", 1+i%28),
+			Rating: 1 + i%5,
+			Source: sources[i%len(sources)],
+		}
+	}
+	return reviews
+}
+
+func TestStratifiedSampleCoversEveryStratum(t *testing.T) {
+	reviews := sampleReviews(600)
+	config := SamplingConfig{SampleSize: 40, DateBucket: "day", Seed: 1}
+	sample := drawStratifiedSample(reviews, config.SampleSize, config)
+
+	if sample.SampleSize != 40 {
+		t.Fatalf("sample size = %d, want 40", sample.SampleSize)
+	}
+	if sample.Stratification == stratumLevels[0] {
+		t.Fatalf("kept %q strata although 40 reviews cannot cover them", sample.Stratification)
+	}
+	population := 0
+	for _, stratum := range sample.Strata {
+		population += stratum.Population
+		if stratum.Sampled < min(2, stratum.Population) {
+			t.Fatalf("stratum %+v got fewer than two reviews", stratum)
+		}
+	}
+	if population != len(reviews) {
+		t.Fatalf("strata cover %d of %d reviews", population, len(reviews))
+	}
+
+	// Label every sampled review, so the estimates must sum to the population
+	var sentiments []SentimentResult
+	for i, id := range sample.ReviewIDs {
+		sentiments = append(sentiments, SentimentResult{ReviewID: id, Sentiment: sentimentClasses[i%len(sentimentClasses)]})
+	}
+	sample.extrapolate(sentiments)
+	total := 0.0
+	for _, label := range sentimentClasses {
+		estimate := sample.Sentiment[label]
+		total += estimate.Proportion
+		if estimate.Low > estimate.Count || estimate.High < estimate.Count {
+			t.Fatalf("%s interval [%v, %v] excludes %v", label, estimate.Low, estimate.High, estimate.Count)
+		}
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Fatalf("proportions sum to %v, want 1", total)
+	}
+}
+
+func TestStratifiedSampleKeepsFineStrataWhenLargeEnough(t *testing.T) {
+	reviews := sampleReviews(300)
+	config := SamplingConfig{SampleSize: 250, DateBucket: "month", Seed: 1}
+	sample := drawStratifiedSample(reviews, config.SampleSize, config)
+	if sample.Stratification != stratumLevels[0] {
+		t.Fatalf("stratification = %q, want %q", sample.Stratification, stratumLevels[0])
+	}
+}
+
+func TestAllocateSample(t *testing.T) {
+	cases := []struct {
+		populations []int
+		size        int
+		want        []int
+	}{
+		{[]int{100, 1, 9}, 20, []int{16, 1, 3}},
+		{[]int{10, 10, 10}, 6, []int{2, 2, 2}},
+		{[]int{10, 10, 10}, 4, []int{2, 1, 1}},
+		{[]int{10, 10, 10}, 2, []int{1, 1, 0}},
+	}
+	for _, c := range cases {
+		got := allocateSample(c.populations, c.size)
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("allocateSample(%v, %d) = %v, want %v", c.populations, c.size, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// successThreshold is the minimum score for a launch to count as successful
+const successThreshold = 60
+
+// ScoreBreakdown shows how the success score was computed
+type ScoreBreakdown struct {
+	Base                float64 `json:"base"`
+	SentimentComponent  float64 `json:"sentiment_component"`
+	RatingComponent     float64 `json:"rating_component"`
+	NegativeThemeGrowth float64 `json:"negative_theme_growth"` // percentage points
+	NegativeComponent   float64 `json:"negative_component"`
+	Formula             string  `json:"formula"`
+}
+
+// NumberCheck is a number quoted in the narrative that did not match the data
+type NumberCheck struct {
+	Value   string `json:"value"`
+	Field   string `json:"field"` // which part of the summary it appeared in
+	Context string `json:"context"`
+}
+
+// scoreFormula documents computeSuccessScore for API consumers
+const scoreFormula = "clamp(50 + clamp(0.4*sentiment_shift, -20, 20) + clamp(10*rating_delta, -20, 20) - clamp(0.5*negative_theme_growth, -10, 10), 0, 100)"
+
+// computeSuccessScore derives a 0-100 launch score from the comparison alone:
+//
+//   - 50 is neutral (no measurable change)
+//   - sentiment: 0.4 points per percentage point of positive-rate shift, capped at ±20
+//   - rating: 10 points per star of average rating change, capped at ±20
+//   - negative themes: minus 0.5 points per percentage point growth in negative
+//     theme mentions per review, capped at ±10
+//
+// The result is clamped to [0, 100]; a launch succeeds at successThreshold or above.
+func computeSuccessScore(comparison ComparisonResult) (float64, ScoreBreakdown) {
+	pre, post := comparison.PreLaunchSentiment, comparison.PostLaunchSentiment
+
+	themes := allThemes(comparison)
+	growth := (negativeThemeRate(themes, post, false) - negativeThemeRate(themes, pre, true)) * 100
+	breakdown := ScoreBreakdown{
+		Base:                50,
+		SentimentComponent:  round2(clamp(0.4*comparison.SentimentShift, -20, 20)),
+		RatingComponent:     round2(clamp(10*(post.Average-pre.Average), -20, 20)),
+		NegativeThemeGrowth: round2(growth),
+		NegativeComponent:   round2(-clamp(0.5*growth, -10, 10)),
+		Formula:             scoreFormula,
+	}
+
+	score := breakdown.Base + breakdown.SentimentComponent + breakdown.RatingComponent + breakdown.NegativeComponent
+	return math.Round(clamp(score, 0, 100)*10) / 10, breakdown
+}
+
+// negativeThemeRate is the number of negative theme mentions per review in a phase
+func negativeThemeRate(themes []ThemeResult, summary SentimentSummary, pre bool) float64 {
+	total := summary.Positive + summary.Negative + summary.Neutral
+	if total == 0 {
+		return 0
+	}
+	negative := 0
+	for _, t := range themes {
+		if t.Parent != "" {
+			continue // already rolled up into the parent
+		}
+		if pre {
+			negative += t.PreSentiment.Negative
+		} else {
+			negative += t.PostSentiment.Negative
+		}
+	}
+	return float64(negative) / float64(total)
+}
+
+// applySuccessScore replaces any model-provided score with the computed one
+func applySuccessScore(impact *ImpactSummary, comparison ComparisonResult) {
+	score, breakdown := computeSuccessScore(comparison)
+	impact.SuccessScore = score
+	impact.OverallSuccess = score >= successThreshold
+	impact.ScoreBreakdown = &breakdown
+}
+
+// numberPattern matches integers and decimals not embedded in words
+var numberPattern = regexp.MustCompile(`(?:^|[^\w.])([-+]?\d+(?:\.\d+)?)`)
+
+// thousandsPattern matches digit-grouping commas such as 1,234
+var thousandsPattern = regexp.MustCompile(`(\d),(\d{3})`)
+
+// monthName matches full and abbreviated English month names
+const monthName = `(?:jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+
+// datePattern matches calendar dates such as 2024-02-01, March 5, 2024, Feb 2024
+// and 5 March, whose numbers describe time rather than the data
+var datePattern = regexp.MustCompile(`(?i)\b\d{4}-\d{2}-\d{2}\b|\b` + monthName + `\s+\d{1,2}(?:st|nd|rd|th)?(?:,?\s+\d{4})?\b|\b` + monthName + `\s+\d{4}\b|\b\d{1,2}(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthName + `(?:,?\s+\d{4})?\b`)
+
+// quotedPattern matches quoted text, whose numbers come from reviews rather than the data
+var quotedPattern = regexp.MustCompile(`"[^"]*"|“[^”]*”`)
+
+// checkNarrativeNumbers flags numbers in the narrative that match no figure in the comparison
+func checkNarrativeNumbers(impact *ImpactSummary, comparison ComparisonResult) []NumberCheck {
+	facts := comparisonFacts(comparison, impact.SuccessScore)
+
+	fields := []struct {
+		name string
+		text string
+	}{{"executive_summary", impact.ExecutiveSummary}}
+	for i, c := range impact.KeyImprovements {
+		fields = append(fields, struct{ name, text string }{fmt.Sprintf("key_improvements[%d]", i), c.Text})
+	}
+	for i, c := range impact.CriticalIssues {
+		fields = append(fields, struct{ name, text string }{fmt.Sprintf("critical_issues[%d]", i), c.Text})
+	}
+	for i, r := range impact.Recommendations {
+		fields = append(fields, struct{ name, text string }{fmt.Sprintf("recommendations[%d]", i), r})
+	}
+
+	checks := []NumberCheck{}
+	for _, f := range fields {
+		text := quotedPattern.ReplaceAllString(f.text, `""`)
+		text = datePattern.ReplaceAllString(text, "<date>")
+		text = thousandsPattern.ReplaceAllString(text, "$1$2")
+		for _, m := range numberPattern.FindAllStringSubmatchIndex(text, -1) {
+			raw := text[m[2]:m[3]]
+			if m[3] < len(text) && isLetter(text[m[3]]) {
+				continue // ordinals and identifiers like 3rd or v2
+			}
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || matchesFact(value, facts) {
+				continue
+			}
+			checks = append(checks, NumberCheck{Value: raw, Field: f.name, Context: snippet(text, m[2], m[3])})
+		}
+	}
+	return checks
+}
+
+// comparisonFacts lists every figure the narrative may legitimately quote
+func comparisonFacts(comparison ComparisonResult, score float64) []float64 {
+	facts := []float64{score, comparison.SentimentShift, math.Abs(comparison.SentimentShift)}
+	pre, post := comparison.PreLaunchSentiment, comparison.PostLaunchSentiment
+	ratingDelta := post.Average - pre.Average
+	facts = append(facts, ratingDelta, math.Abs(ratingDelta), successThreshold, 100)
+
+	for _, s := range []SentimentSummary{pre, post} {
+		total := s.Positive + s.Negative + s.Neutral
+		facts = append(facts, float64(total), float64(s.Positive), float64(s.Negative), float64(s.Neutral), s.Average)
+		if total > 0 {
+			for _, n := range []int{s.Positive, s.Negative, s.Neutral} {
+				facts = append(facts, float64(n)/float64(total)*100)
+			}
+		}
+	}
+	for _, s := range [][2]int{{pre.Positive, post.Positive}, {pre.Negative, post.Negative}, {pre.Neutral, post.Neutral}} {
+		facts = append(facts, math.Abs(float64(s[1]-s[0])))
+	}
+
+	for _, t := range flattenThemes(allThemes(comparison)) {
+		facts = append(facts,
+			float64(t.PreCount), float64(t.PostCount), t.ChangeRate, math.Abs(t.ChangeRate),
+			math.Abs(float64(t.PostCount-t.PreCount)))
+		for _, c := range []SentimentCounts{t.PreSentiment, t.PostSentiment} {
+			facts = append(facts, float64(c.Positive), float64(c.Negative), float64(c.Neutral))
+		}
+	}
+	return facts
+}
+
+// matchesFact allows for the rounding a narrative naturally applies
+func matchesFact(value float64, facts []float64) bool {
+	for _, f := range facts {
+		if math.Abs(value-f) <= 0.51 || math.Abs(math.Abs(value)-math.Abs(f)) <= 0.51 {
+			return true
+		}
+		if f != 0 && math.Abs(value-f)/math.Abs(f) <= 0.01 {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// snippet returns a short window of text around a match
+func snippet(text string, start, end int) string {
+	from, to := start-30, end+30
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	return strings.TrimSpace(text[from:to])
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// narrativeComparison has 7 negative post-launch reviews and nothing near 3
+func narrativeComparison() ComparisonResult {
+	return ComparisonResult{
+		PreLaunchSentiment:  SentimentSummary{Positive: 40, Negative: 20, Neutral: 40, Average: 3.8},
+		PostLaunchSentiment: SentimentSummary{Positive: 55, Negative: 7, Neutral: 38, Average: 4.4},
+		SentimentShift:      15,
+	}
+}
+
+func TestCheckNarrativeNumbersFlagsSmallInventedCounts(t *testing.T) {
+	impact := &ImpactSummary{
+		SuccessScore:     70,
+		ExecutiveSummary: "Only 7 negative reviews after launch, but 3 new issues appeared.",
+	}
+	checks := checkNarrativeNumbers(impact, narrativeComparison())
+	if len(checks) != 1 || checks[0].Value != "3" || checks[0].Field != "executive_summary" {
+		t.Fatalf("checks = %+v, want only the invented 3 flagged", checks)
+	}
+}
+
+func TestCheckNarrativeNumbersSkipsDatesAndOrdinals(t *testing.T) {
+	impact := &ImpactSummary{
+		SuccessScore: 70,
+		ExecutiveSummary: "Since the March 5, 2024 launch (2024-02-01 in the EU) sentiment rose 15 points; " +
+			"the 3rd release on 12 April and the Feb 2024 patch both helped.",
+		Recommendations: []string{`Follow up on "crashes 4 times a day" reports.`},
+	}
+	if checks := checkNarrativeNumbers(impact, narrativeComparison()); len(checks) != 0 {
+		var values []string
+		for _, c := range checks {
+			values = append(values, c.Value+" in "+c.Context)
+		}
+		t.Fatalf("flagged %s", strings.Join(values, "; "))
+	}
+}
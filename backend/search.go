@@ -0,0 +1,264 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Search result paging limits
+const (
+	defaultSearchPageSize = 50
+	maxSearchPageSize     = 500
+)
+
+// SearchDocument is a review together with the labels the analysis gave it
+type SearchDocument struct {
+	Review
+	Phase     string   `json:"phase"`
+	Sentiment string   `json:"sentiment,omitempty"` // empty until the review has been analyzed
+	Themes    []string `json:"themes"`
+}
+
+// ReviewQuery filters, sorts and pages a review search. Empty fields match everything
+type ReviewQuery struct {
+	Phase     string `json:"phase"`
+	Sentiment string `json:"sentiment"`
+	Theme     string `json:"theme"`
+	Source    string `json:"source"`
+	MinRating int    `json:"min_rating"`
+	MaxRating int    `json:"max_rating"`
+	From      string `json:"from"` // inclusive YYYY-MM-DD
+	To        string `json:"to"`   // inclusive YYYY-MM-DD
+	Keyword   string `json:"q"`
+	Sort      string `json:"sort"` // date, rating or id; prefix with - for descending
+	Page      int    `json:"page"`
+	PageSize  int    `json:"page_size"`
+}
+
+// ReviewSearchResult is one page of matching reviews
+type ReviewSearchResult struct {
+	Total    int              `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Reviews  []SearchDocument `json:"reviews"`
+}
+
+// ReviewSearchIndex keeps inverted indexes over the uploaded reviews so
+// filters are answered by intersecting posting lists instead of scanning
+type ReviewSearchIndex struct {
+	mu     sync.RWMutex
+	docs   []SearchDocument
+	fields map[string]map[string][]int // field -> value -> ascending document positions
+	terms  map[string][]int            // keyword token -> ascending document positions
+}
+
+// NewReviewSearchIndex creates an empty index
+func NewReviewSearchIndex() *ReviewSearchIndex {
+	idx := &ReviewSearchIndex{}
+	idx.rebuild()
+	return idx
+}
+
+// Load replaces the indexed reviews with a freshly uploaded dataset
+func (idx *ReviewSearchIndex) Load(preReviews, postReviews []Review) {
+	docs := make([]SearchDocument, 0, len(preReviews)+len(postReviews))
+	for _, r := range preReviews {
+		docs = append(docs, SearchDocument{Review: r, Phase: "pre_launch", Themes: []string{}})
+	}
+	for _, r := range postReviews {
+		docs = append(docs, SearchDocument{Review: r, Phase: "post_launch", Themes: []string{}})
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs = docs
+	idx.rebuild()
+}
+
+// Annotate labels indexed reviews with the sentiment and themes from an analysis
+func (idx *ReviewSearchIndex) Annotate(result *AnalysisResult) {
+	sentiments := map[string]map[string]string{"pre_launch": {}, "post_launch": {}}
+	for _, s := range result.PreLaunchReviews.Sentiments {
+		sentiments["pre_launch"][s.ReviewID] = s.Sentiment
+	}
+	for _, s := range result.PostLaunchReviews.Sentiments {
+		sentiments["post_launch"][s.ReviewID] = s.Sentiment
+	}
+
+	themes := reviewThemes(result.Comparison)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for i := range idx.docs {
+		d := &idx.docs[i]
+		d.Sentiment = sentiments[d.Phase][d.ID]
+		d.Themes = append([]string{}, themes[d.Phase][d.ID]...)
+	}
+	idx.rebuild()
+}
+
+// rebuild recreates the posting lists; callers hold the write lock
+func (idx *ReviewSearchIndex) rebuild() {
+	idx.fields = map[string]map[string][]int{
+		"phase": {}, "sentiment": {}, "theme": {}, "source": {}, "rating": {},
+	}
+	idx.terms = make(map[string][]int)
+
+	for i, d := range idx.docs {
+		idx.post("phase", d.Phase, i)
+		idx.post("sentiment", d.Sentiment, i)
+		idx.post("source", d.Source, i)
+		idx.post("rating", strconv.Itoa(d.Rating), i)
+		for _, t := range d.Themes {
+			idx.post("theme", t, i)
+		}
+
+		seen := make(map[string]bool)
+		for _, token := range tokenize(d.ReviewText) {
+			if !seen[token] {
+				seen[token] = true
+				idx.terms[token] = append(idx.terms[token], i)
+			}
+		}
+	}
+}
+
+// post appends a document to a field's posting list, skipping repeats
+func (idx *ReviewSearchIndex) post(field, value string, doc int) {
+	if value == "" {
+		return
+	}
+	key := strings.ToLower(strings.TrimSpace(value))
+	list := idx.fields[field][key]
+	if len(list) == 0 || list[len(list)-1] != doc {
+		idx.fields[field][key] = append(list, doc)
+	}
+}
+
+// Search returns one sorted page of the reviews matching every filter
+func (idx *ReviewSearchIndex) Search(q ReviewQuery) ReviewSearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var lists [][]int
+	for field, value := range map[string]string{"phase": q.Phase, "sentiment": q.Sentiment, "theme": q.Theme, "source": q.Source} {
+		if value != "" {
+			lists = append(lists, idx.fields[field][strings.ToLower(strings.TrimSpace(value))])
+		}
+	}
+	if q.MinRating > 0 || q.MaxRating > 0 {
+		var ratings []int
+		for value, list := range idx.fields["rating"] {
+			rating, _ := strconv.Atoi(value)
+			if (q.MinRating == 0 || rating >= q.MinRating) && (q.MaxRating == 0 || rating <= q.MaxRating) {
+				ratings = append(ratings, list...)
+			}
+		}
+		sort.Ints(ratings)
+		lists = append(lists, ratings)
+	}
+	for _, token := range tokenize(q.Keyword) {
+		lists = append(lists, idx.terms[token])
+	}
+
+	var matches []int
+	if len(lists) == 0 {
+		matches = make([]int, len(idx.docs))
+		for i := range matches {
+			matches[i] = i
+		}
+	} else {
+		sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+		matches = lists[0]
+		for _, list := range lists[1:] {
+			matches = intersectSorted(matches, list)
+		}
+	}
+
+	// Dates are YYYY-MM-DD, so a string comparison on the prefix orders them
+	filtered := make([]SearchDocument, 0, len(matches))
+	for _, i := range matches {
+		d := idx.docs[i]
+		date := d.Date
+		if len(date) > 10 {
+			date = date[:10]
+		}
+		if (q.From != "" && date < q.From) || (q.To != "" && date > q.To) {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+
+	sortDocuments(filtered, q.Sort)
+
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+	// Clamp the page to just past the last one before multiplying, so a huge
+	// page number cannot overflow the offset
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	if last := (len(filtered)+pageSize-1)/pageSize + 1; page > last {
+		page = last
+	}
+	start := min((page-1)*pageSize, len(filtered))
+	end := start + pageSize
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	return ReviewSearchResult{
+		Total:    len(filtered),
+		Page:     page,
+		PageSize: pageSize,
+		Reviews:  filtered[start:end],
+	}
+}
+
+// sortDocuments orders documents by date, rating or id; a leading - sorts descending
+func sortDocuments(docs []SearchDocument, order string) {
+	descending := strings.HasPrefix(order, "-")
+	var less func(a, b SearchDocument) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "date":
+		less = func(a, b SearchDocument) bool { return a.Date < b.Date }
+	case "rating":
+		less = func(a, b SearchDocument) bool { return a.Rating < b.Rating }
+	case "id":
+		less = func(a, b SearchDocument) bool { return a.ID < b.ID }
+	default:
+		return // keep upload order
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if descending {
+			return less(docs[j], docs[i])
+		}
+		return less(docs[i], docs[j])
+	})
+}
+
+// intersectSorted returns the values present in both ascending lists
+func intersectSorted(a, b []int) []int {
+	result := []int{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
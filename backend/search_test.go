@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSearchClampsHugePages(t *testing.T) {
+	idx := NewReviewSearchIndex()
+	idx.Load(budgetTestReviews("pre", 5), budgetTestReviews("post", 5))
+
+	for _, page := range []int{math.MaxInt, math.MaxInt/3 + 2, 5} {
+		t.Run(fmt.Sprint(page), func(t *testing.T) {
+			result := idx.Search(ReviewQuery{Page: page, PageSize: 3})
+			if result.Total != 10 || len(result.Reviews) != 0 {
+				t.Fatalf("page %d = %d of %d reviews, want an empty page", page, len(result.Reviews), result.Total)
+			}
+		})
+	}
+
+	if result := idx.Search(ReviewQuery{Page: 4, PageSize: 3}); len(result.Reviews) != 1 {
+		t.Fatalf("last page has %d reviews, want 1", len(result.Reviews))
+	}
+}
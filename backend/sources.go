@@ -0,0 +1,502 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Review source types
+const (
+	sourceDirectory = "directory" // every *.csv file in a local directory
+	sourceHTTP      = "http"      // one CSV served over HTTP(S)
+	sourceS3        = "s3"        // every *.csv object under a prefix of an S3-compatible bucket
+	sourceConnector = "connector" // a review connector, synced before every run
+)
+
+// maxSourceBytes caps a downloaded CSV
+const maxSourceBytes = 256 << 20
+
+// secretEnvPrefix names the environment variables job sources may reference
+const secretEnvPrefix = "JOB_SECRET_"
+
+// s3Region guards the region, which becomes part of the default endpoint host
+var s3Region = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// JobSource says where a scheduled job reads reviews from. Header values and
+// the S3 endpoint may reference allowed environment variables as ${NAME} (see
+// secretAllowed), and S3 credentials come from the AWS_* variables, so
+// secrets stay out of the job store
+type JobSource struct {
+	Type      string            `json:"type"`
+	Path      string            `json:"path,omitempty"`      // directory
+	URL       string            `json:"url,omitempty"`       // http
+	Headers   map[string]string `json:"headers,omitempty"`   // http, e.g. {"Authorization": "Bearer ${JOB_SECRET_REVIEWS_TOKEN}"}
+	Endpoint  string            `json:"endpoint,omitempty"`  // s3; default https://s3.<region>.amazonaws.com
+	Bucket    string            `json:"bucket,omitempty"`    // s3
+	Prefix    string            `json:"prefix,omitempty"`    // s3
+	Region    string            `json:"region,omitempty"`    // s3; default AWS_REGION or us-east-1
+	Connector string            `json:"connector,omitempty"` // connector name
+}
+
+// validate checks the fields required by the source type
+func (s JobSource) validate() error {
+	switch s.Type {
+	case sourceDirectory:
+		if s.Path == "" {
+			return fmt.Errorf("a directory source needs a path")
+		}
+		if _, err := sourceDir(s.Path); err != nil {
+			return err
+		}
+	case sourceHTTP:
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("an http source needs an absolute http or https url")
+		}
+		for key, value := range s.Headers {
+			if err := checkSecrets("header "+key, value); err != nil {
+				return err
+			}
+		}
+	case sourceS3:
+		if s.Bucket == "" {
+			return fmt.Errorf("an s3 source needs a bucket")
+		}
+		if s.Region != "" && !s3Region.MatchString(s.Region) {
+			return fmt.Errorf("invalid s3 region %q", s.Region)
+		}
+		if s.Endpoint != "" {
+			if err := checkSecrets("endpoint", s.Endpoint); err != nil {
+				return err
+			}
+			if u, err := url.Parse(s.Endpoint); err != nil || u.Host == "" {
+				return fmt.Errorf("invalid s3 endpoint %q", s.Endpoint)
+			}
+		}
+	case sourceConnector:
+		if s.Connector == "" {
+			return fmt.Errorf("a connector source needs a connector name")
+		}
+	default:
+		return fmt.Errorf("source type must be %s, %s, %s or %s", sourceDirectory, sourceHTTP, sourceS3, sourceConnector)
+	}
+	return nil
+}
+
+// sourceFile is one CSV read from a source
+type sourceFile struct {
+	name string
+	body io.ReadCloser
+}
+
+// Fetch reads and parses every CSV in the source. Reviews repeated across
+// files (e.g. overlapping weekly exports) are kept once; a different review
+// reusing an ID gets the file name as a prefix
+func (s JobSource) Fetch(client *http.Client, parser ReviewParser) ([]Review, []string, error) {
+	var names []string
+	var reviews []Review
+	seen := make(map[string]string) // review ID -> review hash
+
+	err := s.each(client, func(f sourceFile) error {
+		defer f.body.Close()
+		parsed, err := parser.ParseCSV(io.LimitReader(f.body, maxSourceBytes))
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		names = append(names, f.name)
+		for _, r := range parsed {
+			hash := reviewHash(r)
+			if existing, ok := seen[r.ID]; ok {
+				if existing == hash {
+					continue
+				}
+				r.ID = path.Base(f.name) + ":" + r.ID
+			}
+			seen[r.ID] = hash
+			reviews = append(reviews, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, names, err
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("the %s source has no CSV files", s.Type)
+	}
+	return reviews, names, nil
+}
+
+// each opens every CSV in the source in name order
+func (s JobSource) each(client *http.Client, fn func(sourceFile) error) error {
+	switch s.Type {
+	case sourceDirectory:
+		dir, err := sourceDir(s.Path)
+		if err != nil {
+			return err
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(matches)
+		for _, name := range matches {
+			// A symlinked file must not lead out of the root either
+			if _, err := sourceDir(name); err != nil {
+				return err
+			}
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			if err := fn(sourceFile{name: name, body: f}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case sourceHTTP:
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		if err != nil {
+			return err
+		}
+		for key, value := range s.Headers {
+			req.Header.Set(key, expandSecrets(value))
+		}
+		body, err := fetchBody(client, req)
+		if err != nil {
+			return err
+		}
+		return fn(sourceFile{name: s.URL, body: body})
+	case sourceS3:
+		bucket := newS3Bucket(s)
+		keys, err := bucket.list(client)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			req, err := bucket.request(http.MethodGet, "/"+key, nil)
+			if err != nil {
+				return err
+			}
+			body, err := fetchBody(client, req)
+			if err != nil {
+				return fmt.Errorf("s3://%s/%s: %w", s.Bucket, key, err)
+			}
+			if err := fn(sourceFile{name: key, body: body}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case sourceConnector:
+		return fmt.Errorf("connector sources are read through the connector store")
+	}
+	return fmt.Errorf("unknown source type %q", s.Type)
+}
+
+// sourceDir resolves a directory source's path inside JOB_SOURCE_ROOT. Job
+// definitions come from API callers and their results are served back, so
+// directory sources are disabled until the operator sets a root, and paths
+// (after following symlinks) may not leave it. Relative paths are taken
+// from the root
+func sourceDir(p string) (string, error) {
+	root := os.Getenv("JOB_SOURCE_ROOT")
+	if root == "" {
+		return "", fmt.Errorf("directory sources are disabled; set JOB_SOURCE_ROOT to allow them")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside JOB_SOURCE_ROOT", p)
+	}
+	return p, nil
+}
+
+// secretAllowed reports whether sources may read an environment variable:
+// those named JOB_SECRET_* or listed in the comma-separated JOB_SECRET_NAMES.
+// Job and connector definitions come from API callers, so the LLM key, AWS
+// credentials and everything else in the environment stay out of reach
+func secretAllowed(name string) bool {
+	if strings.HasPrefix(name, secretEnvPrefix) && len(name) > len(secretEnvPrefix) {
+		return true
+	}
+	for _, allowed := range strings.Split(os.Getenv("JOB_SECRET_NAMES"), ",") {
+		if strings.TrimSpace(allowed) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSecrets substitutes allowed ${NAME} references; any other reference
+// expands to "" (definitions saved before the allowlist are not re-checked)
+func expandSecrets(s string) string {
+	return os.Expand(s, func(name string) string {
+		if !secretAllowed(name) {
+			return ""
+		}
+		return os.Getenv(name)
+	})
+}
+
+// checkSecrets rejects references to environment variables outside the allowlist
+func checkSecrets(field, s string) error {
+	var denied []string
+	os.Expand(s, func(name string) string {
+		if !secretAllowed(name) && !containsString(denied, name) {
+			denied = append(denied, name)
+		}
+		return ""
+	})
+	if len(denied) > 0 {
+		return fmt.Errorf("%s references %s; only variables named %s* or listed in JOB_SECRET_NAMES may be used",
+			field, strings.Join(denied, ", "), secretEnvPrefix)
+	}
+	return nil
+}
+
+// fetchBody sends a request and returns the body of a 2xx response
+func fetchBody(client *http.Client, req *http.Request) (io.ReadCloser, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return resp.Body, nil
+}
+
+// s3Bucket signs path-style requests to an S3-compatible bucket with AWS
+// Signature Version 4. Without credentials, or for an endpoint the operator
+// has not trusted, requests are sent unsigned
+type s3Bucket struct {
+	endpoint     *url.URL
+	signed       bool
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// newS3Bucket reads credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN. They are only sent to AWS itself or to a custom
+// endpoint whose host is listed in the comma-separated S3_SIGNED_ENDPOINTS
+func newS3Bucket(s JobSource) *s3Bucket {
+	region := s.Region
+	if region == "" {
+		region = getEnv("AWS_REGION", "us-east-1")
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(expandSecrets(endpoint))
+	if err != nil {
+		u = &url.URL{}
+	}
+	signed := s.Endpoint == ""
+	for _, host := range strings.Split(os.Getenv("S3_SIGNED_ENDPOINTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" && strings.EqualFold(host, u.Host) {
+			signed = true
+		}
+	}
+	return &s3Bucket{
+		endpoint:     u,
+		signed:       signed,
+		bucket:       s.Bucket,
+		prefix:       s.Prefix,
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// s3ListResult is the part of a ListObjectsV2 response we use
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns the keys of the CSV objects under the prefix, following pagination
+func (b *s3Bucket) list(client *http.Client) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := b.request(http.MethodGet, "", query)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fetchBody(client, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", b.bucket, b.prefix, err)
+		}
+		var page s3ListResult
+		err = xml.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse s3 listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			if strings.HasSuffix(strings.ToLower(object.Key), ".csv") {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// request builds a signed request for a key ("" addresses the bucket)
+func (b *s3Bucket) request(method, key string, query url.Values) (*http.Request, error) {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.bucket + key
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.signed && b.accessKey != "" && b.secretKey != "" {
+		b.sign(req, time.Now())
+	}
+	return req, nil
+}
+
+// sign adds SigV4 headers for an empty-bodied request sent at now
+func (b *s3Bucket) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(nil)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if b.sessionToken != "" {
+		headers["x-amz-security-token"] = b.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + b.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.secretKey), day)
+	for _, part := range []string{b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath encodes each path segment as SigV4 requires
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes and sorts query parameters as SigV4 requires
+func s3CanonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes everything except RFC 3986 unreserved characters
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex SHA-256 digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
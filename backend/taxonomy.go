@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Taxonomy theme statuses
+const (
+	themeApproved = "approved"
+	themeProposed = "proposed"
+)
+
+// TaxonomyTheme is a canonical theme with the alternative names it absorbs
+type TaxonomyTheme struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Synonyms    []string `json:"synonyms"`
+	Parent      string   `json:"parent,omitempty"`      // canonical name of the parent theme
+	Status      string   `json:"status"`                // approved or proposed
+	ProposedBy  string   `json:"proposed_by,omitempty"` // dataset that first surfaced a proposed theme
+	Sightings   int      `json:"sightings,omitempty"`   // analyses that proposed it
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// ThemeTaxonomy stores canonical themes so analyses use the same names across launches
+type ThemeTaxonomy struct {
+	mu     sync.RWMutex
+	path   string
+	themes []TaxonomyTheme
+}
+
+// NewThemeTaxonomy creates a taxonomy persisted at path ("" keeps it in memory)
+func NewThemeTaxonomy(path string) (*ThemeTaxonomy, error) {
+	t := &ThemeTaxonomy{path: path}
+	if err := loadJSONFile(path, &t.themes); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// List returns all themes, approved first, sorted by name
+func (t *ThemeTaxonomy) List() []TaxonomyTheme {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	themes := append([]TaxonomyTheme{}, t.themes...)
+	sort.Slice(themes, func(i, j int) bool {
+		if themes[i].Status != themes[j].Status {
+			return themes[i].Status == themeApproved
+		}
+		return themes[i].Name < themes[j].Name
+	})
+	return themes
+}
+
+// Approved returns the themes the extractor should map reviews onto
+func (t *ThemeTaxonomy) Approved() []TaxonomyTheme {
+	var approved []TaxonomyTheme
+	for _, theme := range t.List() {
+		if theme.Status == themeApproved {
+			approved = append(approved, theme)
+		}
+	}
+	return approved
+}
+
+// Upsert creates or replaces a theme by name. Names and synonyms must not
+// collide with another theme
+func (t *ThemeTaxonomy) Upsert(theme TaxonomyTheme) (TaxonomyTheme, error) {
+	theme.Name = strings.TrimSpace(theme.Name)
+	if theme.Name == "" {
+		return theme, fmt.Errorf("theme name is required")
+	}
+	if theme.Status == "" {
+		theme.Status = themeApproved
+	}
+	if theme.Status != themeApproved && theme.Status != themeProposed {
+		return theme, fmt.Errorf("unknown status %q", theme.Status)
+	}
+	synonyms := []string{}
+	for _, s := range theme.Synonyms {
+		if s = strings.TrimSpace(s); s != "" && themeKey(s) != themeKey(theme.Name) {
+			synonyms = append(synonyms, s)
+		}
+	}
+	theme.Synonyms = synonyms
+	theme.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing := t.indexOf(theme.Name)
+	for _, name := range append([]string{theme.Name}, theme.Synonyms...) {
+		if i := t.lookup(name); i >= 0 && i != existing {
+			return theme, fmt.Errorf("%q already belongs to theme %q", name, t.themes[i].Name)
+		}
+	}
+	if err := t.checkParent(theme); err != nil {
+		return theme, err
+	}
+
+	if existing >= 0 {
+		t.themes[existing] = theme
+	} else {
+		t.themes = append(t.themes, theme)
+	}
+	return theme, t.save()
+}
+
+// Delete removes a theme; rejecting a proposal is a delete. Its sub-themes
+// become top level
+func (t *ThemeTaxonomy) Delete(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.indexOf(name)
+	if i < 0 {
+		return fmt.Errorf("theme %q not found", name)
+	}
+	t.reparent(t.themes[i].Name, "", time.Now().UTC().Format(time.RFC3339))
+	t.themes = append(t.themes[:i], t.themes[i+1:]...)
+	return t.save()
+}
+
+// Approve accepts a proposed theme. With mergeInto set, the proposal becomes
+// a synonym of that existing theme instead of a theme of its own, and its
+// sub-themes move under the target (or under the target's parent, when the
+// target is itself a sub-theme)
+func (t *ThemeTaxonomy) Approve(name, mergeInto string) (TaxonomyTheme, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.indexOf(name)
+	if i < 0 {
+		return TaxonomyTheme{}, fmt.Errorf("theme %q not found", name)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	if mergeInto == "" {
+		t.themes[i].Status = themeApproved
+		t.themes[i].UpdatedAt = now
+		return t.themes[i], t.save()
+	}
+
+	target := t.indexOf(mergeInto)
+	if target < 0 {
+		return TaxonomyTheme{}, fmt.Errorf("theme %q not found", mergeInto)
+	}
+	if target == i {
+		return TaxonomyTheme{}, fmt.Errorf("cannot merge %q into itself", name)
+	}
+	merged := t.themes[i]
+	t.themes[target].Synonyms = append(t.themes[target].Synonyms, merged.Name)
+	t.themes[target].Synonyms = append(t.themes[target].Synonyms, merged.Synonyms...)
+	t.themes[target].UpdatedAt = now
+	newParent := t.themes[target].Name
+	if p := t.themes[target].Parent; p != "" && themeKey(p) != themeKey(merged.Name) {
+		newParent = p
+	}
+	t.reparent(merged.Name, newParent, now)
+	result := t.themes[target]
+	t.themes = append(t.themes[:i], t.themes[i+1:]...)
+	return result, t.save()
+}
+
+// Resolve renames extracted themes to their canonical names, merging themes
+// that map to the same entry. Themes that fit no approved entry are flagged
+// and recorded as proposals
+func (t *ThemeTaxonomy) Resolve(themes []ThemeResult, datasetID string) []ThemeResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resolved := []ThemeResult{}
+	position := make(map[string]int)
+	changed := false
+	for _, theme := range themes {
+		name := strings.TrimSpace(theme.Theme)
+		if name == "" {
+			continue
+		}
+
+		if p := t.lookup(theme.Parent); p >= 0 {
+			theme.Parent = t.themes[p].Name
+		}
+
+		i := t.lookup(name)
+		if i >= 0 && t.themes[i].Status == themeApproved {
+			theme.Theme = t.themes[i].Name
+			theme.Parent = t.themes[i].Parent
+			theme.Proposed = false
+		} else {
+			if i < 0 {
+				t.themes = append(t.themes, TaxonomyTheme{Name: name, Synonyms: []string{}, Parent: theme.Parent, Status: themeProposed, ProposedBy: datasetID})
+				i = len(t.themes) - 1
+			}
+			t.themes[i].Sightings++
+			t.themes[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			theme.Theme = t.themes[i].Name
+			theme.Proposed = true
+			changed = true
+		}
+
+		if j, ok := position[theme.Theme]; ok {
+			resolved[j].PreCount += theme.PreCount
+			resolved[j].PostCount += theme.PostCount
+			resolved[j].ChangeRate = changeRate(resolved[j].PreCount, resolved[j].PostCount)
+			continue
+		}
+		position[theme.Theme] = len(resolved)
+		resolved = append(resolved, theme)
+	}
+
+	if changed {
+		if err := t.save(); err != nil {
+			log.Printf("⚠️ Failed to save theme taxonomy: %v", err)
+		}
+	}
+	return resolved
+}
+
+// reparent moves the sub-themes of a removed theme under newParent, or to the
+// top level when newParent is "" or the sub-theme itself; callers hold the lock
+func (t *ThemeTaxonomy) reparent(removed, newParent, now string) {
+	for i := range t.themes {
+		if themeKey(t.themes[i].Parent) != themeKey(removed) {
+			continue
+		}
+		t.themes[i].Parent = newParent
+		if themeKey(newParent) == themeKey(t.themes[i].Name) {
+			t.themes[i].Parent = ""
+		}
+		t.themes[i].UpdatedAt = now
+	}
+}
+
+// checkParent keeps the taxonomy two levels deep: a parent must exist and be
+// top level, and a theme with sub-themes cannot itself become a sub-theme
+func (t *ThemeTaxonomy) checkParent(theme TaxonomyTheme) error {
+	if theme.Parent == "" {
+		return nil
+	}
+	if themeKey(theme.Parent) == themeKey(theme.Name) {
+		return fmt.Errorf("theme %q cannot be its own parent", theme.Name)
+	}
+	p := t.indexOf(theme.Parent)
+	if p < 0 {
+		return fmt.Errorf("parent theme %q not found", theme.Parent)
+	}
+	if t.themes[p].Parent != "" {
+		return fmt.Errorf("parent theme %q is itself a sub-theme", theme.Parent)
+	}
+	for _, other := range t.themes {
+		if themeKey(other.Parent) == themeKey(theme.Name) {
+			return fmt.Errorf("theme %q has sub-themes and cannot have a parent", theme.Name)
+		}
+	}
+	return nil
+}
+
+// lookup finds the theme whose name or synonym matches, or -1
+func (t *ThemeTaxonomy) lookup(name string) int {
+	key := themeKey(name)
+	for i, theme := range t.themes {
+		if themeKey(theme.Name) == key {
+			return i
+		}
+		for _, s := range theme.Synonyms {
+			if themeKey(s) == key {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// indexOf finds a theme by its canonical name only, or -1
+func (t *ThemeTaxonomy) indexOf(name string) int {
+	key := themeKey(name)
+	for i, theme := range t.themes {
+		if themeKey(theme.Name) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the taxonomy; callers hold the lock
+func (t *ThemeTaxonomy) save() error {
+	return saveJSONFile(t.path, t.themes)
+}
+
+// themeKey normalises a theme name for matching
+func themeKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// formatTaxonomyForThemes lists canonical themes for the extraction prompt
+func formatTaxonomyForThemes(taxonomy []TaxonomyTheme) string {
+	if len(taxonomy) == 0 {
+		return "(none defined yet)\n"
+	}
+	result := ""
+	for _, theme := range taxonomy {
+		result += "- " + theme.Name
+		if theme.Parent != "" {
+			result += " [sub-theme of " + theme.Parent + "]"
+		}
+		if theme.Description != "" {
+			result += ": " + theme.Description
+		}
+		if len(theme.Synonyms) > 0 {
+			result += " (also: " + strings.Join(theme.Synonyms, ", ") + ")"
+		}
+		result += "\n"
+	}
+	return result
+}
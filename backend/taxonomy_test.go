@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestTaxonomy builds an in-memory taxonomy from the given themes
+func newTestTaxonomy(t *testing.T, themes ...TaxonomyTheme) *ThemeTaxonomy {
+	t.Helper()
+	taxonomy, err := NewThemeTaxonomy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, theme := range themes {
+		if _, err := taxonomy.Upsert(theme); err != nil {
+			t.Fatalf("Upsert %s: %v", theme.Name, err)
+		}
+	}
+	return taxonomy
+}
+
+// parents lists every theme as name>parent, sorted by name
+func parents(taxonomy *ThemeTaxonomy) string {
+	var pairs []string
+	for _, theme := range taxonomy.List() {
+		pairs = append(pairs, theme.Name+">"+theme.Parent)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func TestTaxonomyUpsertChecks(t *testing.T) {
+	taxonomy := newTestTaxonomy(t,
+		TaxonomyTheme{Name: "Performance", Synonyms: []string{"speed", " performance ", ""}},
+		TaxonomyTheme{Name: "Battery", Parent: "performance"},
+		TaxonomyTheme{Name: "Stability"},
+	)
+	if theme := taxonomy.List()[1]; theme.Name != "Performance" || strings.Join(theme.Synonyms, ",") != "speed" {
+		t.Fatalf("theme = %+v, want blank and self synonyms dropped", theme)
+	}
+
+	cases := []struct {
+		theme TaxonomyTheme
+		err   string
+	}{
+		{TaxonomyTheme{Name: " "}, "name is required"},
+		{TaxonomyTheme{Name: "Login", Status: "draft"}, "unknown status"},
+		{TaxonomyTheme{Name: "Speed"}, "already belongs"},
+		{TaxonomyTheme{Name: "Login", Synonyms: []string{"SPEED"}}, "already belongs"},
+		{TaxonomyTheme{Name: "Login", Parent: "login"}, "its own parent"},
+		{TaxonomyTheme{Name: "Login", Parent: "Billing"}, "not found"},
+		{TaxonomyTheme{Name: "Drain", Parent: "Battery"}, "is itself a sub-theme"},
+		{TaxonomyTheme{Name: "Performance", Parent: "Stability"}, "has sub-themes"},
+	}
+	for _, c := range cases {
+		if _, err := taxonomy.Upsert(c.theme); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Upsert(%+v) error = %v, want %q", c.theme, err, c.err)
+		}
+	}
+}
+
+func TestTaxonomyDeleteMakesSubThemesTopLevel(t *testing.T) {
+	taxonomy := newTestTaxonomy(t,
+		TaxonomyTheme{Name: "Performance"},
+		TaxonomyTheme{Name: "Battery", Parent: "Performance"},
+		TaxonomyTheme{Name: "Startup", Parent: "performance"},
+	)
+	if err := taxonomy.Delete("PERFORMANCE"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := parents(taxonomy); got != "Battery>,Startup>" {
+		t.Fatalf("themes = %s, want the sub-themes top level", got)
+	}
+	if err := taxonomy.Delete("Performance"); err == nil {
+		t.Fatal("deleted a missing theme")
+	}
+}
+
+func TestTaxonomyMergeReparentsSubThemes(t *testing.T) {
+	cases := []struct {
+		name   string
+		themes []TaxonomyTheme
+		merge  string
+		into   string
+		want   string
+	}{
+		{
+			"into a top-level theme",
+			[]TaxonomyTheme{{Name: "Speed", Status: themeProposed}, {Name: "Lag", Parent: "Speed"}, {Name: "Performance"}},
+			"Speed", "Performance", "Lag>Performance,Performance>",
+		},
+		{
+			"into a sub-theme",
+			[]TaxonomyTheme{{Name: "Performance"}, {Name: "Battery", Parent: "Performance"}, {Name: "Power", Status: themeProposed}, {Name: "Charging", Parent: "Power"}},
+			"Power", "Battery", "Battery>Performance,Charging>Performance,Performance>",
+		},
+		{
+			"into one of its own sub-themes",
+			[]TaxonomyTheme{{Name: "Speed", Status: themeProposed}, {Name: "Lag", Parent: "Speed"}, {Name: "Startup", Parent: "Speed"}},
+			"Speed", "Lag", "Lag>,Startup>Lag",
+		},
+	}
+	for _, c := range cases {
+		taxonomy := newTestTaxonomy(t, c.themes...)
+		target, err := taxonomy.Approve(c.merge, c.into)
+		if err != nil {
+			t.Fatalf("%s: Approve: %v", c.name, err)
+		}
+		if !containsString(target.Synonyms, c.merge) {
+			t.Errorf("%s: target synonyms = %v, want %s", c.name, target.Synonyms, c.merge)
+		}
+		if got := parents(taxonomy); got != c.want {
+			t.Errorf("%s: themes = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTaxonomyApprove(t *testing.T) {
+	taxonomy := newTestTaxonomy(t, TaxonomyTheme{Name: "Lag", Status: themeProposed})
+	if _, err := taxonomy.Approve("Lag", "Lag"); err == nil {
+		t.Fatal("merged a theme into itself")
+	}
+	if _, err := taxonomy.Approve("Lag", "Missing"); err == nil {
+		t.Fatal("merged into a missing theme")
+	}
+	theme, err := taxonomy.Approve("lag", "")
+	if err != nil || theme.Status != themeApproved || len(taxonomy.Approved()) != 1 {
+		t.Fatalf("Approve = %+v, %v; want Lag approved", theme, err)
+	}
+}
+
+func TestTaxonomyResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taxonomy.json")
+	taxonomy, err := NewThemeTaxonomy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, theme := range []TaxonomyTheme{{Name: "Performance", Synonyms: []string{"Speed"}}, {Name: "Battery", Parent: "Performance"}} {
+		if _, err := taxonomy.Upsert(theme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolved := taxonomy.Resolve([]ThemeResult{
+		{Theme: "speed", PreCount: 2, PostCount: 4},
+		{Theme: "Performance", PreCount: 1, PostCount: 1},
+		{Theme: "battery"},
+		{Theme: "Dark  mode", PostCount: 3},
+		{Theme: " "},
+	}, "launch-1")
+
+	var got []string
+	for _, r := range resolved {
+		got = append(got, fmt.Sprintf("%s>%s %d/%d proposed=%v", r.Theme, r.Parent, r.PreCount, r.PostCount, r.Proposed))
+	}
+	want := "Performance> 3/5 proposed=false,Battery>Performance 0/0 proposed=false,Dark  mode> 0/3 proposed=true"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("resolved = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	reloaded, err := NewThemeTaxonomy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal := reloaded.List()[2]
+	if proposal.Name != "Dark  mode" || proposal.Status != themeProposed || proposal.ProposedBy != "launch-1" || proposal.Sightings != 1 {
+		t.Fatalf("proposal = %+v, want a saved proposal from launch-1", proposal)
+	}
+}
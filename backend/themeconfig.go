@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultThemeTopN matches the theme count the extractor has always asked for
+const defaultThemeTopN = 8
+
+// ThemeConfig controls how many themes are reported and which are highlighted.
+// A TopN of 0 (or less) means no limit: the extractor is asked for every
+// theme and none is moved to the long tail for rank
+type ThemeConfig struct {
+	TopN          int     `json:"top_n"`           // top-level themes to request and report; 0 for no limit
+	MinSupport    int     `json:"min_support"`     // minimum pre+post reviews for a theme to be reported
+	MinChangeRate float64 `json:"min_change_rate"` // minimum |change rate| in percent for a theme to be highlighted
+	Discovery     string  `json:"discovery"`       // "llm" or "clusters"
+}
+
+// LoadThemeConfig reads theme thresholds from the environment
+func LoadThemeConfig() ThemeConfig {
+	return ThemeConfig{
+		TopN:          getEnvInt("THEME_TOP_N", defaultThemeTopN),
+		MinSupport:    getEnvInt("THEME_MIN_SUPPORT", 1),
+		MinChangeRate: getEnvFloat("THEME_MIN_CHANGE_RATE", 20),
+		Discovery:     getEnv("THEME_DISCOVERY", discoveryLLM),
+	}
+}
+
+// ThemeRequest carries the per-analysis settings for theme extraction
+type ThemeRequest struct {
+	TopN     int             // themes to ask for; 0 for every theme
+	Taxonomy []TaxonomyTheme // approved themes to map reviews onto; empty when none
+}
+
+// ThemeRequestAnalyzer is implemented by analyzers that accept per-analysis theme settings
+type ThemeRequestAnalyzer interface {
+	ExtractThemesFor(preReviews, postReviews []Review, request ThemeRequest) ([]ThemeResult, error)
+}
+
+// LongTailBucket collects themes below the reporting thresholds
+type LongTailBucket struct {
+	Themes     []ThemeResult `json:"themes"`
+	PreCount   int           `json:"pre_count"` // summed mentions of top-level themes
+	PostCount  int           `json:"post_count"`
+	ChangeRate float64       `json:"change_rate"`
+}
+
+// applyThemeThresholds keeps the top N supported themes, moving the rest (and
+// unsupported sub-themes) to the long tail, and highlights large changes
+func applyThemeThresholds(themes []ThemeResult, config ThemeConfig) ([]ThemeResult, *LongTailBucket) {
+	tail := &LongTailBucket{Themes: []ThemeResult{}}
+
+	sort.SliceStable(themes, func(i, j int) bool {
+		return themeSupport(themes[i]) > themeSupport(themes[j])
+	})
+
+	kept := []ThemeResult{}
+	for _, t := range themes {
+		if themeSupport(t) < config.MinSupport || (config.TopN > 0 && len(kept) >= config.TopN) {
+			tail.add(t)
+			continue
+		}
+
+		// Sub-themes stay counted in their parent's rollup either way
+		subThemes := []ThemeResult{}
+		for _, sub := range t.SubThemes {
+			if themeSupport(sub) < config.MinSupport {
+				tail.add(sub)
+				continue
+			}
+			sub.Highlighted = isHighlighted(sub, config)
+			subThemes = append(subThemes, sub)
+		}
+		if len(t.SubThemes) > 0 {
+			t.SubThemes = subThemes
+		}
+		t.Highlighted = isHighlighted(t, config)
+		kept = append(kept, t)
+	}
+
+	if len(tail.Themes) == 0 {
+		return kept, nil
+	}
+	tail.ChangeRate = changeRate(tail.PreCount, tail.PostCount)
+	return kept, tail
+}
+
+// add moves a theme into the long tail; sub-themes are listed but not summed
+// because their parent's rollup already counts them
+func (b *LongTailBucket) add(t ThemeResult) {
+	b.Themes = append(b.Themes, t)
+	if t.Parent == "" {
+		b.PreCount += t.PreCount
+		b.PostCount += t.PostCount
+	}
+}
+
+// allThemes returns the reported themes followed by the long tail
+func allThemes(comparison ComparisonResult) []ThemeResult {
+	if comparison.LongTail == nil {
+		return comparison.Themes
+	}
+	return append(append([]ThemeResult{}, comparison.Themes...), comparison.LongTail.Themes...)
+}
+
+// themeSupport is the number of reviews mentioning a theme across both phases
+func themeSupport(t ThemeResult) int {
+	return t.PreCount + t.PostCount
+}
+
+// isHighlighted reports whether a theme changed by at least the configured rate
+func isHighlighted(t ThemeResult, config ThemeConfig) bool {
+	return math.Abs(t.ChangeRate) >= config.MinChangeRate
+}
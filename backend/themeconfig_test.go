@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyThemeThresholds(t *testing.T) {
+	themes := func() []ThemeResult {
+		return []ThemeResult{
+			{Theme: "Login", PreCount: 1, PostCount: 2, ChangeRate: 100},
+			{Theme: "Battery", PreCount: 10, PostCount: 12, ChangeRate: 20, SubThemes: []ThemeResult{
+				{Theme: "Drain", Parent: "Battery", PreCount: 8, PostCount: 11, ChangeRate: 37.5},
+				{Theme: "Charging", Parent: "Battery", PreCount: 1, PostCount: 0, ChangeRate: -100},
+			}},
+			{Theme: "Sync", PreCount: 5, PostCount: 4, ChangeRate: -20},
+			{Theme: "Pricing", PreCount: 0, PostCount: 1, ChangeRate: 100},
+		}
+	}
+	cases := []struct {
+		name   string
+		config ThemeConfig
+		kept   string
+		tail   string
+	}{
+		{"no limit", ThemeConfig{TopN: 0, MinSupport: 1}, "Battery,Sync,Login,Pricing", ""},
+		{"negative is no limit", ThemeConfig{TopN: -1, MinSupport: 1}, "Battery,Sync,Login,Pricing", ""},
+		{"top 2", ThemeConfig{TopN: 2, MinSupport: 1}, "Battery,Sync", "Login,Pricing"},
+		{"minimum support", ThemeConfig{TopN: 0, MinSupport: 3}, "Battery,Sync,Login", "Charging,Pricing"},
+	}
+	for _, c := range cases {
+		kept, tail := applyThemeThresholds(themes(), c.config)
+		var keptNames, tailNames []string
+		for _, theme := range kept {
+			keptNames = append(keptNames, theme.Theme)
+		}
+		if tail != nil {
+			for _, theme := range tail.Themes {
+				tailNames = append(tailNames, theme.Theme)
+			}
+		}
+		if got := strings.Join(keptNames, ","); got != c.kept {
+			t.Errorf("%s: kept %s, want %s", c.name, got, c.kept)
+		}
+		if got := strings.Join(tailNames, ","); got != c.tail {
+			t.Errorf("%s: long tail %s, want %s", c.name, got, c.tail)
+		}
+	}
+
+	// The long tail sums top-level themes only and highlights follow the change rate
+	kept, tail := applyThemeThresholds(themes(), ThemeConfig{TopN: 1, MinSupport: 2, MinChangeRate: 30})
+	if tail.PreCount != 6 || tail.PostCount != 7 {
+		t.Errorf("long tail counts %d/%d, want 6/7", tail.PreCount, tail.PostCount)
+	}
+	if kept[0].Highlighted || !kept[0].SubThemes[0].Highlighted {
+		t.Errorf("Battery highlighted %v and Drain %v, want only Drain", kept[0].Highlighted, kept[0].SubThemes[0].Highlighted)
+	}
+}
+
+func TestThemesPromptTopN(t *testing.T) {
+	prompts, err := NewPromptRegistry("")
+	if err != nil {
+		t.Fatalf("NewPromptRegistry: %v", err)
+	}
+	client := NewGroqClient("", GenerationConfig{Model: "stub"}, prompts)
+	for topN, want := range map[int]string{5: "Extract the top 5 top-level themes", 0: "Extract all the top-level themes", -1: "Extract all the top-level themes"} {
+		prompt, err := client.renderPrompt(promptThemes, map[string]interface{}{"PreReviews": "", "PostReviews": "", "Taxonomy": "", "TopN": topN})
+		if err != nil {
+			t.Fatalf("render: %v", err)
+		}
+		if !strings.Contains(prompt, want) {
+			t.Errorf("top_n %d: prompt does not say %q", topN, want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenUsage counts the tokens consumed by one or more provider calls
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add returns the sum of two usages
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// ModelPrice is the USD price per million tokens for a model
+type ModelPrice struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+}
+
+// PriceTable maps model names to prices
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable returns Groq's published on-demand prices for the models we use
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		"llama-3.3-70b-versatile": {PromptPerMillion: 0.59, CompletionPerMillion: 0.79},
+		"llama-3.1-8b-instant":    {PromptPerMillion: 0.05, CompletionPerMillion: 0.08},
+	}
+}
+
+// LoadPriceTable returns the default prices overlaid with any from the PRICE_TABLE JSON file
+func LoadPriceTable() (PriceTable, error) {
+	prices := DefaultPriceTable()
+	path := getEnv("PRICE_TABLE", "")
+	if path == "" {
+		return prices, nil
+	}
+	overrides := PriceTable{}
+	if err := loadJSONFile(path, &overrides); err != nil {
+		return nil, err
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+// Cost estimates the USD cost of usage on a model; unknown models cost 0
+func (p PriceTable) Cost(model string, usage TokenUsage) float64 {
+	price, ok := p[model]
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.PromptPerMillion +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion) / 1e6
+}
+
+// UsageSummary aggregates token usage and estimated cost
+type UsageSummary struct {
+	Calls   int        `json:"calls"`
+	Usage   TokenUsage `json:"usage"`
+	CostUSD float64    `json:"cost_usd"`
+}
+
+// UsageEntry is one analysis recorded in the usage ledger
+type UsageEntry struct {
+	DatasetID  string       `json:"dataset_id"`
+	APIKeyID   string       `json:"api_key_id,omitempty"`
+	Day        string       `json:"day"` // UTC, YYYY-MM-DD
+	RecordedAt string       `json:"recorded_at"`
+	Summary    UsageSummary `json:"summary"`
+}
+
+// UsageTotal is an aggregate over ledger entries sharing a key
+type UsageTotal struct {
+	Key      string       `json:"key"`
+	Analyses int          `json:"analyses"`
+	Summary  UsageSummary `json:"summary"`
+}
+
+// UsageReport is returned by the usage endpoint
+type UsageReport struct {
+	Total     UsageSummary `json:"total"`
+	ByDataset []UsageTotal `json:"by_dataset"`
+	ByDay     []UsageTotal `json:"by_day"`
+	ByAPIKey  []UsageTotal `json:"by_api_key"`
+}
+
+// UsageLedger prices provider calls and keeps a persistent record of spend.
+// Runs in progress hold their estimated cost as reservations, which count as
+// spend until the run is recorded or fails
+type UsageLedger struct {
+	mu           sync.Mutex
+	path         string
+	prices       PriceTable
+	entries      []UsageEntry
+	reservations map[*Reservation]bool
+}
+
+// Reservation is the estimated cost of a run in progress, held against the budgets
+type Reservation struct {
+	ledger   *UsageLedger
+	apiKeyID string
+	day      string
+	costUSD  float64
+}
+
+// Release drops the reservation of a run that will not be recorded; it is a
+// no-op on nil and on a reservation already settled
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	r.ledger.mu.Lock()
+	defer r.ledger.mu.Unlock()
+	delete(r.ledger.reservations, r)
+}
+
+// NewUsageLedger creates a ledger persisted at path ("" keeps it in memory)
+func NewUsageLedger(path string, prices PriceTable) (*UsageLedger, error) {
+	l := &UsageLedger{path: path, prices: prices}
+	if err := loadJSONFile(path, &l.entries); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Summarize prices each call in place and returns their aggregate
+func (l *UsageLedger) Summarize(calls []LLMCall) UsageSummary {
+	summary := UsageSummary{}
+	for i := range calls {
+		calls[i].CostUSD = l.prices.Cost(calls[i].Model, calls[i].Usage)
+		summary.Calls++
+		summary.Usage = summary.Usage.Add(calls[i].Usage)
+		summary.CostUSD += calls[i].CostUSD
+	}
+	return summary
+}
+
+// Record appends an analysis' usage to the ledger, settling its reservation
+// (nil when none was made) in the same step
+func (l *UsageLedger) Record(datasetID, apiKeyID string, summary UsageSummary, reservation *Reservation) {
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.reservations, reservation)
+	l.entries = append(l.entries, UsageEntry{
+		DatasetID:  datasetID,
+		APIKeyID:   apiKeyID,
+		Day:        now.Format("2006-01-02"),
+		RecordedAt: now.Format(time.RFC3339),
+		Summary:    summary,
+	})
+	if err := saveJSONFile(l.path, l.entries); err != nil {
+		log.Printf("⚠️ Failed to persist usage ledger: %v", err)
+	}
+}
+
+// Report aggregates the ledger per dataset, per day and per API key
+func (l *UsageLedger) Report() UsageReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := UsageReport{}
+	byDataset := make(map[string]*UsageTotal)
+	byDay := make(map[string]*UsageTotal)
+	byKey := make(map[string]*UsageTotal)
+	for _, e := range l.entries {
+		report.Total = addSummary(report.Total, e.Summary)
+		addTotal(byDataset, e.DatasetID, e.Summary)
+		addTotal(byDay, e.Day, e.Summary)
+		if e.APIKeyID != "" {
+			addTotal(byKey, e.APIKeyID, e.Summary)
+		}
+	}
+	report.ByDataset = sortedTotals(byDataset)
+	report.ByDay = sortedTotals(byDay)
+	report.ByAPIKey = sortedTotals(byKey)
+	return report
+}
+
+// spend totals a day's entries and reservations, for one API key unless
+// apiKeyID is ""; the caller holds the lock
+func (l *UsageLedger) spend(apiKeyID, day string) float64 {
+	total := 0.0
+	for _, e := range l.entries {
+		if e.Day == day && (apiKeyID == "" || e.APIKeyID == apiKeyID) {
+			total += e.Summary.CostUSD
+		}
+	}
+	for r := range l.reservations {
+		if r.day == day && (apiKeyID == "" || r.apiKeyID == apiKeyID) {
+			total += r.costUSD
+		}
+	}
+	return total
+}
+
+// reserve holds a run's estimated cost; the caller holds the lock
+func (l *UsageLedger) reserve(apiKeyID, day string, costUSD float64) *Reservation {
+	if l.reservations == nil {
+		l.reservations = make(map[*Reservation]bool)
+	}
+	r := &Reservation{ledger: l, apiKeyID: apiKeyID, day: day, costUSD: costUSD}
+	l.reservations[r] = true
+	return r
+}
+
+func addSummary(a, b UsageSummary) UsageSummary {
+	return UsageSummary{
+		Calls:   a.Calls + b.Calls,
+		Usage:   a.Usage.Add(b.Usage),
+		CostUSD: a.CostUSD + b.CostUSD,
+	}
+}
+
+func addTotal(totals map[string]*UsageTotal, key string, summary UsageSummary) {
+	t, ok := totals[key]
+	if !ok {
+		t = &UsageTotal{Key: key}
+		totals[key] = t
+	}
+	t.Analyses++
+	t.Summary = addSummary(t.Summary, summary)
+}
+
+func sortedTotals(totals map[string]*UsageTotal) []UsageTotal {
+	list := make([]UsageTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// datasetID identifies an uploaded pre/post dataset pair by its content
+func datasetID(preReviews, postReviews []Review) string {
+	h := sha256.New()
+	for _, set := range [][]Review{preReviews, postReviews} {
+		for _, r := range set {
+			fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00", r.ID, r.Date, r.ReviewText, r.Rating)
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
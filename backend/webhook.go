@@ -0,0 +1,593 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook events
+const (
+	eventAnalysisCompleted = "analysis.completed"
+	eventAnalysisFailed    = "analysis.failed"
+	eventPolicyBreached    = "policy.breached" // the regression policy warned or failed
+	eventWebhookTest       = "webhook.test"
+)
+
+// webhookEvents are the events a subscription may select
+var webhookEvents = []string{eventAnalysisCompleted, eventAnalysisFailed, eventPolicyBreached}
+
+// Payload formats
+const (
+	webhookJSON  = "json"  // versioned WebhookPayload
+	webhookSlack = "slack" // Slack incoming-webhook message
+)
+
+// webhookSchemaVersion is bumped whenever WebhookPayload changes incompatibly
+const webhookSchemaVersion = "1"
+
+// Delivery states
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+// Webhook request headers
+const (
+	headerWebhookSignature = "X-Analyzer-Signature" // t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">
+	headerWebhookEvent     = "X-Analyzer-Event"
+	headerWebhookDelivery  = "X-Analyzer-Delivery"
+	headerWebhookSchema    = "X-Analyzer-Schema-Version"
+)
+
+// WebhookConfig controls delivery retries
+type WebhookConfig struct {
+	MaxAttempts    int           `json:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff"` // doubled after every failed attempt
+	MaxBackoff     time.Duration `json:"max_backoff"`
+	Timeout        time.Duration `json:"timeout"`   // per attempt
+	LogLimit       int           `json:"log_limit"` // deliveries kept in the log
+}
+
+// LoadWebhookConfig reads webhook delivery settings from the environment
+func LoadWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		InitialBackoff: time.Duration(getEnvInt("WEBHOOK_BACKOFF_MS", 1000)) * time.Millisecond,
+		MaxBackoff:     time.Duration(getEnvInt("WEBHOOK_MAX_BACKOFF_MS", 60000)) * time.Millisecond,
+		Timeout:        time.Duration(getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+		LogLimit:       getEnvInt("WEBHOOK_LOG_LIMIT", 500),
+	}
+}
+
+// WebhookSubscription sends the selected events to a URL
+type WebhookSubscription struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`           // empty selects every event
+	Format      string   `json:"format,omitempty"` // "json" (default) or "slack"
+	Secret      string   `json:"secret,omitempty"` // HMAC key; generated when empty and only returned on creation
+	Description string   `json:"description,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// wants reports whether the subscription selects an event
+func (s WebhookSubscription) wants(event string) bool {
+	return len(s.Events) == 0 || containsString(s.Events, event) || event == eventWebhookTest
+}
+
+// WebhookPayload is the generic JSON body. Fields are only added within a
+// schema version; removing or changing one bumps SchemaVersion
+type WebhookPayload struct {
+	SchemaVersion string      `json:"schema_version"`
+	ID            string      `json:"id"` // unique per event, shared by its deliveries
+	Event         string      `json:"event"`
+	OccurredAt    string      `json:"occurred_at"`
+	Data          WebhookData `json:"data"`
+}
+
+// WebhookData summarises the analysis behind an event
+type WebhookData struct {
+	DatasetID      string         `json:"dataset_id,omitempty"`
+	AnalyzedAt     string         `json:"analyzed_at,omitempty"`
+	PreCount       int            `json:"pre_count"`
+	PostCount      int            `json:"post_count"`
+	SuccessScore   float64        `json:"success_score"`
+	OverallSuccess bool           `json:"overall_success"`
+	SentimentShift float64        `json:"sentiment_shift"`
+	Summary        string         `json:"summary,omitempty"`
+	NewIssues      []WebhookIssue `json:"new_issues"`
+	Policy         *PolicyResult  `json:"policy,omitempty"`
+	Error          string         `json:"error,omitempty"`  // set for analysis.failed
+	Source         string         `json:"source,omitempty"` // what triggered the analysis, e.g. "api" or "cli"
+}
+
+// WebhookIssue is an emerging issue in a payload
+type WebhookIssue struct {
+	Theme    string  `json:"theme"`
+	Level    string  `json:"level"`
+	Severity float64 `json:"severity"`
+}
+
+// WebhookDelivery records the attempts to send one event to one subscription
+type WebhookDelivery struct {
+	ID             string            `json:"id"`
+	SubscriptionID string            `json:"subscription_id"`
+	URL            string            `json:"url"`
+	Event          string            `json:"event"`
+	PayloadID      string            `json:"payload_id"`
+	Status         string            `json:"status"` // pending, delivered or failed
+	Attempts       []DeliveryAttempt `json:"attempts"`
+	CreatedAt      string            `json:"created_at"`
+}
+
+// DeliveryAttempt is one HTTP request of a delivery
+type DeliveryAttempt struct {
+	At         string `json:"at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// WebhookNotifier stores subscriptions and delivers events to them in the background
+type WebhookNotifier struct {
+	mu            sync.Mutex
+	path          string
+	logPath       string
+	config        WebhookConfig
+	client        *http.Client
+	subscriptions []WebhookSubscription
+	deliveries    []WebhookDelivery // oldest first, capped at config.LogLimit
+	pending       sync.WaitGroup
+}
+
+// NewWebhookNotifier loads subscriptions from path and the delivery log from
+// logPath ("" keeps either in memory)
+func NewWebhookNotifier(path, logPath string, config WebhookConfig) (*WebhookNotifier, error) {
+	n := &WebhookNotifier{
+		path:    path,
+		logPath: logPath,
+		config:  config,
+		client:  &http.Client{Timeout: config.Timeout},
+	}
+	if err := loadJSONFile(path, &n.subscriptions); err != nil {
+		return nil, err
+	}
+	if err := loadJSONFile(logPath, &n.deliveries); err != nil {
+		return nil, err
+	}
+
+	// Deliveries in flight at a restart lost their retries; the body is not
+	// kept, so they cannot be resumed
+	interrupted := false
+	for i := range n.deliveries {
+		if n.deliveries[i].Status == deliveryPending {
+			n.deliveries[i].Status = deliveryFailed
+			n.deliveries[i].Attempts = append(n.deliveries[i].Attempts, DeliveryAttempt{
+				At:    time.Now().UTC().Format(time.RFC3339),
+				Error: "interrupted by a server restart",
+			})
+			interrupted = true
+		}
+	}
+	if interrupted {
+		if err := saveJSONFile(logPath, n.deliveries); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// List returns the subscriptions without their secrets
+func (n *WebhookNotifier) List() []WebhookSubscription {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	subs := make([]WebhookSubscription, len(n.subscriptions))
+	for i, s := range n.subscriptions {
+		s.Secret = ""
+		subs[i] = s
+	}
+	return subs
+}
+
+// Subscribe validates and stores a subscription, returning it with its secret
+func (n *WebhookNotifier) Subscribe(s WebhookSubscription) (WebhookSubscription, error) {
+	target, err := url.Parse(strings.TrimSpace(s.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return s, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	s.URL = target.String()
+	s.Format = strings.ToLower(strings.TrimSpace(s.Format))
+	if s.Format == "" {
+		s.Format = webhookJSON
+	}
+	if s.Format != webhookJSON && s.Format != webhookSlack {
+		return s, fmt.Errorf("format must be %s or %s", webhookJSON, webhookSlack)
+	}
+	for _, event := range s.Events {
+		if !containsString(webhookEvents, event) {
+			return s, fmt.Errorf("unknown event %q; expected one of %s", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+	if s.Events == nil {
+		s.Events = []string{}
+	}
+	if s.Secret == "" {
+		if s.Secret, err = randomHex(24); err != nil {
+			return s, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+	}
+	if s.ID, err = randomHex(8); err != nil {
+		return s, fmt.Errorf("failed to generate webhook id: %w", err)
+	}
+	s.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subscriptions = append(n.subscriptions, s)
+	return s, saveJSONFile(n.path, n.subscriptions)
+}
+
+// Unsubscribe removes a subscription by ID
+func (n *WebhookNotifier) Unsubscribe(id string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, s := range n.subscriptions {
+		if s.ID == id {
+			n.subscriptions = append(n.subscriptions[:i], n.subscriptions[i+1:]...)
+			return saveJSONFile(n.path, n.subscriptions)
+		}
+	}
+	return fmt.Errorf("webhook %q not found", id)
+}
+
+// Deliveries returns the delivery log, newest first, optionally for one subscription
+func (n *WebhookNotifier) Deliveries(subscriptionID string) []WebhookDelivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	deliveries := []WebhookDelivery{}
+	for i := len(n.deliveries) - 1; i >= 0; i-- {
+		if subscriptionID == "" || n.deliveries[i].SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, n.deliveries[i])
+		}
+	}
+	return deliveries
+}
+
+// NotifyResult sends analysis.completed, plus policy.breached when the policy
+// did not pass
+func (n *WebhookNotifier) NotifyResult(result *AnalysisResult, source string) {
+	data := webhookData(result)
+	data.Source = source
+	n.notify(eventAnalysisCompleted, data)
+	if result.Policy != nil && result.Policy.Status != policyPass {
+		n.notify(eventPolicyBreached, data)
+	}
+}
+
+// NotifyFailure sends analysis.failed. Runs refused by the budget or for an
+// unknown API key never started, so they are not reported as failures
+func (n *WebhookNotifier) NotifyFailure(err error, preCount, postCount int, source string) {
+	var budgetErr *BudgetExceededError
+	if errors.As(err, &budgetErr) || errors.Is(err, ErrBudgetUnpriced) || errors.Is(err, ErrUnknownAPIKey) {
+		return
+	}
+	n.notify(eventAnalysisFailed, WebhookData{
+		PreCount:  preCount,
+		PostCount: postCount,
+		NewIssues: []WebhookIssue{},
+		Error:     err.Error(),
+		Source:    source,
+	})
+}
+
+// Test sends a webhook.test event to one subscription
+func (n *WebhookNotifier) Test(id string) error {
+	n.mu.Lock()
+	var target *WebhookSubscription
+	for i := range n.subscriptions {
+		if n.subscriptions[i].ID == id {
+			s := n.subscriptions[i]
+			target = &s
+		}
+	}
+	n.mu.Unlock()
+	if target == nil {
+		return fmt.Errorf("webhook %q not found", id)
+	}
+	payload, err := newWebhookPayload(eventWebhookTest, WebhookData{NewIssues: []WebhookIssue{}, Summary: "Test event"})
+	if err != nil {
+		return err
+	}
+	n.dispatch(*target, payload)
+	return nil
+}
+
+// Wait blocks until in-flight deliveries finish, so the CLI can exit safely
+func (n *WebhookNotifier) Wait() {
+	n.pending.Wait()
+}
+
+// notify delivers an event to every subscription that selects it
+func (n *WebhookNotifier) notify(event string, data WebhookData) {
+	n.mu.Lock()
+	var targets []WebhookSubscription
+	for _, s := range n.subscriptions {
+		if s.wants(event) {
+			targets = append(targets, s)
+		}
+	}
+	n.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	payload, err := newWebhookPayload(event, data)
+	if err != nil {
+		log.Printf("⚠️  Webhook %s not sent: %v", event, err)
+		return
+	}
+	for _, s := range targets {
+		n.dispatch(s, payload)
+	}
+}
+
+// dispatch records a pending delivery and sends it in the background
+func (n *WebhookNotifier) dispatch(s WebhookSubscription, payload WebhookPayload) {
+	body, err := encodeWebhookBody(s.Format, payload)
+	if err != nil {
+		log.Printf("⚠️  Webhook %s not sent to %s: %v", payload.Event, s.ID, err)
+		return
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		log.Printf("⚠️  Webhook %s not sent to %s: %v", payload.Event, s.ID, err)
+		return
+	}
+	delivery := WebhookDelivery{
+		ID:             id,
+		SubscriptionID: s.ID,
+		URL:            s.URL,
+		Event:          payload.Event,
+		PayloadID:      payload.ID,
+		Status:         deliveryPending,
+		Attempts:       []DeliveryAttempt{},
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
+	}
+	n.record(delivery)
+
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		n.deliver(s, delivery, body)
+	}()
+}
+
+// deliver POSTs the body until it is accepted, a non-retryable status is
+// returned or the attempts run out
+func (n *WebhookNotifier) deliver(s WebhookSubscription, delivery WebhookDelivery, body []byte) {
+	maxAttempts := n.config.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		result, retryAfter := n.attempt(s, delivery, body)
+		delivery.Attempts = append(delivery.Attempts, result)
+
+		retryable := result.StatusCode == 0 || result.StatusCode == http.StatusTooManyRequests || result.StatusCode >= 500
+		switch {
+		case result.StatusCode >= 200 && result.StatusCode < 300:
+			delivery.Status = deliveryDelivered
+		case !retryable || attempt >= maxAttempts:
+			delivery.Status = deliveryFailed
+			log.Printf("⚠️  Webhook %s to %s failed after %d attempts", delivery.Event, s.URL, attempt)
+		}
+		n.record(delivery)
+		if delivery.Status != deliveryPending {
+			return
+		}
+
+		wait := n.backoff(attempt)
+		if retryAfter > wait && retryAfter <= n.config.MaxBackoff {
+			wait = retryAfter
+		}
+		time.Sleep(wait)
+	}
+}
+
+// attempt makes one signed request, returning its outcome and any Retry-After delay
+func (n *WebhookNotifier) attempt(s WebhookSubscription, delivery WebhookDelivery, body []byte) (DeliveryAttempt, time.Duration) {
+	start := time.Now()
+	result := DeliveryAttempt{At: start.UTC().Format(time.RFC3339)}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, 0
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "launch-analyzer-webhooks/"+webhookSchemaVersion)
+	req.Header.Set(headerWebhookEvent, delivery.Event)
+	req.Header.Set(headerWebhookDelivery, delivery.ID)
+	req.Header.Set(headerWebhookSchema, webhookSchemaVersion)
+	req.Header.Set(headerWebhookSignature, signWebhook(s.Secret, start.Unix(), body))
+
+	resp, err := n.client.Do(req)
+	result.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, 0
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = resp.Status
+	}
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return result, retryAfter
+}
+
+// backoff is the wait after the given failed attempt: the initial backoff,
+// doubled per attempt and capped at the maximum
+func (n *WebhookNotifier) backoff(attempt int) time.Duration {
+	wait := n.config.InitialBackoff
+	for i := 1; i < attempt && wait < n.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if n.config.MaxBackoff > 0 && wait > n.config.MaxBackoff {
+		wait = n.config.MaxBackoff
+	}
+	return wait
+}
+
+// record inserts or updates a delivery in the log and persists it
+func (n *WebhookNotifier) record(delivery WebhookDelivery) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delivery.Attempts = append([]DeliveryAttempt{}, delivery.Attempts...)
+	found := false
+	for i := range n.deliveries {
+		if n.deliveries[i].ID == delivery.ID {
+			n.deliveries[i] = delivery
+			found = true
+			break
+		}
+	}
+	if !found {
+		n.deliveries = append(n.deliveries, delivery)
+		if limit := n.config.LogLimit; limit > 0 && len(n.deliveries) > limit {
+			n.deliveries = append([]WebhookDelivery{}, n.deliveries[len(n.deliveries)-limit:]...)
+		}
+	}
+	if err := saveJSONFile(n.logPath, n.deliveries); err != nil {
+		log.Printf("⚠️  Failed to save webhook delivery log: %v", err)
+	}
+}
+
+// signWebhook signs "<timestamp>.<body>" so receivers can reject replays
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// newWebhookPayload wraps event data in the versioned envelope
+func newWebhookPayload(event string, data WebhookData) (WebhookPayload, error) {
+	id, err := randomHex(12)
+	if err != nil {
+		return WebhookPayload{}, fmt.Errorf("failed to generate event id: %w", err)
+	}
+	return WebhookPayload{
+		SchemaVersion: webhookSchemaVersion,
+		ID:            "evt_" + id,
+		Event:         event,
+		OccurredAt:    time.Now().UTC().Format(time.RFC3339),
+		Data:          data,
+	}, nil
+}
+
+// webhookData summarises a result for a payload
+func webhookData(result *AnalysisResult) WebhookData {
+	data := WebhookData{
+		DatasetID:      result.Metadata.DatasetID,
+		AnalyzedAt:     result.AnalyzedAt,
+		PreCount:       result.PreLaunchReviews.Count,
+		PostCount:      result.PostLaunchReviews.Count,
+		SuccessScore:   result.Impact.SuccessScore,
+		OverallSuccess: result.Impact.OverallSuccess,
+		SentimentShift: result.Comparison.SentimentShift,
+		Summary:        result.Impact.ExecutiveSummary,
+		NewIssues:      []WebhookIssue{},
+		Policy:         result.Policy,
+	}
+	for _, issue := range result.NewIssues {
+		data.NewIssues = append(data.NewIssues, WebhookIssue{Theme: issue.Theme, Level: issue.Level, Severity: issue.Severity})
+	}
+	return data
+}
+
+// encodeWebhookBody renders the payload in the subscription's format
+func encodeWebhookBody(format string, payload WebhookPayload) ([]byte, error) {
+	if format == webhookSlack {
+		return json.Marshal(slackMessage(payload))
+	}
+	return json.Marshal(payload)
+}
+
+// slackMessage formats a payload for a Slack incoming webhook; the text field
+// is the fallback for notifications and clients without Block Kit
+func slackMessage(payload WebhookPayload) map[string]interface{} {
+	data := payload.Data
+	var title, detail string
+	switch payload.Event {
+	case eventAnalysisCompleted:
+		verdict := "needs improvement"
+		if data.OverallSuccess {
+			verdict = "successful"
+		}
+		title = fmt.Sprintf("Launch analysis complete: %s (score %.0f/100)", verdict, data.SuccessScore)
+		detail = fmt.Sprintf("*Reviews:* %d pre-launch, %d post-launch\n*Sentiment shift:* %+.1f pts\n*New issues:* %d",
+			data.PreCount, data.PostCount, data.SentimentShift, len(data.NewIssues))
+	case eventPolicyBreached:
+		title = fmt.Sprintf("Launch regression policy %s", data.Policy.Status)
+		var lines []string
+		for _, rule := range data.Policy.Rules {
+			if rule.Status != policyPass {
+				lines = append(lines, fmt.Sprintf("• *%s* (%s): %s", rule.Rule, rule.Status, rule.Reason))
+			}
+		}
+		detail = strings.Join(lines, "\n")
+	case eventAnalysisFailed:
+		title = "Launch analysis failed"
+		detail = fmt.Sprintf("*Reviews:* %d pre-launch, %d post-launch\n*Error:* %s", data.PreCount, data.PostCount, data.Error)
+	default:
+		title = "Launch analyzer webhook test"
+		detail = "This subscription is receiving events."
+	}
+
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": title}},
+	}
+	if detail != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": detail}})
+	}
+	context := fmt.Sprintf("%s · %s", payload.Event, payload.OccurredAt)
+	if data.DatasetID != "" {
+		context = fmt.Sprintf("Dataset %s · %s", data.DatasetID, context)
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": context}},
+	})
+	return map[string]interface{}{"text": title, "blocks": blocks}
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
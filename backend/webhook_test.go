@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// verifyWebhook checks a signature header the way a receiver would
+func verifyWebhook(secret, header string, body []byte) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		return fmt.Errorf("bad timestamp in %q", header)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	want, _ := hex.DecodeString(signature)
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return fmt.Errorf("signature mismatch in %q", header)
+	}
+	return nil
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"analysis.completed"}`)
+	header := signWebhook("shh", 1700000000, body)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") || len(header) != len("t=1700000000,v1=")+64 {
+		t.Fatalf("header = %q, want t=<unix>,v1=<64 hex digits>", header)
+	}
+	if err := verifyWebhook("shh", header, body); err != nil {
+		t.Fatal(err)
+	}
+	if verifyWebhook("other", header, body) == nil || verifyWebhook("shh", header, append(body, ' ')) == nil {
+		t.Fatal("a wrong secret or altered body verified")
+	}
+	if signWebhook("shh", 1700000001, body) == header {
+		t.Fatal("the timestamp is not signed")
+	}
+}
+
+// webhookStandIn answers deliveries with the scripted statuses in turn and
+// records the signed bodies it accepted
+type webhookStandIn struct {
+	mu       sync.Mutex
+	statuses []int
+	times    []time.Time
+	bodies   []string
+}
+
+func (s *webhookStandIn) serve(t *testing.T, secret *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifyWebhook(*secret, r.Header.Get(headerWebhookSignature), body); err != nil {
+			t.Errorf("delivery: %v", err)
+		}
+		if r.Header.Get(headerWebhookDelivery) == "" || r.Header.Get(headerWebhookSchema) != webhookSchemaVersion {
+			t.Errorf("headers = %v", r.Header)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.times = append(s.times, time.Now())
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		if status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(status)
+	})
+}
+
+func newTestNotifier(t *testing.T, config WebhookConfig, statuses ...int) (*WebhookNotifier, *webhookStandIn, string) {
+	t.Helper()
+	standIn := &webhookStandIn{statuses: statuses}
+	var secret string
+	srv := httptest.NewServer(standIn.serve(t, &secret))
+	t.Cleanup(srv.Close)
+
+	n, err := NewWebhookNotifier("", "", config)
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	sub, err := n.Subscribe(WebhookSubscription{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	secret = sub.Secret
+	return n, standIn, sub.ID
+}
+
+func TestWebhookRetryDecisions(t *testing.T) {
+	fast := WebhookConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Timeout: 5 * time.Second}
+	cases := []struct {
+		name     string
+		statuses []int
+		status   string
+		attempts int
+	}{
+		{"accepted", []int{http.StatusNoContent}, deliveryDelivered, 1},
+		{"client error is final", []int{http.StatusBadRequest}, deliveryFailed, 1},
+		{"gone is final", []int{http.StatusGone}, deliveryFailed, 1},
+		{"rate limited then accepted", []int{http.StatusTooManyRequests, http.StatusOK}, deliveryDelivered, 2},
+		{"server errors until attempts run out", []int{500, 502, 500, 200}, deliveryFailed, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n, standIn, id := newTestNotifier(t, fast, c.statuses...)
+			if err := n.Test(id); err != nil {
+				t.Fatalf("Test: %v", err)
+			}
+			n.Wait()
+			deliveries := n.Deliveries(id)
+			if len(deliveries) != 1 {
+				t.Fatalf("got %d deliveries", len(deliveries))
+			}
+			d := deliveries[0]
+			if d.Status != c.status || len(d.Attempts) != c.attempts || len(standIn.bodies) != c.attempts {
+				t.Fatalf("delivery = %s after %d attempts (%d requests), want %s after %d", d.Status, len(d.Attempts), len(standIn.bodies), c.status, c.attempts)
+			}
+			var payload WebhookPayload
+			if err := json.Unmarshal([]byte(standIn.bodies[0]), &payload); err != nil || payload.Event != eventWebhookTest || payload.ID != d.PayloadID {
+				t.Fatalf("payload = %+v (%v), want the test event of delivery %s", payload, err, d.PayloadID)
+			}
+		})
+	}
+}
+
+func TestWebhookHonoursRetryAfter(t *testing.T) {
+	config := WebhookConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Second, Timeout: 5 * time.Second}
+	n, standIn, id := newTestNotifier(t, config, http.StatusServiceUnavailable, http.StatusOK)
+	n.Test(id)
+	n.Wait()
+	if len(standIn.times) != 2 {
+		t.Fatalf("got %d requests, want a retry", len(standIn.times))
+	}
+	if wait := standIn.times[1].Sub(standIn.times[0]); wait < time.Second {
+		t.Fatalf("retried after %v, want the 1s Retry-After", wait)
+	}
+
+	// A Retry-After beyond the maximum backoff is not honoured
+	config.MaxBackoff = 10 * time.Millisecond
+	n, standIn, id = newTestNotifier(t, config, http.StatusServiceUnavailable, http.StatusOK)
+	n.Test(id)
+	n.Wait()
+	if wait := standIn.times[1].Sub(standIn.times[0]); wait >= time.Second {
+		t.Fatalf("retried after %v, want the capped backoff", wait)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	n := &WebhookNotifier{config: WebhookConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := n.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := n.backoff(200); got != 5*time.Second {
+		t.Errorf("backoff(200) = %v, want the cap", got)
+	}
+}
+
+func TestWebhookLogLimit(t *testing.T) {
+	n := &WebhookNotifier{config: WebhookConfig{LogLimit: 3}}
+	for i := 0; i < 5; i++ {
+		n.record(WebhookDelivery{ID: fmt.Sprint(i), Status: deliveryPending})
+	}
+	n.record(WebhookDelivery{ID: "4", Status: deliveryDelivered})
+	var ids []string
+	for _, d := range n.Deliveries("") {
+		ids = append(ids, d.ID+":"+d.Status)
+	}
+	if got := strings.Join(ids, ","); got != "4:delivered,3:pending,2:pending" {
+		t.Fatalf("log = %s, want the three newest with updates applied in place", got)
+	}
+}
+
+func TestWebhookPendingDeliveriesFailOnRestart(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "deliveries.json")
+	if err := saveJSONFile(logPath, []WebhookDelivery{
+		{ID: "a", Status: deliveryPending, Attempts: []DeliveryAttempt{{StatusCode: 500}}},
+		{ID: "b", Status: deliveryDelivered},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewWebhookNotifier("", logPath, WebhookConfig{})
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	deliveries := n.Deliveries("")
+	if deliveries[1].Status != deliveryFailed || len(deliveries[1].Attempts) != 2 || deliveries[0].Status != deliveryDelivered {
+		t.Fatalf("deliveries = %+v, want the pending one failed", deliveries)
+	}
+
+	var saved []WebhookDelivery
+	if err := loadJSONFile(logPath, &saved); err != nil || saved[0].Status != deliveryFailed {
+		t.Fatalf("saved log = %+v (%v), want the failure persisted", saved, err)
+	}
+}
+
+func TestNotifyFailureSkipsRefusedRuns(t *testing.T) {
+	n, standIn, _ := newTestNotifier(t, WebhookConfig{MaxAttempts: 1, Timeout: 5 * time.Second})
+	n.NotifyFailure(&BudgetExceededError{Decision: BudgetDecision{Action: budgetRefused}}, 1, 1, "api")
+	n.NotifyFailure(fmt.Errorf("estimate: %w", ErrUnknownAPIKey), 1, 1, "api")
+	n.NotifyFailure(ErrBudgetUnpriced, 1, 1, "api")
+	n.Wait()
+	if len(standIn.bodies) != 0 {
+		t.Fatalf("refused runs sent %d analysis.failed events", len(standIn.bodies))
+	}
+
+	n.NotifyFailure(errors.New("provider returned 500"), 1, 1, "api")
+	n.Wait()
+	if len(standIn.bodies) != 1 || !strings.Contains(standIn.bodies[0], eventAnalysisFailed) {
+		t.Fatalf("bodies = %v, want one analysis.failed event", standIn.bodies)
+	}
+}
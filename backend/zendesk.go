@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// ZendeskConfig reads tickets through the incremental ticket export API
+type ZendeskConfig struct {
+	Subdomain string `json:"subdomain"` // <subdomain>.zendesk.com
+	Email     string `json:"email"`     // agent the API token belongs to
+	APIToken  string `json:"api_token"` // e.g. "${JOB_SECRET_ZENDESK_TOKEN}"
+}
+
+// zendeskConnector maps tickets to reviews: the subject and description are
+// the text, and a satisfaction rating of good or bad becomes 5 or 1 stars.
+// The cursor is the export API's after_cursor
+type zendeskConnector struct {
+	config  ConnectorConfig
+	baseURL string
+	client  *http.Client
+}
+
+// zendeskSubdomain guards the subdomain, which becomes part of the API host
+var zendeskSubdomain = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+func newZendeskConnector(config ConnectorConfig, client *http.Client) (*zendeskConnector, error) {
+	settings := config.Zendesk
+	if settings.Subdomain == "" && config.BaseURL == "" {
+		return nil, fmt.Errorf("zendesk needs a subdomain")
+	}
+	if settings.Subdomain != "" && !zendeskSubdomain.MatchString(settings.Subdomain) {
+		return nil, fmt.Errorf("invalid zendesk subdomain %q", settings.Subdomain)
+	}
+	if settings.Email == "" || settings.APIToken == "" {
+		return nil, fmt.Errorf("zendesk needs an email and api_token")
+	}
+	return &zendeskConnector{
+		config:  config,
+		baseURL: connectorBaseURL(config, "https://"+settings.Subdomain+".zendesk.com"),
+		client:  client,
+	}, nil
+}
+
+// zendeskTicketPage is one page of the incremental ticket export
+type zendeskTicketPage struct {
+	Tickets []struct {
+		ID                 int64  `json:"id"`
+		CreatedAt          string `json:"created_at"`
+		Subject            string `json:"subject"`
+		Description        string `json:"description"`
+		RequesterID        int64  `json:"requester_id"`
+		Status             string `json:"status"`
+		SatisfactionRating *struct {
+			Score string `json:"score"` // good, bad, offered or unoffered
+		} `json:"satisfaction_rating"`
+	} `json:"tickets"`
+	AfterCursor string `json:"after_cursor"`
+	EndOfStream bool   `json:"end_of_stream"`
+}
+
+// Sync pages through tickets updated since the cursor
+func (z *zendeskConnector) Sync(cursor string) ([]Review, string, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	} else {
+		query.Set("start_time", strconv.FormatInt(z.config.sinceUnix(), 10))
+	}
+
+	var reviews []Review
+	for page := 0; page < connectorMaxPages; page++ {
+		req, err := http.NewRequest(http.MethodGet, z.baseURL+"/api/v2/incremental/tickets/cursor.json?"+query.Encode(), nil)
+		if err != nil {
+			return nil, cursor, err
+		}
+		req.SetBasicAuth(expandEnv(z.config.Zendesk.Email)+"/token", expandEnv(z.config.Zendesk.APIToken))
+
+		var body zendeskTicketPage
+		if _, err := doConnectorRequest(z.client, req, &body); err != nil {
+			return nil, cursor, err
+		}
+		for _, t := range body.Tickets {
+			if t.Status == "deleted" {
+				continue
+			}
+			rating := 0
+			if t.SatisfactionRating != nil {
+				switch t.SatisfactionRating.Score {
+				case "good":
+					rating = 5
+				case "bad":
+					rating = 1
+				}
+			}
+			reviews = append(reviews, Review{
+				ID:         "zendesk-" + strconv.FormatInt(t.ID, 10),
+				Date:       normalizeDate(t.CreatedAt),
+				UserID:     strconv.FormatInt(t.RequesterID, 10),
+				ReviewText: joinText(t.Subject, t.Description),
+				Rating:     rating,
+				Source:     z.config.source(),
+			})
+		}
+		if body.AfterCursor != "" {
+			cursor = body.AfterCursor
+		}
+		if body.EndOfStream || body.AfterCursor == "" {
+			return reviews, cursor, nil
+		}
+		query = url.Values{"cursor": {body.AfterCursor}}
+	}
+	return reviews, cursor, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// zendeskStandIn serves two pages of the incremental export and then, for
+// the final cursor, one ticket updated since the last sync
+func zendeskStandIn(t *testing.T) *httptest.Server {
+	var limited int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/incremental/tickets/cursor.json" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, token, _ := r.BasicAuth(); user != "agent@example.com/token" || token != "zd-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		query := r.URL.Query()
+		switch query.Get("cursor") {
+		case "":
+			if query.Get("start_time") != "1704067200" {
+				t.Errorf("start_time = %q, want the since date", query.Get("start_time"))
+			}
+			fmt.Fprint(w, `{"tickets":[
+				{"id":1,"subject":"Export broken","description":"CSV export fails","created_at":"2024-02-02T10:00:00Z","requester_id":7,"status":"open","satisfaction_rating":{"score":"bad"}},
+				{"id":2,"subject":"Spam","description":"x","created_at":"2024-02-02T11:00:00Z","requester_id":8,"status":"deleted"}
+			],"after_cursor":"c1","end_of_stream":false}`)
+		case "c1":
+			// Rate limited once mid-export
+			if atomic.AddInt32(&limited, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"tickets":[
+				{"id":3,"subject":"Thanks","description":"Fixed quickly","created_at":"2024-02-03T09:00:00Z","requester_id":9,"status":"solved","satisfaction_rating":{"score":"good"}},
+				{"id":4,"subject":"Question","description":"How do I invite?","created_at":"2024-02-03T12:00:00Z","requester_id":10,"status":"pending","satisfaction_rating":{"score":"offered"}}
+			],"after_cursor":"c2","end_of_stream":true}`)
+		case "c2":
+			fmt.Fprint(w, `{"tickets":[
+				{"id":1,"subject":"Export broken","description":"CSV export fails","created_at":"2024-02-02T10:00:00Z","requester_id":7,"status":"solved","satisfaction_rating":{"score":"good"}}
+			],"after_cursor":"c3","end_of_stream":true}`)
+		default:
+			t.Errorf("unexpected cursor %q", query.Get("cursor"))
+		}
+	}))
+}
+
+func TestZendeskConnectorSync(t *testing.T) {
+	srv := zendeskStandIn(t)
+	defer srv.Close()
+	t.Setenv("JOB_SECRET_ZENDESK_TOKEN", "zd-token")
+
+	connector, err := newConnector(ConnectorConfig{
+		Name: "support", Type: connectorZendesk, BaseURL: srv.URL, Since: "2024-01-01",
+		Zendesk: &ZendeskConfig{Subdomain: "acme", Email: "agent@example.com", APIToken: "${JOB_SECRET_ZENDESK_TOKEN}"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+
+	reviews, cursor, err := connector.Sync("")
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if cursor != "c2" {
+		t.Fatalf("cursor = %q, want c2", cursor)
+	}
+	if got := strings.Join(reviewIDs(reviews), ","); got != "zendesk-1,zendesk-3,zendesk-4" {
+		t.Fatalf("reviews = %s, want the deleted ticket skipped", got)
+	}
+	want := Review{ID: "zendesk-1", Date: "2024-02-02", UserID: "7", ReviewText: "Export broken\n\nCSV export fails", Rating: 1, Source: "support_ticket"}
+	if reviews[0] != want {
+		t.Fatalf("review = %+v, want %+v", reviews[0], want)
+	}
+	if reviews[1].Rating != 5 || reviews[2].Rating != 0 {
+		t.Fatalf("ratings = %d, %d; want 5 for good and 0 for unrated", reviews[1].Rating, reviews[2].Rating)
+	}
+
+	// Resuming from the cursor returns only the updated ticket
+	reviews, cursor, err = connector.Sync("c2")
+	if err != nil {
+		t.Fatalf("resumed Sync: %v", err)
+	}
+	if len(reviews) != 1 || reviews[0].Rating != 5 || cursor != "c3" {
+		t.Fatalf("resumed sync = %+v, cursor %q", reviews, cursor)
+	}
+}
+
+func TestZendeskConnectorRejectsHostInSubdomain(t *testing.T) {
+	_, err := newConnector(ConnectorConfig{Name: "zd", Type: connectorZendesk,
+		Zendesk: &ZendeskConfig{Subdomain: "evil.example.com/x", Email: "a@example.com", APIToken: "t"}}, nil)
+	if err == nil {
+		t.Fatal("a subdomain naming another host was accepted")
+	}
+}
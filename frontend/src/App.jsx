@@ -131,7 +131,7 @@ function App() {
             </div>
           </section>
         ) : (
-          <Dashboard data={analysisResult} />
+          <Dashboard data={analysisResult} apiBase={API_BASE} />
         )}
       </main>
     </div>
@@ -1,3 +1,4 @@
+import { useState } from 'react'
 import { Bar, Doughnut } from 'react-chartjs-2'
 import {
     Chart as ChartJS,
@@ -20,8 +21,19 @@ ChartJS.register(
     Legend
 )
 
-function Dashboard({ data }) {
+function Dashboard({ data, apiBase }) {
     const { comparison, impact, pre_launch_reviews, post_launch_reviews } = data
+    const [openTheme, setOpenTheme] = useState(null)
+
+    // Drill-down: reviews are looked up by ID within their phase
+    const reviewsById = (collection) =>
+        Object.fromEntries((collection.reviews || []).map((r) => [r.id, r]))
+    const preById = reviewsById(pre_launch_reviews)
+    const postById = reviewsById(post_launch_reviews)
+    const themeReviews = (theme) => [
+        ...(theme.pre_review_ids || []).map((id) => ({ phase: 'Pre', review: preById[id] })),
+        ...(theme.post_review_ids || []).map((id) => ({ phase: 'Post', review: postById[id] })),
+    ].filter((r) => r.review)
 
     // Chart options
     const chartOptions = {
@@ -175,14 +187,36 @@ function Dashboard({ data }) {
                 </div>
             </div>
 
+            {/* New issues */}
+            {data.new_issues && data.new_issues.length > 0 && (
+                <section className="glass-card new-issues" style={{ marginBottom: '48px' }}>
+                    <h3 className="chart-title">🚨 New Issues</h3>
+                    <ul>
+                        {data.new_issues.map((issue, i) => (
+                            <li key={i} className={`issue-${issue.level}`}>
+                                <span className="issue-level">{issue.level}</span>
+                                <strong>{issue.parent ? `${issue.parent} › ` : ''}{issue.theme}</strong>
+                                {' '}({issue.severity.toFixed(0)}/100): {issue.reasons.join('; ')}
+                                {issue.evidence.slice(0, 1).map((e, j) => (
+                                    <blockquote key={j} className="evidence-quote">“{e.quote}”</blockquote>
+                                ))}
+                            </li>
+                        ))}
+                    </ul>
+                </section>
+            )}
+
             {/* Themes */}
             <section className="glass-card" style={{ marginBottom: '48px' }}>
                 <h3 className="chart-title">🏷️ Key Themes Analysis</h3>
                 <div className="themes-grid">
                     {comparison.themes.map((theme, index) => (
-                        <div key={index} className="theme-card">
+                        <div key={index} className={`theme-card ${theme.highlighted ? 'highlighted' : ''}`}>
                             <div className="theme-header">
-                                <span className="theme-name">{theme.theme}</span>
+                                <span className="theme-name">
+                                    {theme.theme}
+                                    {theme.proposed && <span className="theme-proposed" title="Not yet in the approved taxonomy">proposed</span>}
+                                </span>
                                 <span className={`theme-change ${theme.change_rate >= 0 ? 'positive' : 'negative'}`}>
                                     {theme.change_rate >= 0 ? '+' : ''}{theme.change_rate.toFixed(0)}%
                                 </span>
@@ -190,11 +224,46 @@ function Dashboard({ data }) {
                             <div className="theme-stats">
                                 <span>Pre: {theme.pre_count} mentions</span>
                                 <span>Post: {theme.post_count} mentions</span>
-                                <span>Sentiment: {theme.sentiment}</span>
+                                <span>Pre sentiment: 👍 {theme.pre_sentiment.positive} · 👎 {theme.pre_sentiment.negative} · 😐 {theme.pre_sentiment.neutral}</span>
+                                <span>Post sentiment: 👍 {theme.post_sentiment.positive} · 👎 {theme.post_sentiment.negative} · 😐 {theme.post_sentiment.neutral}</span>
                             </div>
+                            {theme.evidence.slice(0, 1).map((e, j) => (
+                                <blockquote key={j} className="evidence-quote">“{e.quote}”</blockquote>
+                            ))}
+                            <button
+                                className="theme-drilldown-toggle"
+                                onClick={() => setOpenTheme(openTheme === theme.theme ? null : theme.theme)}
+                            >
+                                {openTheme === theme.theme ? 'Hide details' : `Details${theme.sub_themes ? ` · ${theme.sub_themes.length} sub-themes` : ''}`}
+                            </button>
+                            {openTheme === theme.theme && (
+                                <div className="theme-drilldown">
+                                    {(theme.sub_themes || []).map((sub, j) => (
+                                        <details key={j} className="sub-theme">
+                                            <summary>
+                                                {sub.theme}: {sub.pre_count} → {sub.post_count} mentions
+                                                ({sub.change_rate >= 0 ? '+' : ''}{sub.change_rate.toFixed(0)}%)
+                                            </summary>
+                                            {themeReviews(sub).map(({ phase, review }, k) => (
+                                                <p key={k} className="drilldown-review">[{phase}] {review.review_text}</p>
+                                            ))}
+                                        </details>
+                                    ))}
+                                    {!theme.sub_themes && themeReviews(theme).map(({ phase, review }, k) => (
+                                        <p key={k} className="drilldown-review">[{phase}] {review.review_text}</p>
+                                    ))}
+                                </div>
+                            )}
                         </div>
                     ))}
                 </div>
+                {comparison.long_tail && (
+                    <p className="theme-long-tail">
+                        Long tail: {comparison.long_tail.themes.length} smaller themes
+                        ({comparison.long_tail.pre_count} → {comparison.long_tail.post_count} mentions):{' '}
+                        {comparison.long_tail.themes.map((t) => t.theme).join(', ')}
+                    </p>
+                )}
             </section>
 
             {/* Lists */}
@@ -203,7 +272,12 @@ function Dashboard({ data }) {
                     <h3>✅ Key Improvements</h3>
                     <ul>
                         {impact.key_improvements.map((item, i) => (
-                            <li key={i}>{item}</li>
+                            <li key={i}>
+                                {item.text}
+                                {item.evidence.map((e, j) => (
+                                    <blockquote key={j} className="evidence-quote">“{e.quote}”</blockquote>
+                                ))}
+                            </li>
                         ))}
                     </ul>
                 </div>
@@ -212,7 +286,12 @@ function Dashboard({ data }) {
                     <ul>
                         {impact.critical_issues.length > 0 ? (
                             impact.critical_issues.map((item, i) => (
-                                <li key={i}>{item}</li>
+                                <li key={i}>
+                                    {item.text}
+                                    {item.evidence.map((e, j) => (
+                                        <blockquote key={j} className="evidence-quote">“{e.quote}”</blockquote>
+                                    ))}
+                                </li>
                             ))
                         ) : (
                             <li>No critical issues identified</li>
@@ -228,6 +307,31 @@ function Dashboard({ data }) {
                     </ul>
                 </div>
             </div>
+
+            {/* Exports */}
+            {apiBase && (
+                <div className="glass-card export-card">
+                    <h3>⬇️ Export</h3>
+                    {['reviews', 'themes'].map((table) => (
+                        <div key={table} className="export-row">
+                            <span className="export-table">{table === 'reviews' ? 'Per-review table' : 'Theme table'}</span>
+                            {['csv', 'xlsx', 'parquet'].map((format) => (
+                                <a key={format} className="export-link" href={`${apiBase}/export?table=${table}&format=${format}`}>
+                                    {format.toUpperCase()}
+                                </a>
+                            ))}
+                        </div>
+                    ))}
+                    <div className="export-row">
+                        <span className="export-table">Launch report</span>
+                        {['html', 'pdf', 'markdown'].map((format) => (
+                            <a key={format} className="export-link" href={`${apiBase}/report?format=${format}`} target="_blank" rel="noreferrer">
+                                {format === 'markdown' ? 'MD' : format.toUpperCase()}
+                            </a>
+                        ))}
+                    </div>
+                </div>
+            )}
         </div>
     )
 }